@@ -1,39 +1,110 @@
 package main
 
 import (
-	"log"
+	"encoding/json"
+	"flag"
 	"os"
+	"strings"
 
 	"github.com/trustacks/catalog/pkg/catalog"
 	"github.com/trustacks/catalog/pkg/components"
 	"github.com/trustacks/catalog/pkg/functions"
 	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
 	"github.com/trustacks/catalog/server"
 )
 
+// inClusterNamespace is the path of the pod namespace file.
+const inClusterNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 var (
 	mode           = os.Getenv("CATALOG_MODE")
 	hookComponent  = os.Getenv("HOOK_COMPONENT")
 	hookKind       = os.Getenv("HOOK_KIND")
 	functionName   = os.Getenv("FUNCTION_NAME")
 	functionParams = os.Getenv("FUNCTION_PARAMS")
+	pushgatewayURL = os.Getenv("CATALOG_PUSHGATEWAY_URL")
+	logLevel       = flag.String("log-level", os.Getenv("CATALOG_LOG_LEVEL"), "the minimum log level (debug, info, warn, error)")
 )
 
+// pushRunMetrics pushes the hook or function run metrics if a
+// pushgateway is configured.
+func pushRunMetrics(run *metrics.Run, runErr error) {
+	if pushgatewayURL == "" {
+		return
+	}
+	if err := run.Push(pushgatewayURL, runErr); err != nil {
+		logging.Warn("error pushing the run metrics", "error", err)
+	}
+}
+
+// applicationFunctions are the functions whose name param is the
+// application name.
+var applicationFunctions = map[string]bool{
+	"create-application": true,
+	"update-application": true,
+	"delete-application": true,
+	"application-status": true,
+}
+
+// setLogFields sets the run context fields of the log lines.
+func setLogFields() {
+	logging.SetField("mode", mode)
+	logging.SetField("component", hookComponent)
+	logging.SetField("hook", hookKind)
+	logging.SetField("function", functionName)
+	if data, err := os.ReadFile(inClusterNamespace); err == nil {
+		namespace := strings.TrimSpace(string(data))
+		logging.SetField("toolchain", strings.TrimPrefix(namespace, "trustacks-toolchain-"))
+	}
+	params := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(functionParams), &params); err == nil {
+		fields := map[string]string{"toolchain": "toolchain", "name": "name", "component": "component", "provider": "component"}
+		// the application functions are called with the application
+		// name, while the other functions name a client or user.
+		if applicationFunctions[functionName] {
+			fields["name"] = "application"
+		}
+		for param, field := range fields {
+			if value, ok := params[param].(string); ok {
+				logging.SetField(field, value)
+			}
+		}
+	}
+}
+
 func main() {
+	flag.Parse()
+	if *logLevel != "" {
+		level, err := logging.ParseLevel(*logLevel)
+		if err != nil {
+			logging.Fatal("error parsing the log level", "error", err)
+		}
+		logging.SetLevel(level)
+	}
+	setLogFields()
+
 	cat, err := catalog.NewComponentCatalog()
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("error loading the catalog", "error", err)
 	}
 	components.Initialize(cat)
 
 	switch mode {
 	case "hook":
-		if err := hooks.Call(hookComponent, hookKind); err != nil {
-			log.Fatal(err)
+		run := metrics.StartRun("catalog-hook", map[string]string{"component": hookComponent, "hook": hookKind})
+		err := hooks.Call(hookComponent, hookKind)
+		pushRunMetrics(run, err)
+		if err != nil {
+			logging.Fatal("hook failed", "error", err)
 		}
 	case "function":
-		if _, err := functions.Call(functionName, []byte(functionParams)); err != nil {
-			log.Fatal(err)
+		run := metrics.StartRun("catalog-function", map[string]string{"function": functionName})
+		_, err := functions.Call(functionName, []byte(functionParams))
+		pushRunMetrics(run, err)
+		if err != nil {
+			logging.Fatal("function failed", "error", err)
 		}
 	default:
 		server.StartCatalogServer(cat)
@@ -0,0 +1,428 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// manifestName is the name of the backup manifest archive entry.
+	manifestName = "manifest.json"
+	// secretKind is the kind of the backed up secrets.
+	secretKind = "Secret"
+	// configMapKind is the kind of the backed up config maps.
+	configMapKind = "ConfigMap"
+)
+
+var (
+	// toolchainSecrets are the catalog managed toolchain secrets that
+	// are backed up by name, in restore order. The age key secret is
+	// restored before any other object so the encrypted system secrets
+	// can be decrypted as soon as they are restored. The authentik api
+	// token secret is unlabeled on the installs that predate its
+	// part-of label.
+	toolchainSecrets = []string{
+		"sops-age",
+		"system-secrets",
+		"authentik-bootstrap",
+	}
+	// componentSecretsSelector selects the secrets that the components
+	// and the sso provider functions label as part of a component. The
+	// chart managed secrets are recreated by the chart, and are left
+	// out.
+	componentSecretsSelector = "app.kubernetes.io/part-of,app.kubernetes.io/managed-by!=Helm"
+	// toolchainConfigMaps are the catalog managed toolchain config
+	// maps in restore order.
+	toolchainConfigMaps = []string{
+		"system-vars",
+		"catalog-migrations",
+	}
+	// applicationInputsPattern matches the per application vars and
+	// secrets names.
+	applicationInputsPattern = regexp.MustCompile(`^application-.+-(vars|secrets)$`)
+)
+
+// Entry describes a backed up object.
+type Entry struct {
+	Kind string   `json:"kind"`
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+}
+
+// Manifest contains the contents of a backup.
+type Manifest struct {
+	Namespace string    `json:"namespace"`
+	CreatedAt time.Time `json:"createdAt"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// object is a backed up secret or config map.
+type object struct {
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Data   map[string][]byte `json:"data"`
+}
+
+// Difference is the difference between a backed up object and the
+// live object. Only the key names are reported so the diff can be
+// logged without exposing the values.
+type Difference struct {
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Missing bool     `json:"missing,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// String returns the human readable difference.
+func (d Difference) String() string {
+	if d.Missing {
+		return fmt.Sprintf("%s/%s: missing", d.Kind, d.Name)
+	}
+	return fmt.Sprintf("%s/%s: added %v, removed %v, changed %v", d.Kind, d.Name, d.Added, d.Removed, d.Changed)
+}
+
+// entryName returns the archive entry name of the object.
+func entryName(kind, name string) string {
+	return fmt.Sprintf("%ss/%s.json", strings.ToLower(kind), name)
+}
+
+// sortedKeys returns the sorted keys of the object data.
+func sortedKeys(data map[string][]byte) []string {
+	keys := []string{}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// collect gets the catalog managed objects of the toolchain in
+// restore order.
+func collect(namespace string, clientset kubernetes.Interface) ([]object, error) {
+	objects := []object{}
+	for _, name := range toolchainSecrets {
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, secretObject(secret))
+	}
+	componentSecrets, err := clientset.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: componentSecretsSelector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(componentSecrets.Items, func(i, j int) bool { return componentSecrets.Items[i].Name < componentSecrets.Items[j].Name })
+	for i := range componentSecrets.Items {
+		if !isToolchainSecret(componentSecrets.Items[i].Name) {
+			objects = append(objects, secretObject(&componentSecrets.Items[i]))
+		}
+	}
+	for _, name := range toolchainConfigMaps {
+		configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, configMapObject(configMap))
+	}
+	// the application inputs are restored last since they are
+	// created from the system inputs.
+	configMaps, err := clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(configMaps.Items, func(i, j int) bool { return configMaps.Items[i].Name < configMaps.Items[j].Name })
+	for i := range configMaps.Items {
+		if applicationInputsPattern.MatchString(configMaps.Items[i].Name) {
+			objects = append(objects, configMapObject(&configMaps.Items[i]))
+		}
+	}
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool { return secrets.Items[i].Name < secrets.Items[j].Name })
+	for i := range secrets.Items {
+		if applicationInputsPattern.MatchString(secrets.Items[i].Name) {
+			objects = append(objects, secretObject(&secrets.Items[i]))
+		}
+	}
+	return objects, nil
+}
+
+// isToolchainSecret returns whether the secret is collected by name.
+func isToolchainSecret(name string) bool {
+	for _, n := range toolchainSecrets {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretObject converts the secret to a backup object.
+func secretObject(secret *corev1.Secret) object {
+	return object{Kind: secretKind, Name: secret.Name, Labels: secret.Labels, Data: secret.Data}
+}
+
+// configMapObject converts the config map to a backup object.
+func configMapObject(configMap *corev1.ConfigMap) object {
+	data := map[string][]byte{}
+	for k, v := range configMap.Data {
+		data[k] = []byte(v)
+	}
+	return object{Kind: configMapKind, Name: configMap.Name, Labels: configMap.Labels, Data: data}
+}
+
+// writeEntry writes the json encoded value to the archive.
+func writeEntry(tw *tar.Writer, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// Backup writes the catalog managed objects of the toolchain
+// namespace to an age encrypted tarball. The application namespaces,
+// which hold the plaintext copies of the system secrets that the
+// pipelines read, are not backed up.
+func Backup(namespace string, recipient age.Recipient, w io.Writer, clientset kubernetes.Interface) (*Manifest, error) {
+	objects, err := collect(namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Namespace: namespace, CreatedAt: time.Now().UTC()}
+	for _, o := range objects {
+		manifest.Entries = append(manifest.Entries, Entry{Kind: o.Kind, Name: o.Name, Keys: sortedKeys(o.Data)})
+	}
+	ew, err := age.Encrypt(w, recipient)
+	if err != nil {
+		return nil, err
+	}
+	gw := gzip.NewWriter(ew)
+	tw := tar.NewWriter(gw)
+	if err := writeEntry(tw, manifestName, manifest); err != nil {
+		return nil, err
+	}
+	for _, o := range objects {
+		if err := writeEntry(tw, entryName(o.Kind, o.Name), o); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range []io.Closer{tw, gw, ew} {
+		if err := c.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// read decrypts the backup and returns the manifest and the backed
+// up objects in restore order.
+func read(identities []age.Identity, r io.Reader) (*Manifest, []object, error) {
+	dr, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, nil, err
+	}
+	gr, err := gzip.NewReader(dr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	var manifest *Manifest
+	entries := map[string]object{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if header.Name == manifestName {
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		o := object{}
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, nil, err
+		}
+		entries[header.Name] = o
+	}
+	if manifest == nil {
+		return nil, nil, errors.New("backup manifest not found")
+	}
+	objects := []object{}
+	for _, entry := range manifest.Entries {
+		o, ok := entries[entryName(entry.Kind, entry.Name)]
+		if !ok {
+			return nil, nil, fmt.Errorf("'%s/%s' backup entry not found", entry.Kind, entry.Name)
+		}
+		objects = append(objects, o)
+	}
+	return manifest, objects, nil
+}
+
+// Restore restores the backed up objects in the toolchain namespace.
+// Objects are created or replaced in the manifest order, so the
+// restore can safely be run more than once.
+func Restore(namespace string, identities []age.Identity, r io.Reader, clientset kubernetes.Interface) (*Manifest, error) {
+	manifest, objects, err := read(identities, r)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objects {
+		var err error
+		switch o.Kind {
+		case secretKind:
+			err = restoreSecret(o, namespace, clientset)
+		case configMapKind:
+			err = restoreConfigMap(o, namespace, clientset)
+		default:
+			err = fmt.Errorf("'%s' is not a supported backup kind", o.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// restoreSecret creates or replaces the secret.
+func restoreSecret(o object, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().Secrets(namespace)
+	current, err := client.Get(context.TODO(), o.Name, metav1.GetOptions{})
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: o.Name, Labels: o.Labels}, Data: o.Data}
+		_, err = client.Create(context.TODO(), secret, metav1.CreateOptions{})
+		return err
+	}
+	current.Labels, current.Data = o.Labels, o.Data
+	_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{})
+	return err
+}
+
+// restoreConfigMap creates or replaces the config map.
+func restoreConfigMap(o object, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().ConfigMaps(namespace)
+	data := map[string]string{}
+	for k, v := range o.Data {
+		data[k] = string(v)
+	}
+	current, err := client.Get(context.TODO(), o.Name, metav1.GetOptions{})
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: o.Name, Labels: o.Labels}, Data: data}
+		_, err = client.Create(context.TODO(), configMap, metav1.CreateOptions{})
+		return err
+	}
+	current.Labels, current.Data = o.Labels, data
+	_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{})
+	return err
+}
+
+// Verify diffs the backed up objects against the live objects in the
+// toolchain namespace.
+func Verify(namespace string, identities []age.Identity, r io.Reader, clientset kubernetes.Interface) ([]Difference, error) {
+	_, objects, err := read(identities, r)
+	if err != nil {
+		return nil, err
+	}
+	differences := []Difference{}
+	for _, o := range objects {
+		var live map[string][]byte
+		found := false
+		switch o.Kind {
+		case secretKind:
+			secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), o.Name, metav1.GetOptions{})
+			if err != nil && !strings.Contains(err.Error(), "not found") {
+				return nil, err
+			}
+			if err == nil {
+				live, found = secret.Data, true
+			}
+		case configMapKind:
+			configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), o.Name, metav1.GetOptions{})
+			if err != nil && !strings.Contains(err.Error(), "not found") {
+				return nil, err
+			}
+			if err == nil {
+				live, found = configMapObject(configMap).Data, true
+			}
+		default:
+			return nil, fmt.Errorf("'%s' is not a supported backup kind", o.Kind)
+		}
+		if !found {
+			differences = append(differences, Difference{Kind: o.Kind, Name: o.Name, Missing: true})
+			continue
+		}
+		if d := diff(o, live); d != nil {
+			differences = append(differences, *d)
+		}
+	}
+	return differences, nil
+}
+
+// diff compares the backed up object data with the live data. Keys
+// only found in the backup are reported as removed from the cluster.
+func diff(o object, live map[string][]byte) *Difference {
+	d := &Difference{Kind: o.Kind, Name: o.Name}
+	for _, k := range sortedKeys(o.Data) {
+		v, ok := live[k]
+		if !ok {
+			d.Removed = append(d.Removed, k)
+		} else if string(v) != string(o.Data[k]) {
+			d.Changed = append(d.Changed, k)
+		}
+	}
+	for _, k := range sortedKeys(live) {
+		if _, ok := o.Data[k]; !ok {
+			d.Added = append(d.Added, k)
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return nil
+	}
+	return d
+}
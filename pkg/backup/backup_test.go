@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newToolchain creates a clientset with the catalog managed objects.
+func newToolchain(t *testing.T) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	secrets := []*corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Name: "sops-age"}, Data: map[string][]byte{"age.agekey": []byte("key")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "system-secrets"}, Data: map[string][]byte{"test.password": []byte("password")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "authentik-bootstrap"}, Data: map[string][]byte{"api-token": []byte("token")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "keycloak-admin", Labels: map[string]string{"app.kubernetes.io/part-of": "keycloak"}}, Data: map[string][]byte{"password": []byte("admin")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "dex-config", Labels: map[string]string{"app.kubernetes.io/part-of": "dex"}}, Data: map[string][]byte{"config.yaml": []byte("issuer: dex")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "authentik-oidc-client-sonarqube", Labels: map[string]string{"app.kubernetes.io/part-of": "authentik"}}, Data: map[string][]byte{"client-id": []byte("sonarqube")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "argocd-secret", Labels: map[string]string{"app.kubernetes.io/part-of": "argocd", "app.kubernetes.io/managed-by": "Helm"}}, Data: map[string][]byte{"server.secretkey": []byte("key")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "application-test-secrets"}, Data: map[string][]byte{"token": []byte("token")}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}, Data: map[string][]byte{"key": []byte("value")}},
+	}
+	for _, secret := range secrets {
+		if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configMaps := []*corev1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: "system-vars"}, Data: map[string]string{"test.server": "server"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "application-test-vars"}, Data: map[string]string{"name": "test"}},
+	}
+	for _, configMap := range configMaps {
+		if _, err := clientset.CoreV1().ConfigMaps("test").Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return clientset
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	manifest, err := Backup("test", identity.Recipient(), &buf, newToolchain(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range manifest.Entries {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(t, []string{"sops-age", "system-secrets", "authentik-bootstrap", "authentik-oidc-client-sonarqube", "dex-config", "keycloak-admin", "system-vars", "application-test-vars", "application-test-secrets"}, names, "got unexpected backup entries")
+	assert.NotContains(t, buf.String(), "password", "expected an encrypted backup")
+
+	clientset := fake.NewSimpleClientset()
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "system-secrets"}, Data: map[string][]byte{"test.password": []byte("changed")}}
+	if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), existing, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// restoring twice should leave the same objects.
+	for i := 0; i < 2; i++ {
+		if _, err := Restore("test", []age.Identity{identity}, bytes.NewReader(buf.Bytes()), clientset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "password", string(secret.Data["test.password"]), "expected the secret to be restored")
+	configMap, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), "application-test-vars", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", configMap.Data["name"], "expected the config map to be restored")
+	secret, err = clientset.CoreV1().Secrets("test").Get(context.TODO(), "dex-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "dex", secret.Labels["app.kubernetes.io/part-of"], "expected the component secret labels to be restored")
+	for _, name := range []string{"unmanaged", "argocd-secret"} {
+		if _, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), name, metav1.GetOptions{}); err == nil {
+			t.Fatalf("expected the %s secret to be excluded from the backup", name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientset := newToolchain(t)
+	var buf bytes.Buffer
+	if _, err := Backup("test", identity.Recipient(), &buf, clientset); err != nil {
+		t.Fatal(err)
+	}
+	differences, err := Verify("test", []age.Identity{identity}, bytes.NewReader(buf.Bytes()), clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, differences, "expected no differences")
+
+	secret, _ := clientset.CoreV1().Secrets("test").Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	secret.Data = map[string][]byte{"test.password": []byte("changed"), "test.token": []byte("token")}
+	if _, err := clientset.CoreV1().Secrets("test").Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clientset.CoreV1().ConfigMaps("test").Delete(context.TODO(), "system-vars", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	differences, err = Verify("test", []age.Identity{identity}, bytes.NewReader(buf.Bytes()), clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Difference{
+		{Kind: secretKind, Name: "system-secrets", Added: []string{"test.token"}, Changed: []string{"test.password"}},
+		{Kind: configMapKind, Name: "system-vars", Missing: true},
+	}, differences, "got unexpected differences")
+}
+
+func TestRestoreWrongIdentity(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := Backup("test", identity.Recipient(), &buf, newToolchain(t)); err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore("test", []age.Identity{other}, &buf, fake.NewSimpleClientset()); err == nil {
+		t.Fatal("expected a decryption error")
+	}
+}
+
+func TestBackupLabeledToolchainSecret(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the labeled api token secret is backed up once, by name.
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "authentik-bootstrap", Namespace: "test", Labels: map[string]string{"app.kubernetes.io/part-of": "authentik"}},
+		Data:       map[string][]byte{"api-token": []byte("token")},
+	})
+	var buf bytes.Buffer
+	manifest, err := Backup("test", identity.Recipient(), &buf, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Entry{{Kind: secretKind, Name: "authentik-bootstrap", Keys: []string{"api-token"}}}, manifest.Entries)
+}
+
+func TestBackupSkipsApplicationNamespaces(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the plaintext application secrets copy is not backed up.
+	clientset := newToolchain(t)
+	applicationSecrets := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "application-secrets", Labels: map[string]string{"app.kubernetes.io/part-of": "concourse"}},
+		Data:       map[string][]byte{"test.password": []byte("password")},
+	}
+	if _, err := clientset.CoreV1().Secrets("trustacks-application-test-app").Create(context.TODO(), applicationSecrets, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	manifest, err := Backup("test", identity.Recipient(), &buf, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range manifest.Entries {
+		assert.NotEqual(t, "application-secrets", entry.Name, "expected the application secrets copy to be left out")
+	}
+}
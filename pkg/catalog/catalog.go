@@ -1,9 +1,13 @@
 package catalog
 
 import (
+	"bytes"
 	_ "embed"
+	"fmt"
 	"os"
+	"text/template"
 
+	"github.com/Masterminds/sprig/v3"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,8 +20,13 @@ var (
 // mode.
 type component interface {
 	repo() string
+	mirrorRepo() string
 	chart() string
 	version() string
+	values() string
+	hooks() string
+	applicationHooks() string
+	artifacts() *Artifacts
 	preInstall() error
 	postInstall() error
 	preDelete() error
@@ -49,6 +58,71 @@ func (c *ComponentCatalog) AddComponent(name string, component component) {
 	c.Components[name] = component
 }
 
+// ChartVersion contains the helm chart and chart version of a
+// component.
+type ChartVersion struct {
+	Chart   string
+	Version string
+}
+
+// ChartVersions returns the chart versions of the catalog components.
+func (c *ComponentCatalog) ChartVersions() map[string]ChartVersion {
+	versions := map[string]ChartVersion{}
+	for name, component := range c.Components {
+		versions[name] = ChartVersion{Chart: component.chart(), Version: component.version()}
+	}
+	return versions
+}
+
+// RenderValues renders the component's helm values template with the
+// parameters. Parameters that are not set use the catalog defaults.
+// The sprig functions are available to the template as they are when
+// the values are rendered for an install.
+func (c *ComponentCatalog) RenderValues(name string, params map[string]string) (string, error) {
+	component, ok := c.Components[name]
+	if !ok {
+		return "", fmt.Errorf("'%s' component not found", name)
+	}
+	return c.render(name, component.values(), params)
+}
+
+// RenderRepository renders the component's helm repository with the
+// parameters. The chartMirror parameter replaces the upstream
+// repository when it is set.
+func (c *ComponentCatalog) RenderRepository(name string, params map[string]string) (string, error) {
+	component, ok := c.Components[name]
+	if !ok {
+		return "", fmt.Errorf("'%s' component not found", name)
+	}
+	if component.mirrorRepo() == "" {
+		return component.repo(), nil
+	}
+	return c.render(name, component.mirrorRepo(), params)
+}
+
+// render renders the component template with the parameters and the
+// catalog defaults.
+func (c *ComponentCatalog) render(name, text string, params map[string]string) (string, error) {
+	data := map[string]string{}
+	if c.Config != nil {
+		for _, param := range c.Config.Parameters {
+			data[param.Name] = param.Default
+		}
+	}
+	for k, v := range params {
+		data[k] = v
+	}
+	tmpl, err := template.New(name).Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // loadConfig loads the catalog configuration yaml file.
 func loadConfig(data []byte) (*componentCatalogConfig, error) {
 	var config *componentCatalogConfig
@@ -26,6 +26,50 @@ func TestCatalogAddComponent(t *testing.T) {
 	}
 }
 
+func TestCatalogChartVersions(t *testing.T) {
+	cat, err := NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat.AddComponent("test", &testComponent{
+		&BaseComponent{
+			Chart:   "test/test",
+			Version: "1.0.0",
+		},
+	})
+	if cat.ChartVersions()["test"] != (ChartVersion{Chart: "test/test", Version: "1.0.0"}) {
+		t.Fatal("got an unexpected chart version")
+	}
+}
+
+func TestCatalogRenderValues(t *testing.T) {
+	cat, err := NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat.Config = &componentCatalogConfig{
+		Parameters: []componentCatalogConfigParameters{
+			{Name: "domain", Default: "local.gd"},
+			{Name: "tls", Default: "true"},
+		},
+	}
+	cat.AddComponent("test", &testComponent{
+		&BaseComponent{
+			Values: `host: test.{{ .domain }}{{ if eq .tls "true" }}:443{{ end }}`,
+		},
+	})
+	values, err := cat.RenderValues("test", map[string]string{"domain": "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values != "host: test.example.com:443" {
+		t.Fatalf("got unexpected rendered values: %s", values)
+	}
+	if _, err := cat.RenderValues("missing", nil); err == nil {
+		t.Fatal("expected a missing component error")
+	}
+}
+
 func TestCatalogLoadConfig(t *testing.T) {
 	raw := []byte(`parameters:
 - name: test
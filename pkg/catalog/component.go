@@ -1,14 +1,18 @@
 package catalog
 
+import "fmt"
+
 // baseComponent contains default fields and methods for implemented
 // components.
 type BaseComponent struct {
-	Repo             string `json:"repository"`
-	Chart            string `json:"chart"`
-	Version          string `json:"version"`
-	Values           string `json:"values"`
-	Hooks            string `json:"hooks"`
-	ApplicationHooks string `json:"applicationHooks,omitempty"`
+	Repo             string     `json:"repository"`
+	MirrorRepo       string     `json:"mirrorRepository,omitempty"`
+	Chart            string     `json:"chart"`
+	Version          string     `json:"version"`
+	Values           string     `json:"values"`
+	Hooks            string     `json:"hooks"`
+	ApplicationHooks string     `json:"applicationHooks,omitempty"`
+	Artifacts        *Artifacts `json:"artifacts,omitempty"`
 }
 
 // repo returns the component's helm repository.
@@ -16,6 +20,12 @@ func (c *BaseComponent) repo() string {
 	return c.Repo
 }
 
+// mirrorRepo returns the component's helm repository template that
+// applies the chartMirror parameter.
+func (c *BaseComponent) mirrorRepo() string {
+	return c.MirrorRepo
+}
+
 // chart returns the component's helm chart.
 func (c *BaseComponent) chart() string {
 	return c.Chart
@@ -26,6 +36,27 @@ func (c *BaseComponent) version() string {
 	return c.Version
 }
 
+// values returns the component's helm values template.
+func (c *BaseComponent) values() string {
+	return c.Values
+}
+
+// hooks returns the component's helm hooks template.
+func (c *BaseComponent) hooks() string {
+	return c.Hooks
+}
+
+// applicationHooks returns the component's application helm hooks
+// template.
+func (c *BaseComponent) applicationHooks() string {
+	return c.ApplicationHooks
+}
+
+// artifacts returns the charts and images needed by the component.
+func (c *BaseComponent) artifacts() *Artifacts {
+	return c.Artifacts
+}
+
 // preInstall executes after templates are rendered, but before any
 // resources are created in kubernetes.
 func (c *BaseComponent) preInstall() error {
@@ -81,4 +112,20 @@ type ComponentConfig struct {
 	Version   string
 	Values    string
 	Manifests string
+	Images    []string
+}
+
+// MirrorRepository returns the chart repository template. The
+// chartMirror parameter replaces the upstream repository when it is
+// set.
+func (c *ComponentConfig) MirrorRepository() string {
+	return fmt.Sprintf("{{ default %q .chartMirror }}", c.Repo)
+}
+
+// Artifacts returns the chart and images of the component.
+func (c *ComponentConfig) Artifacts() *Artifacts {
+	return &Artifacts{
+		Charts: []ChartArtifact{{Name: c.Chart, Version: c.Version, Repository: c.Repo}},
+		Images: c.Images,
+	}
 }
@@ -0,0 +1,177 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultHookSource is the hook image used to lint the hooks when the
+// catalog hook source is not set.
+const defaultHookSource = "quay.io/trustacks/catalog"
+
+// ChartArtifact is a helm chart needed by a component.
+type ChartArtifact struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+}
+
+// Artifacts contains the charts and images needed by a component. They
+// are listed in the catalog manifest so an offline mirror can be
+// populated before an air-gapped install.
+type Artifacts struct {
+	Charts []ChartArtifact `json:"charts"`
+	Images []string        `json:"images"`
+}
+
+// imageRegistry returns the registry host of the image reference, or
+// an empty string when the reference has no registry host.
+func imageRegistry(ref string) string {
+	i := strings.Index(ref, "/")
+	if i == -1 {
+		return ""
+	}
+	host := ref[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return ""
+}
+
+// MirrorImage replaces the registry host of the image reference with
+// the mirror. References without a registry host are prefixed with the
+// mirror. This is the rewrite applied by the registryMirror parameter.
+func MirrorImage(ref, mirror string) string {
+	if mirror == "" {
+		return ref
+	}
+	if host := imageRegistry(ref); host != "" {
+		ref = strings.TrimPrefix(ref, host+"/")
+	}
+	return mirror + "/" + ref
+}
+
+// imageRepository returns the image reference without the tag or
+// digest.
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i != -1 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// imageRefs walks the yaml node and calls fn with the value of every
+// image, image repository and image registry field.
+func imageRefs(node *yaml.Node, fn func(key, value string)) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			imageRefs(child, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			if key == "image" && value.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					if k := value.Content[j].Value; k == "repository" || k == "registry" {
+						fn("image."+k, value.Content[j+1].Value)
+					}
+				}
+				continue
+			}
+			if key == "image" && value.Kind == yaml.ScalarNode {
+				fn(key, value.Value)
+				continue
+			}
+			imageRefs(value, fn)
+		}
+	}
+}
+
+// LintMirror renders the component templates with the registryMirror
+// and chartMirror parameters and reports the chart and image
+// references that escape the mirrors, or that are missing from the
+// component artifacts. Both network modes are rendered since they use
+// different images.
+func (c *ComponentCatalog) LintMirror(name string) error {
+	component, ok := c.Components[name]
+	if !ok {
+		return fmt.Errorf("'%s' component not found", name)
+	}
+	const registryMirror, chartMirror = "registry.mirror.invalid", "https://charts.mirror.invalid"
+	hookSource := c.HookSource
+	if hookSource == "" {
+		hookSource = defaultHookSource
+	}
+	listed := map[string]bool{imageRepository(MirrorImage(hookSource, registryMirror)): true}
+	if artifacts := component.artifacts(); artifacts != nil {
+		for _, image := range artifacts.Images {
+			listed[imageRepository(MirrorImage(image, registryMirror))] = true
+		}
+	}
+	problems := map[string]bool{}
+	for _, network := range []string{"private", "public"} {
+		params := map[string]string{
+			"registryMirror": registryMirror,
+			"chartMirror":    chartMirror,
+			"network":        network,
+			"image":          hookSource,
+			"sso":            "authentik",
+			"toolchain":      "lint",
+			"application":    "lint",
+		}
+		repo, err := c.RenderRepository(name, params)
+		if err != nil {
+			return err
+		}
+		if repo != chartMirror {
+			problems[fmt.Sprintf("chart repository %q escapes the chart mirror", repo)] = true
+		}
+		for _, text := range []string{component.values(), component.hooks(), component.applicationHooks()} {
+			rendered, err := c.render(name, text, params)
+			if err != nil {
+				return err
+			}
+			decoder := yaml.NewDecoder(bytes.NewReader([]byte(rendered)))
+			for {
+				var doc yaml.Node
+				if err := decoder.Decode(&doc); err != nil {
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					return err
+				}
+				imageRefs(&doc, func(key, value string) {
+					switch {
+					case key == "image.registry":
+						if value != registryMirror {
+							problems[fmt.Sprintf("image registry %q escapes the registry mirror", value)] = true
+						}
+					case !strings.HasPrefix(value, registryMirror+"/"):
+						problems[fmt.Sprintf("image %q escapes the registry mirror", value)] = true
+					case !listed[imageRepository(value)]:
+						problems[fmt.Sprintf("image %q is not listed in the artifacts", value)] = true
+					}
+				})
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	messages := []string{}
+	for problem := range problems {
+		messages = append(messages, problem)
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("%s: %s", name, strings.Join(messages, "; "))
+}
@@ -0,0 +1,101 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMirrorImage(t *testing.T) {
+	tests := []struct {
+		ref    string
+		mirror string
+		want   string
+	}{
+		{"quay.io/trustacks/catalog:v1", "registry.test", "registry.test/trustacks/catalog:v1"},
+		{"localhost:5000/test", "registry.test", "registry.test/test"},
+		{"localhost/test", "registry.test", "registry.test/test"},
+		{"viaductoss/ksops:v3.0.2", "registry.test:5000", "registry.test:5000/viaductoss/ksops:v3.0.2"},
+		{"busybox", "registry.test", "registry.test/busybox"},
+		{"quay.io/trustacks/catalog", "", "quay.io/trustacks/catalog"},
+	}
+	for _, tc := range tests {
+		if got := MirrorImage(tc.ref, tc.mirror); got != tc.want {
+			t.Fatalf("expected '%s', got '%s'", tc.want, got)
+		}
+	}
+}
+
+func TestComponentConfigArtifacts(t *testing.T) {
+	conf := &ComponentConfig{
+		Repo:    "https://charts.test.com",
+		Chart:   "test",
+		Version: "1.0.0",
+		Images:  []string{"quay.io/test/test"},
+	}
+	artifacts := conf.Artifacts()
+	if artifacts.Charts[0] != (ChartArtifact{Name: "test", Version: "1.0.0", Repository: "https://charts.test.com"}) {
+		t.Fatal("got an unexpected chart artifact")
+	}
+	if len(artifacts.Images) != 1 || artifacts.Images[0] != "quay.io/test/test" {
+		t.Fatal("got unexpected image artifacts")
+	}
+	cat, err := NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat.AddComponent("test", &testComponent{&BaseComponent{Repo: conf.Repo, MirrorRepo: conf.MirrorRepository()}})
+	if repo := cat.Components["test"].repo(); repo != "https://charts.test.com" {
+		t.Fatalf("expected the repository to be the chart url, got '%s'", repo)
+	}
+	for mirror, want := range map[string]string{"": "https://charts.test.com", "https://mirror.test": "https://mirror.test"} {
+		repo, err := cat.RenderRepository("test", map[string]string{"chartMirror": mirror})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repo != want {
+			t.Fatalf("expected '%s', got '%s'", want, repo)
+		}
+	}
+}
+
+func TestCatalogLintMirror(t *testing.T) {
+	cat, err := NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat.HookSource = "quay.io/trustacks/catalog:test"
+	cat.AddComponent("mirrored", &testComponent{
+		&BaseComponent{
+			Repo:       "https://charts.test.com",
+			MirrorRepo: `{{ default "https://charts.test.com" .chartMirror }}`,
+			Values:     "image: {{ default \"quay.io\" .registryMirror }}/test/test:v1\ndb:\n  image:\n    registry: {{ default \"docker.io\" .registryMirror }}\n",
+			Hooks:      "image: {{ .registryMirror }}/trustacks/catalog:test\n",
+			Artifacts: &Artifacts{
+				Images: []string{"quay.io/test/test:v1"},
+			},
+		},
+	})
+	if err := cat.LintMirror("mirrored"); err != nil {
+		t.Fatal(err)
+	}
+	cat.AddComponent("escaped", &testComponent{
+		&BaseComponent{
+			Repo:   "https://charts.test.com",
+			Values: "image: quay.io/test/test\nother:\n  image:\n    repository: {{ .registryMirror }}/test/unlisted\n    registry: docker.io\n",
+		},
+	})
+	err = cat.LintMirror("escaped")
+	if err == nil {
+		t.Fatal("expected the escaped references to be reported")
+	}
+	for _, problem := range []string{
+		`chart repository "https://charts.test.com" escapes the chart mirror`,
+		`image "quay.io/test/test" escapes the registry mirror`,
+		`image "registry.mirror.invalid/test/unlisted" is not listed in the artifacts`,
+		`image registry "docker.io" escapes the registry mirror`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("expected '%s' in '%s'", problem, err)
+		}
+	}
+}
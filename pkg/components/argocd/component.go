@@ -8,10 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/sethvargo/go-password/password"
@@ -19,6 +19,11 @@ import (
 	"github.com/trustacks/catalog/pkg/functions"
 	"github.com/trustacks/catalog/pkg/hooks"
 	"github.com/trustacks/catalog/pkg/inputs"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/roles"
+	"github.com/trustacks/catalog/pkg/snapshots"
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +34,13 @@ import (
 const (
 	// componentName is the name of the component.
 	componentName = "argo-cd"
+	// callbackPath is the oidc redirect path of the argo cd server.
+	callbackPath = "/auth/callback"
+	// rbacConfigMap is the argo cd rbac policy config map.
+	rbacConfigMap = "argocd-rbac-cm"
+)
+
+var (
 	// inClusterNamespace is the path to the in-cluster namespace.
 	inClusterNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 	// serviceURL is the argo cd kubernetes service name.
@@ -39,13 +51,14 @@ type argocd struct {
 	catalog.BaseComponent
 }
 
+// snapshotSpec contains the resources managed by the component hooks.
+var snapshotSpec = snapshots.Spec{
+	Secrets: []string{"oidc-client"},
+}
+
 // preInstall creates the oidc client and secret.
 func (c *argocd) preInstall() error {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return err
-	}
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := newClientset()
 	if err != nil {
 		return err
 	}
@@ -53,25 +66,53 @@ func (c *argocd) preInstall() error {
 	if err != nil {
 		return err
 	}
+	metrics.Step("create-oidc-client")
 	provider := os.Getenv("SSO_PROVIDER")
 	clientId, clientSecret, err := createOIDCClient(provider)
 	if err != nil {
 		return err
 	}
+	metrics.Step("add-system-inputs")
 	systemVars := map[string]string{"server": "argo-cd-argocd-server"}
 	if err := inputs.AddSystemVars(componentName, namespace, systemVars, clientset); err != nil {
 		return err
 	}
-	return createOIDCClientSecret(clientId, clientSecret, namespace, clientset)
+	if err := createOIDCClientSecret(clientId, clientSecret, namespace, clientset); err != nil {
+		return err
+	}
+	return migrations.SetVersion(componentName, namespace, clientset)
 }
 
-// postInstall creates the ci service account.
+// postInstall binds the sso groups to the argo cd roles and creates the
+// ci service account.
 func (c *argocd) postInstall() error {
-	config, err := rest.InClusterConfig()
+	clientset, err := newClientset()
 	if err != nil {
 		return err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+	metrics.Step("health-check")
+	if err := healthCheckService(serviceURL, 2, ctx); err != nil {
+		return err
+	}
+	logging.Info("apply sso group role bindings")
+	metrics.Step("apply-role-bindings")
+	if err := applyRoleBindings(namespace, clientset); err != nil {
+		return err
+	}
+	logging.Info("set service account password")
+	metrics.Step("set-service-account-password")
+	return updateServiceAccountPassword(serviceURL, namespace, clientset)
+}
+
+// postDelete deletes the oidc client and removes the system inputs.
+func (c *argocd) postDelete() error {
+	clientset, err := newClientset()
 	if err != nil {
 		return err
 	}
@@ -79,22 +120,107 @@ func (c *argocd) postInstall() error {
 	if err != nil {
 		return err
 	}
-	adminPassword, err := getAdminPassword(namespace, clientset)
+	logging.Info("delete oidc client")
+	if err := deleteOIDCClient(os.Getenv("SSO_PROVIDER")); err != nil {
+		return err
+	}
+	if err := deleteOIDCClientSecret(namespace, clientset); err != nil {
+		return err
+	}
+	logging.Info("remove system inputs")
+	if err := inputs.RemoveSystemVars(componentName, namespace, clientset); err != nil {
+		return err
+	}
+	return inputs.RemoveSystemSecrets(componentName, namespace, clientset)
+}
+
+// policyRoles maps the toolchain roles to the argo cd roles.
+var policyRoles = map[roles.Role]string{
+	roles.Admin:  "role:admin",
+	roles.Editor: "role:editor",
+	roles.Viewer: "role:viewer",
+}
+
+// renderRoleBindings renders the sso group bindings of the toolchain
+// role model into the values template. The bindings use the [[ ]]
+// delimiters, so the catalog templates are kept.
+func renderRoleBindings(values string) (string, error) {
+	tmpl, err := template.New("values").Delims("[[", "]]").Parse(values)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]interface{}{"Bindings": roles.Bindings(policyRoles)}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// applyRoleBindings replaces the sso group bindings of the rbac policy
+// with the bindings of the toolchain role model. The other policy lines
+// are kept.
+func applyRoleBindings(namespace string, clientset kubernetes.Interface) error {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), rbacConfigMap, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	lines := []string{}
+	if policy := strings.TrimRight(configMap.Data["policy.csv"], "\n"); policy != "" {
+		for _, line := range strings.Split(policy, "\n") {
+			fields := strings.Split(line, ",")
+			if len(fields) == 3 && strings.TrimSpace(fields[0]) == "g" && roles.IsGroup(strings.TrimSpace(fields[1])) {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	for _, binding := range roles.Bindings(policyRoles) {
+		lines = append(lines, fmt.Sprintf("g, %s, %s", binding.Group, binding.Role))
+	}
+	configMap.Data["policy.csv"] = strings.Join(lines, "\n") + "\n"
+	_, err = clientset.CoreV1().ConfigMaps(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	return err
+}
+
+// rotateSecretsHandler rotates the system service account password.
+func rotateSecretsHandler(_ map[string]interface{}) (interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
 	defer cancel()
 	if err := healthCheckService(serviceURL, 2, ctx); err != nil {
+		return nil, err
+	}
+	logging.Info("rotate service account password")
+	return nil, updateServiceAccountPassword(serviceURL, namespace, clientset)
+}
+
+// updateServiceAccountPassword generates a new system service
+// account password and publishes it to the system secrets.
+func updateServiceAccountPassword(url, namespace string, clientset kubernetes.Interface) error {
+	adminPassword, err := getAdminPassword(namespace, clientset)
+	if err != nil {
 		return err
 	}
-	token, err := getAPISessionToken(serviceURL, adminPassword)
+	token, err := getAPISessionToken(url, adminPassword)
 	if err != nil {
 		return err
 	}
-	log.Println("set service account password")
-	pwd := password.MustGenerate(32, 10, 0, false, false)
-	if err := setServiceAccountPassword(serviceURL, token, adminPassword, pwd); err != nil {
+	pwd, err := password.Generate(32, 10, 0, false, false)
+	if err != nil {
+		return err
+	}
+	logging.AddSecret(pwd)
+	if err := setServiceAccountPassword(url, token, adminPassword, pwd); err != nil {
 		return err
 	}
 	systemSecrets := map[string][]byte{"password": []byte(pwd)}
@@ -103,7 +229,10 @@ func (c *argocd) postInstall() error {
 
 // createOIDCClient creates the concourse oidc client.
 func createOIDCClient(provider string) (string, string, error) {
-	params := []byte(fmt.Sprintf(`{"name": "%s", "provider": "%s"}`, componentName, provider))
+	params, err := functions.OIDCClientParams(componentName, provider, callbackPath)
+	if err != nil {
+		return "", "", err
+	}
 	result, err := functions.Call("create-oidc-client", params)
 	if err != nil {
 		return "", "", err
@@ -133,13 +262,39 @@ func createOIDCClientSecret(clientId, clientSecret, namespace string, clientset
 	return err
 }
 
+// updateOIDCClient updates the argo cd oidc client redirect uris.
+func updateOIDCClient(provider string) error {
+	params, err := functions.OIDCClientParams(componentName, provider, callbackPath)
+	if err != nil {
+		return err
+	}
+	_, err = functions.Call("update-oidc-client", params)
+	return err
+}
+
+// deleteOIDCClient deletes the argo cd oidc client.
+func deleteOIDCClient(provider string) error {
+	params := []byte(fmt.Sprintf(`{"name": "%s", "provider": "%s"}`, componentName, provider))
+	_, err := functions.Call("delete-oidc-client", params)
+	return err
+}
+
+// deleteOIDCClientSecret deletes the oidc client secret.
+func deleteOIDCClientSecret(namespace string, clientset kubernetes.Interface) error {
+	err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), "oidc-client", metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
+
 // healthCheckService checks the health of the argocd service.
 func healthCheckService(url string, interval int, ctx context.Context) error {
 	for {
 		select {
 		case <-time.After(time.Second * time.Duration(interval)):
 			if _, err := http.Get(url); err != nil {
-				log.Println(err)
+				logging.Debug("service health check failed", "error", err)
 				continue
 			}
 		case <-ctx.Done():
@@ -193,6 +348,122 @@ func setServiceAccountPassword(url, token, currentPassword, password string) err
 	return err
 }
 
+// preUpgrade snapshots the hook managed resources, updates the oidc
+// client redirect uris and runs the pending chart migrations.
+func (c *argocd) preUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	// the hook is rendered for the upgrade revision, so the current
+	// state belongs to the previous revision.
+	logging.Info("snapshot hook managed resources")
+	if err := snapshots.Create(componentName, revision-1, snapshotSpec, namespace, clientset); err != nil {
+		return err
+	}
+	logging.Info("update oidc client redirect uris")
+	if err := updateOIDCClient(os.Getenv("SSO_PROVIDER")); err != nil {
+		return err
+	}
+	_, err = migrations.Run(componentName, namespace, false, clientset)
+	return err
+}
+
+// postUpgrade reapplies the sso group role bindings, which are replaced
+// when the upgrade changes the chart rbac policy.
+func (c *argocd) postUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("apply sso group role bindings")
+	return applyRoleBindings(namespace, clientset)
+}
+
+// postRollback restores the hook managed resources of the revision
+// rolled back to, and reapplies the sso group role bindings replaced
+// by the chart rbac policy of that revision.
+func (c *argocd) postRollback() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	// rollbacks run the hooks rendered for the target revision.
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	logging.Info("restore hook managed resources")
+	if err := snapshots.Restore(componentName, revision, namespace, clientset); err != nil {
+		return err
+	}
+	logging.Info("apply sso group role bindings")
+	return applyRoleBindings(namespace, clientset)
+}
+
+// migrateHandler runs or lists the pending chart migrations.
+func migrateHandler(params map[string]interface{}) (interface{}, error) {
+	dryRun, _ := params["dryRun"].(bool)
+	return runMigrations(dryRun)
+}
+
+// RotateSecrets rotates the component credentials.
+func (c *argocd) RotateSecrets(params map[string]interface{}) (interface{}, error) {
+	return rotateSecretsHandler(params)
+}
+
+// Migrate runs or lists the pending chart migrations.
+func (c *argocd) Migrate(params map[string]interface{}) (interface{}, error) {
+	return migrateHandler(params)
+}
+
+// runMigrations runs the pending chart migrations and returns their
+// names.
+func runMigrations(dryRun bool) ([]string, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := migrations.Run(componentName, namespace, dryRun, clientset)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(pending))
+	for i, m := range pending {
+		names[i] = m.Name()
+	}
+	return names, nil
+}
+
+// newClientset creates the in-cluster kubernetes clientset.
+var newClientset = func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
 // getNamespace gets the current kubernetes namespace.
 func getNamespace() (string, error) {
 	data, err := os.ReadFile(inClusterNamespace)
@@ -212,25 +483,39 @@ var hookManifests []byte
 func Initialize(c *catalog.ComponentCatalog) {
 	var conf *catalog.ComponentConfig
 	if err := yaml.Unmarshal(config, &conf); err != nil {
-		log.Fatal(err)
+		logging.Fatal("error loading the component config", "error", err)
+	}
+	values, err := renderRoleBindings(conf.Values)
+	if err != nil {
+		logging.Fatal("error rendering the role bindings", "error", err)
 	}
 	component := &argocd{
 		catalog.BaseComponent{
-			Repo:    conf.Repo,
-			Chart:   conf.Chart,
-			Version: conf.Version,
-			Values:  conf.Values,
-			Hooks:   string(hookManifests),
+			Repo:       conf.Repo,
+			MirrorRepo: conf.MirrorRepository(),
+			Chart:      conf.Chart,
+			Version:    conf.Version,
+			Values:     values,
+			Hooks:      string(hookManifests),
+			Artifacts:  conf.Artifacts(),
 		},
 	}
 	c.AddComponent(componentName, component)
+	migrations.Register(componentName, conf.Version)
 
 	for hook, fn := range map[string]func() error{
 		hooks.PreInstallHook:  component.preInstall,
 		hooks.PostInstallHook: component.postInstall,
+		hooks.PostDeleteHook:  component.postDelete,
+		hooks.PreUpgrade:      component.preUpgrade,
+		hooks.PostUpgrade:     component.postUpgrade,
+		hooks.PostRollback:    component.postRollback,
 	} {
 		if err := hooks.AddHook(componentName, hook, fn); err != nil {
-			log.Fatal(err)
+			logging.Fatal("error adding the component hook", "hook", hook, "error", err)
 		}
 	}
+
+	// configure functions.
+	functions.RegisterComponent(componentName, component)
 }
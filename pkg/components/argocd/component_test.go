@@ -2,6 +2,7 @@ package argocd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/trustacks/catalog/pkg/catalog"
 	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,6 +46,7 @@ func TestCreateOIDCClient(t *testing.T) {
 	}
 	assert.Equal(t, componentName, p["name"], "got an unexpected provider")
 	assert.Equal(t, "test-provider", p["provider"], "got an unexpected provider")
+	assert.Equal(t, []interface{}{"/auth/callback"}, p["callbackPaths"], "got unexpected callback paths")
 	assert.Equal(t, "test-id", clientId, "got an unexpected client id")
 	assert.Equal(t, "test-secret", clientSecret, "got an unexpected client secret")
 }
@@ -62,6 +65,36 @@ func TestCreateOIDCClientSecret(t *testing.T) {
 	assert.Equal(t, "argocd", secret.Labels["app.kubernetes.io/part-of"], "got an unexpected part-of label")
 }
 
+func TestDeleteOIDCClient(t *testing.T) {
+	var p map[string]interface{}
+	defer functions.PatchMockFunction("delete-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		p = params
+		return nil, nil
+	})()
+	if err := deleteOIDCClient("test-provider"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, componentName, p["name"], "got an unexpected name")
+	assert.Equal(t, "test-provider", p["provider"], "got an unexpected provider")
+}
+
+func TestDeleteOIDCClientSecret(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	if err := createOIDCClientSecret("test-id", "test-secret", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteOIDCClientSecret("test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "oidc-client", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the oidc client secret to be deleted")
+	}
+	// check idempotence.
+	if err := deleteOIDCClientSecret("test", clientset); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestGetAdminPassword(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
 	secret := &corev1.Secret{
@@ -108,6 +141,83 @@ func TestSetSystemUserPassword(t *testing.T) {
 	}
 }
 
+func TestUpdateServiceAccountPassword(t *testing.T) {
+	var newPassword string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/session":
+			if _, err := w.Write([]byte(`{"token": "test-session-token"}`)); err != nil {
+				t.Fatal(err)
+			}
+		case "/api/v1/account/password":
+			body := map[string]string{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			newPassword = body["newPassword"]
+			if _, err := w.Write([]byte(`{}`)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}))
+	defer ts.Close()
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "argocd-initial-admin-secret",
+		},
+		Data: map[string][]byte{
+			"password": []byte("password123"),
+		},
+	}
+	if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateServiceAccountPassword(ts.URL, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	systemSecrets, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, newPassword, 32, "expected a 32 character password")
+	assert.Equal(t, newPassword, string(systemSecrets.Data["argo-cd.password"]), "got an unexpected system secret password")
+}
+
+func TestApplyRoleBindings(t *testing.T) {
+	policy := "p, role:editor, applications, *, */*, allow\n\ng, viewers, role:admin\ng, trustacks, role:editor\n"
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: rbacConfigMap, Namespace: "test"},
+		Data:       map[string]string{"policy.csv": policy},
+	})
+	expected := "p, role:editor, applications, *, */*, allow\n\ng, trustacks, role:editor\ng, admins, role:admin\ng, editors, role:editor\ng, viewers, role:viewer\n"
+	// the bindings are replaced, so applying them again has no effect.
+	for i := 0; i < 2; i++ {
+		if err := applyRoleBindings("test", clientset); err != nil {
+			t.Fatal(err)
+		}
+		configMap, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), rbacConfigMap, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, configMap.Data["policy.csv"], "got an unexpected policy")
+	}
+}
+
+func TestRenderRoleBindings(t *testing.T) {
+	var conf *catalog.ComponentConfig
+	if err := yaml.Unmarshal(config, &conf); err != nil {
+		t.Fatal(err)
+	}
+	values, err := renderRoleBindings(conf.Values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, values, "[[", "expected the bindings to be rendered")
+	assert.Contains(t, values, "\n        g, admins, role:admin\n        g, editors, role:editor\n        g, viewers, role:viewer\n        g, trustacks, role:editor\n", "expected the declared group bindings")
+}
+
 func TestHealthCheckService(t *testing.T) {
 	// test the health check with a malforned URL.
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
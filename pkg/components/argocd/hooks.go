@@ -0,0 +1,65 @@
+package argocd
+
+import (
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/inputs"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/snapshots"
+)
+
+// roleBindingRules are the permissions used to apply the sso group
+// role bindings to the rbac policy config map.
+var roleBindingRules = []hooks.Rule{
+	{Resources: []string{"configmaps"}, Verbs: []string{"get", "update"}},
+}
+
+// hookManifest declares the component hooks and their permissions.
+// hooks.yaml is generated from it.
+var hookManifest = hooks.Manifest{
+	Component: componentName,
+	Jobs: []hooks.Job{
+		{
+			Hook: hooks.PreInstallHook,
+			Env:  append([]hooks.EnvVar{hooks.SSOProviderEnv}, hooks.IngressEnv...),
+			Rules: hooks.JoinRules(
+				functions.SSOProviderRules(componentName),
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"create"}}},
+				inputs.AddSystemVarsRules,
+				migrations.Rules,
+			),
+		},
+		{
+			Hook: hooks.PostInstallHook,
+			Rules: hooks.JoinRules(
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+				roleBindingRules,
+				inputs.AddSystemSecretsRules,
+			),
+		},
+		{
+			Hook: hooks.PostDeleteHook,
+			Env:  []hooks.EnvVar{hooks.SSOProviderEnv},
+			Rules: hooks.JoinRules(
+				functions.SSOProviderRules(componentName),
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"delete"}}},
+				inputs.RemoveSystemVarsRules,
+				inputs.RemoveSystemSecretsRules,
+			),
+		},
+		{
+			Hook:  hooks.PreUpgrade,
+			Env:   append([]hooks.EnvVar{hooks.ReleaseRevisionEnv, hooks.SSOProviderEnv}, hooks.IngressEnv...),
+			Rules: hooks.JoinRules(functions.SSOProviderRules(componentName), snapshots.CreateRules, migrations.Rules),
+		},
+		{
+			Hook:  hooks.PostUpgrade,
+			Rules: roleBindingRules,
+		},
+		{
+			Hook:  hooks.PostRollback,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: hooks.JoinRules(snapshotSpec.RestoreRules(), roleBindingRules),
+		},
+	},
+}
@@ -0,0 +1,11 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/trustacks/catalog/pkg/hooks/hookstest"
+)
+
+func TestHookManifests(t *testing.T) {
+	hookstest.CheckManifest(t, hookManifest, "hooks.yaml")
+}
@@ -0,0 +1,143 @@
+package argocd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
+	"github.com/trustacks/catalog/pkg/rbac"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// initializeOnce adds the component hooks once per test binary.
+var initializeOnce sync.Once
+
+func TestHookRBAC(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/session" {
+			w.Write([]byte(`{"token": "test-session-token"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	previousServiceURL := serviceURL
+	serviceURL = ts.URL
+	defer func() { serviceURL = previousServiceURL }()
+
+	// patch the in cluster namespace file.
+	f, err := os.CreateTemp("", "in-cluster-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	previousInClusterNamespace := inClusterNamespace
+	inClusterNamespace = f.Name()
+	defer func() {
+		os.Remove(f.Name())
+		inClusterNamespace = previousInClusterNamespace
+	}()
+
+	// the initial admin secret and the rbac config map are created by
+	// the chart.
+	clientset := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "argocd-initial-admin-secret", Namespace: "test"},
+			Data:       map[string][]byte{"password": []byte("password123")},
+		},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: rbacConfigMap, Namespace: "test"}},
+	)
+	inputstest.AddApplyReactor(clientset)
+	previousNewClientset := newClientset
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	defer func() { newClientset = previousNewClientset }()
+
+	defer functions.PatchMockFunction("create-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"clientId": "test-id", "clientSecret": "test-secret"}, nil
+	})()
+	defer functions.PatchMockFunction("delete-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	})()
+	defer functions.PatchMockFunction("update-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	})()
+
+	initializeOnce.Do(func() {
+		cat, err := catalog.NewComponentCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		Initialize(cat)
+	})
+	manifests, err := rbac.RenderManifests(string(hookManifests), map[string]interface{}{"image": "test", "sso": "authentik"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the hooks through the release lifecycle.
+	var grants []rbac.Grant
+	accesses := []rbac.Access{}
+	for _, step := range []struct {
+		hook     string
+		revision string
+	}{
+		{hooks.PreInstallHook, "1"},
+		{hooks.PostInstallHook, "1"},
+		{hooks.PreUpgrade, "2"},
+		{hooks.PostUpgrade, "2"},
+		{hooks.PostRollback, "1"},
+		{hooks.PostDeleteHook, "3"},
+	} {
+		t.Setenv("RELEASE_REVISION", step.revision)
+		if step.hook == hooks.PostRollback {
+			// the rollback replaces the rbac policy with the one of the
+			// chart revision rolled back to.
+			if _, err := clientset.CoreV1().ConfigMaps("test").Update(context.TODO(), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: rbacConfigMap}}, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		hookAccesses, err := rbac.Record(clientset, func() error { return hooks.Call(componentName, step.hook) })
+		if err != nil {
+			t.Fatalf("%s: %s", step.hook, err)
+		}
+		if step.hook == hooks.PostRollback {
+			configMap, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), rbacConfigMap, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Contains(t, configMap.Data["policy.csv"], "g, admins, role:admin", "expected the rollback to reapply the role bindings")
+		}
+		grants, err = rbac.Grants(manifests, "test", componentName+"-"+step.hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := rbac.Verify(grants, hookAccesses)
+		assert.Empty(t, report.Missing, "%s is missing permissions:\n%s", step.hook, report)
+		accesses = append(accesses, hookAccesses...)
+	}
+
+	// every hook job shares the role. the sso provider functions are
+	// mocked, so the grants on the authentik secrets are unused.
+	report := rbac.Verify(grants, accesses)
+	unused := []rbac.Grant{}
+	for _, grant := range report.Unused {
+		if !strings.HasPrefix(grant.Name, "authentik-") {
+			unused = append(unused, grant)
+		}
+	}
+	assert.Empty(t, unused, "got unexpected unused grants:\n%s", report)
+}
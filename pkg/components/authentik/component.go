@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -18,6 +17,12 @@ import (
 	"github.com/trustacks/catalog/pkg/catalog"
 	"github.com/trustacks/catalog/pkg/functions"
 	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/roles"
+	"github.com/trustacks/catalog/pkg/rollout"
+	"github.com/trustacks/catalog/pkg/snapshots"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +33,9 @@ import (
 const (
 	// componentName is the name of the component.
 	componentName = "authentik"
+)
+
+var (
 	// inClusterNamespace is the path to the in-cluster namespace.
 	inClusterNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 	// serviceURL is the authentik kubernetes service name.
@@ -37,17 +45,22 @@ const (
 // apiTokenSecret is the secret where the api token is stored.
 var apiTokenSecret = "authentik-bootstrap"
 
+// bootstrapTokenIdentifier is the identifier of the token created
+// from the bootstrap token environment variable.
+const bootstrapTokenIdentifier = "authentik-bootstrap-token"
+
 type authentik struct {
 	catalog.BaseComponent
 }
 
+// snapshotSpec contains the resources managed by the component hooks.
+var snapshotSpec = snapshots.Spec{
+	Secrets: []string{apiTokenSecret},
+}
+
 // preInstall creates the authentik admin api token.
 func (c *authentik) preInstall() error {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return err
-	}
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := newClientset()
 	if err != nil {
 		return err
 	}
@@ -55,24 +68,26 @@ func (c *authentik) preInstall() error {
 	if err != nil {
 		return err
 	}
-	log.Println("create admin api token")
+	logging.Info("create admin api token")
 	res, err := password.Generate(32, 10, 0, false, false)
 	if err != nil {
 		return err
 	}
+	logging.AddSecret(res)
 	if err := createAPIToken(namespace, res, clientset); err != nil {
 		return err
 	}
-	return nil
+	return migrations.SetVersion(componentName, namespace, clientset)
 }
 
-// postInstall creates the authentik user groups.
+// postInstall creates the authentik user groups and applies the mfa
+// configuration of the toolchain logins.
 func (c *authentik) postInstall() error {
-	config, err := rest.InClusterConfig()
+	mfa, err := parseMFAConfig(os.Getenv("AUTHENTIK_MFA"), os.Getenv("AUTHENTIK_MFA_METHODS"))
 	if err != nil {
 		return err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := newClientset()
 	if err != nil {
 		return err
 	}
@@ -86,21 +101,60 @@ func (c *authentik) postInstall() error {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
 	defer cancel()
+	metrics.Step("health-check")
 	if err := healthCheckService(serviceURL, 2, ctx); err != nil {
 		return err
 	}
-	log.Println("create authentik user groups")
+	logging.Info("create authentik user groups")
+	metrics.Step("create-groups")
 	if err := createGroups(serviceURL, token); err != nil {
 		return err
 	}
-	return nil
+	logging.Info("apply the mfa configuration", "mfa", mfa.Mode)
+	metrics.Step("apply-mfa")
+	return applyMFA(mfa, serviceURL, token)
+}
+
+// postUpgrade applies the mfa configuration of the toolchain logins,
+// which may have changed with the upgrade parameters.
+func (c *authentik) postUpgrade() error {
+	mfa, err := parseMFAConfig(os.Getenv("AUTHENTIK_MFA"), os.Getenv("AUTHENTIK_MFA_METHODS"))
+	if err != nil {
+		return err
+	}
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	logging.Info("apply the mfa configuration", "mfa", mfa.Mode)
+	metrics.Step("apply-mfa")
+	return applyMFA(mfa, serviceURL, token)
+}
+
+// postDelete removes the api token secret so a reinstall bootstraps
+// a new token.
+func (c *authentik) postDelete() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("delete admin api token")
+	return deleteAPIToken(namespace, clientset)
 }
 
-// createAPIToken creates the api token secret.
+// createAPIToken creates the api token secret. The secret is part of
+// authentik, so that it is backed up with the component secrets.
 func createAPIToken(namespace, token string, clientset kubernetes.Interface) error {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: apiTokenSecret,
+			Labels: map[string]string{
+				"app.kubernetes.io/part-of": componentName,
+			},
 		},
 		Data: map[string][]byte{
 			"api-token": []byte(token),
@@ -120,6 +174,15 @@ func createAPIToken(namespace, token string, clientset kubernetes.Interface) err
 	return nil
 }
 
+// deleteAPIToken deletes the api token secret.
+func deleteAPIToken(namespace string, clientset kubernetes.Interface) error {
+	err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), apiTokenSecret, metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
+
 // getAPIToken gets the api token secret value.
 func getAPIToken(namespace string, clientset kubernetes.Interface) (string, error) {
 	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), apiTokenSecret, metav1.GetOptions{})
@@ -129,6 +192,84 @@ func getAPIToken(namespace string, clientset kubernetes.Interface) (string, erro
 	return strings.TrimSpace(string(secret.Data["api-token"])), nil
 }
 
+// connectInterval is the interval of the service health check in
+// seconds.
+var connectInterval = 2
+
+// connect waits for the authentik service and gets the api token.
+func connect() (string, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return "", err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+	if err := healthCheckService(serviceURL, connectInterval, ctx); err != nil {
+		return "", err
+	}
+	return getAPIToken(namespace, clientset)
+}
+
+// rotateSecretsHandler rotates the admin api token and restarts the
+// authentik workloads.
+func rotateSecretsHandler(_ map[string]interface{}) (interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+	if err := healthCheckService(serviceURL, 2, ctx); err != nil {
+		return nil, err
+	}
+	logging.Info("rotate admin api token")
+	if err := rotateAPIToken(serviceURL, namespace, clientset); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"authentik-server", "authentik-worker"} {
+		if err := rollout.RestartDeployment(namespace, name, clientset); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// rotateAPIToken sets a new key on the bootstrap api token and
+// updates the api token secret.
+func rotateAPIToken(url, namespace string, clientset kubernetes.Interface) error {
+	token, err := getAPIToken(namespace, clientset)
+	if err != nil {
+		return err
+	}
+	newToken, err := password.Generate(32, 10, 0, false, false)
+	if err != nil {
+		return err
+	}
+	logging.AddSecret(newToken)
+	data, err := json.Marshal(map[string]string{"key": newToken})
+	if err != nil {
+		return err
+	}
+	if _, err := postAPIResource(url, fmt.Sprintf("core/tokens/%s/set_key", bootstrapTokenIdentifier), token, data); err != nil {
+		return err
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), apiTokenSecret, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	secret.Data["api-token"] = []byte(newToken)
+	_, err = clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	return err
+}
+
 // group represents an authentik group.
 type group struct {
 	Name        string `json:"name"`
@@ -137,14 +278,16 @@ type group struct {
 	Parent      *int   `json:"parent"`
 }
 
-// createGroups creates the user groups.
+// createGroups creates the user groups of the toolchain role model.
+// The admins group members are superusers, and the bootstrap user is
+// added to it.
 func createGroups(url, token string) error {
-	groups := []group{
-		{"admins", []int{1}, true, nil},
-		{"editors", []int{}, false, nil},
-		{"viewers", []int{}, false, nil},
-	}
-	for _, g := range groups {
+	for _, r := range roles.Groups {
+		g := group{r.Name, []int{}, false, nil}
+		if r.Role == roles.Admin {
+			g.Users = []int{1}
+			g.IsSuperuser = true
+		}
 		data, err := json.Marshal(g)
 		if err != nil {
 			return err
@@ -218,6 +361,144 @@ func postAPIResource(url, resource, token string, data []byte) ([]byte, error) {
 	return body, nil
 }
 
+// patchAPIResource patches the API resource at the provided path.
+func patchAPIResource(url, resource, token string, data []byte) ([]byte, error) {
+	req, err := http.NewRequest("PATCH", fmt.Sprintf("%s/api/v3/%s/", url, resource), bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("'%s' patch error: %s", resource, body)
+	}
+	return body, nil
+}
+
+// deleteAPIResource deletes the API resource at the provided path.
+// Resources that do not exist are ignored.
+func deleteAPIResource(url, resource, token string) error {
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v3/%s/", url, resource), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("'%s' delete error: %s", resource, body)
+	}
+	return nil
+}
+
+// preUpgrade snapshots the hook managed resources and runs the
+// pending chart migrations.
+func (c *authentik) preUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	// the hook is rendered for the upgrade revision, so the current
+	// state belongs to the previous revision.
+	logging.Info("snapshot hook managed resources")
+	if err := snapshots.Create(componentName, revision-1, snapshotSpec, namespace, clientset); err != nil {
+		return err
+	}
+	_, err = migrations.Run(componentName, namespace, false, clientset)
+	return err
+}
+
+// postRollback restores the hook managed resources of the revision
+// rolled back to.
+func (c *authentik) postRollback() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	// rollbacks run the hooks rendered for the target revision.
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	logging.Info("restore hook managed resources")
+	return snapshots.Restore(componentName, revision, namespace, clientset)
+}
+
+// migrateHandler runs or lists the pending chart migrations.
+func migrateHandler(params map[string]interface{}) (interface{}, error) {
+	dryRun, _ := params["dryRun"].(bool)
+	return runMigrations(dryRun)
+}
+
+// RotateSecrets rotates the component credentials.
+func (c *authentik) RotateSecrets(params map[string]interface{}) (interface{}, error) {
+	return rotateSecretsHandler(params)
+}
+
+// Migrate runs or lists the pending chart migrations.
+func (c *authentik) Migrate(params map[string]interface{}) (interface{}, error) {
+	return migrateHandler(params)
+}
+
+// runMigrations runs the pending chart migrations and returns their
+// names.
+func runMigrations(dryRun bool) ([]string, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := migrations.Run(componentName, namespace, dryRun, clientset)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(pending))
+	for i, m := range pending {
+		names[i] = m.Name()
+	}
+	return names, nil
+}
+
+// newClientset creates the in-cluster kubernetes clientset.
+var newClientset = func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
 // getNamespace gets the current kubernetes namespace.
 func getNamespace() (string, error) {
 	data, err := os.ReadFile(inClusterNamespace)
@@ -233,7 +514,7 @@ func healthCheckService(url string, interval int, ctx context.Context) error {
 		select {
 		case <-time.After(time.Second * time.Duration(interval)):
 			if _, err := http.Get(url); err != nil {
-				log.Println(err)
+				logging.Debug("service health check failed", "error", err)
 				continue
 			}
 		case <-ctx.Done():
@@ -334,7 +615,7 @@ func getCertificateKeypair(url, token string) (string, error) {
 }
 
 // createOIDCProvier creates a new openid connection auth provider.
-func createOIDCProvider(name, url, token, flow, signingKey string, mappings []string) (int, string, string, error) {
+func createOIDCProvider(name, url, token, flow, signingKey string, mappings, redirectURIs []string) (int, string, string, error) {
 	client_id, err := password.Generate(40, 30, 0, false, true)
 	if err != nil {
 		return -1, "", "", err
@@ -343,6 +624,7 @@ func createOIDCProvider(name, url, token, flow, signingKey string, mappings []st
 	if err != nil {
 		return -1, "", "", err
 	}
+	logging.AddSecret(client_secret)
 	body := map[string]interface{}{
 		"name":               name,
 		"authorization_flow": flow,
@@ -351,6 +633,7 @@ func createOIDCProvider(name, url, token, flow, signingKey string, mappings []st
 		"client_secret":      client_secret,
 		"property_mappings":  mappings,
 		"signing_key":        signingKey,
+		"redirect_uris":      strings.Join(redirectURIs, "\n"),
 	}
 	data, err := json.Marshal(body)
 	if err != nil {
@@ -391,52 +674,296 @@ func createOIDCClientHandler(params map[string]interface{}) (interface{}, error)
 	if !ok {
 		return nil, errors.New("name is required")
 	}
-	return createOIDCClient(name)
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		return nil, err
+	}
+	rotate, _ := params["rotate"].(bool)
+	return createOIDCClient(name, redirectURIs, rotate)
 }
 
-// CreateOIDCClient creates a consumable end to end oidc client.
-func createOIDCClient(name string) (map[string]interface{}, error) {
-	config, err := rest.InClusterConfig()
+// CreateOIDCClient creates a consumable end to end oidc client. The
+// provider and application of an existing client are reused, and the
+// stored client credentials are returned. The client secret is
+// regenerated when rotate is set, or when no credentials are stored.
+func createOIDCClient(name string, redirectURIs []string, rotate bool) (map[string]interface{}, error) {
+	clientset, err := newClientset()
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	namespace, err := getNamespace()
 	if err != nil {
 		return nil, err
 	}
-	namespace, err := getNamespace()
+	token, err := connect()
 	if err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
-	defer cancel()
-	if err := healthCheckService(serviceURL, 2, ctx); err != nil {
+	oidcProvider, err := getOIDCProvider(name, serviceURL, token)
+	if err != nil {
 		return nil, err
 	}
-	token, err := getAPIToken(namespace, clientset)
+	var credentials *functions.OIDCClientCredentials
+	if oidcProvider == nil {
+		mappings, err := getPropertyMappings(serviceURL, token)
+		if err != nil {
+			return nil, err
+		}
+		signingKey, err := getCertificateKeypair(serviceURL, token)
+		if err != nil {
+			return nil, err
+		}
+		flow, err := getAuthorizationFlow(serviceURL, token)
+		if err != nil {
+			return nil, err
+		}
+		pk, id, secret, err := createOIDCProvider(name, serviceURL, token, flow, signingKey, mappings, redirectURIs)
+		if err != nil {
+			return nil, err
+		}
+		oidcProvider = &provider{PK: pk, Name: name, ClientID: id}
+		credentials = &functions.OIDCClientCredentials{ClientID: id, ClientSecret: secret}
+		// the credentials are stored before the application is created,
+		// so that a failed creation is resumed with the same
+		// credentials.
+		if err := functions.StoreProviderSecret("authentik", functions.OIDCClientKind, name, namespace, credentials.Data(), clientset); err != nil {
+			return nil, err
+		}
+	} else {
+		stored, err := functions.GetProviderSecret("authentik", functions.OIDCClientKind, name, namespace, clientset)
+		if err != nil {
+			return nil, err
+		}
+		credentials = functions.OIDCClientCredentialsFromData(stored)
+		if credentials == nil || rotate {
+			secret, err := password.Generate(128, 96, 0, false, true)
+			if err != nil {
+				return nil, err
+			}
+			logging.AddSecret(secret)
+			credentials = &functions.OIDCClientCredentials{ClientID: oidcProvider.ClientID, ClientSecret: secret}
+			// the credentials are stored before the provider is
+			// patched, so that a failed patch is resumed with the same
+			// credentials.
+			if err := functions.StoreProviderSecret("authentik", functions.OIDCClientKind, name, namespace, credentials.Data(), clientset); err != nil {
+				return nil, err
+			}
+		}
+		// the stored secret is always patched, so that the provider
+		// converges to the stored credentials.
+		data, err := json.Marshal(map[string]interface{}{
+			"redirect_uris": strings.Join(redirectURIs, "\n"),
+			"client_secret": credentials.ClientSecret,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := patchAPIResource(serviceURL, fmt.Sprintf("providers/oauth2/%d", oidcProvider.PK), token, data); err != nil {
+			return nil, err
+		}
+	}
+	exists, err := applicationExists(name, serviceURL, token)
 	if err != nil {
 		return nil, err
 	}
-	mappings, err := getPropertyMappings(serviceURL, token)
+	if !exists {
+		if err := createApplication(oidcProvider.PK, name, serviceURL, token); err != nil {
+			return nil, err
+		}
+	}
+	return credentials.Result(), nil
+}
+
+// applicationExists checks if the application with the slug exists.
+func applicationExists(slug, url, token string) (bool, error) {
+	resp, err := getAPIResource(url, "core/applications", token, fmt.Sprintf("slug=%s", slug))
+	if err != nil {
+		return false, err
+	}
+	results := struct {
+		Results []struct {
+			Slug string `json:"slug"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return false, err
+	}
+	for _, application := range results.Results {
+		if application.Slug == slug {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deleteApplication deletes the application.
+func deleteApplication(name, url, token string) error {
+	return deleteAPIResource(url, fmt.Sprintf("core/applications/%s", name), token)
+}
+
+type provider struct {
+	PK           int    `json:"pk"`
+	Name         string `json:"name"`
+	ClientID     string `json:"client_id"`
+	RedirectURIs string `json:"redirect_uris"`
+}
+
+type providers struct {
+	Results []provider `json:"results"`
+}
+
+// getOIDCProvider gets the openid connection auth provider with the
+// name. A nil provider is returned when it does not exist.
+func getOIDCProvider(name, url, token string) (*provider, error) {
+	resp, err := getAPIResource(url, "providers/oauth2", token, fmt.Sprintf("name=%s", name))
 	if err != nil {
 		return nil, err
 	}
-	signingKey, err := getCertificateKeypair(serviceURL, token)
+	p := &providers{}
+	if err := json.Unmarshal(resp, &p); err != nil {
+		return nil, err
+	}
+	for _, provider := range p.Results {
+		if provider.Name == name {
+			return &provider, nil
+		}
+	}
+	return nil, nil
+}
+
+func getOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	client, err := getOIDCClient(name)
+	if client == nil || err != nil {
+		// a nil map is not a nil result.
+		return nil, err
+	}
+	return client, nil
+}
+
+// getOIDCClient gets the stored credentials and the redirect uris of
+// the oidc client provider. A nil client is returned when the provider
+// does not exist.
+func getOIDCClient(name string) (map[string]interface{}, error) {
+	clientset, err := newClientset()
 	if err != nil {
 		return nil, err
 	}
-	flow, err := getAuthorizationFlow(serviceURL, token)
+	namespace, err := getNamespace()
 	if err != nil {
 		return nil, err
 	}
-	pk, id, secret, err := createOIDCProvider(name, serviceURL, token, flow, signingKey, mappings)
+	token, err := connect()
 	if err != nil {
 		return nil, err
 	}
-	if err := createApplication(pk, name, serviceURL, token); err != nil {
+	oidcProvider, err := getOIDCProvider(name, serviceURL, token)
+	if err != nil || oidcProvider == nil {
+		return nil, err
+	}
+	stored, err := functions.GetProviderSecret("authentik", functions.OIDCClientKind, name, namespace, clientset)
+	if err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{"clientId": id, "clientSecret": secret}, nil
+	credentials := functions.OIDCClientCredentialsFromData(stored)
+	if credentials == nil {
+		credentials = &functions.OIDCClientCredentials{ClientID: oidcProvider.ClientID}
+	}
+	client := credentials.Result()
+	client["redirectUris"] = []string{}
+	if oidcProvider.RedirectURIs != "" {
+		client["redirectUris"] = strings.Split(oidcProvider.RedirectURIs, "\n")
+	}
+	return client, nil
+}
+
+func updateOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		return nil, err
+	}
+	return nil, updateOIDCClient(name, redirectURIs)
+}
+
+// updateOIDCClient sets the redirect uris of the oidc client provider.
+func updateOIDCClient(name string, redirectURIs []string) error {
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	provider, err := getOIDCProvider(name, serviceURL, token)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return fmt.Errorf("oidc provider '%s' not found", name)
+	}
+	data, err := json.Marshal(map[string]string{"redirect_uris": strings.Join(redirectURIs, "\n")})
+	if err != nil {
+		return err
+	}
+	_, err = patchAPIResource(serviceURL, fmt.Sprintf("providers/oauth2/%d", provider.PK), token, data)
+	return err
+}
+
+// deleteOIDCProvider deletes the openid connection auth provider.
+func deleteOIDCProvider(name, url, token string) error {
+	resp, err := getAPIResource(url, "providers/oauth2", token, fmt.Sprintf("name=%s", name))
+	if err != nil {
+		return err
+	}
+	p := &providers{}
+	if err := json.Unmarshal(resp, &p); err != nil {
+		return err
+	}
+	for _, provider := range p.Results {
+		if provider.Name != name {
+			continue
+		}
+		if err := deleteAPIResource(url, fmt.Sprintf("providers/oauth2/%d", provider.PK), token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	return nil, deleteOIDCClient(name)
+}
+
+// deleteOIDCClient deletes the oidc client application, provider and
+// stored credentials.
+func deleteOIDCClient(name string) error {
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	if err := deleteApplication(name, serviceURL, token); err != nil {
+		return err
+	}
+	if err := deleteOIDCProvider(name, serviceURL, token); err != nil {
+		return err
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	return functions.DeleteProviderSecret("authentik", functions.OIDCClientKind, name, namespace, clientset)
 }
 
 //go:embed config.yaml
@@ -449,29 +976,37 @@ var hookManifests []byte
 func Initialize(c *catalog.ComponentCatalog) {
 	var conf *catalog.ComponentConfig
 	if err := yaml.Unmarshal(config, &conf); err != nil {
-		log.Fatal(err)
+		logging.Fatal("error loading the component config", "error", err)
 	}
 	component := &authentik{
 		catalog.BaseComponent{
-			Repo:    conf.Repo,
-			Chart:   conf.Chart,
-			Version: conf.Version,
-			Values:  conf.Values,
-			Hooks:   string(hookManifests),
+			Repo:       conf.Repo,
+			MirrorRepo: conf.MirrorRepository(),
+			Chart:      conf.Chart,
+			Version:    conf.Version,
+			Values:     conf.Values,
+			Hooks:      string(hookManifests),
+			Artifacts:  conf.Artifacts(),
 		},
 	}
 	c.AddComponent(componentName, component)
+	migrations.Register(componentName, conf.Version, componentMigrations...)
 
 	// configure hooks.
 	for hook, fn := range map[string]func() error{
 		hooks.PreInstallHook:  component.preInstall,
 		hooks.PostInstallHook: component.postInstall,
+		hooks.PostDeleteHook:  component.postDelete,
+		hooks.PreUpgrade:      component.preUpgrade,
+		hooks.PostUpgrade:     component.postUpgrade,
+		hooks.PostRollback:    component.postRollback,
 	} {
 		if err := hooks.AddHook(componentName, hook, fn); err != nil {
-			log.Fatal(err)
+			logging.Fatal("error adding the component hook", "hook", hook, "error", err)
 		}
 	}
 
 	// configure functions.
-	functions.AddCreateOIDCClientHandler("authentik", createOIDCClientHandler)
+	functions.RegisterSSOProvider(componentName, component)
+	functions.RegisterComponent(componentName, component)
 }
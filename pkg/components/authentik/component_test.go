@@ -13,6 +13,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/functions/providertest"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -115,7 +117,11 @@ func TestGetSigningKey(t *testing.T) {
 }
 
 func TestCreateOIDCProvider(t *testing.T) {
+	body := map[string]interface{}{}
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
 		if _, err := w.Write([]byte(`{"pk": 123}`)); err != nil {
 			t.Fatal(err)
 		}
@@ -128,13 +134,119 @@ func TestCreateOIDCProvider(t *testing.T) {
 	}
 	flow := "c53f70da-aa78-42c1-950a-f0c7e7e324a1"
 	signingKey := "62b33e8b-033b-4dc7-9580-0de0a3f457e6"
-	pk, id, secret, err := createOIDCProvider("test", ts.URL, "test-token", flow, signingKey, mappings)
+	redirectURIs := []string{"https://test.local.gd/callback", "https://test.local.gd/other"}
+	pk, id, secret, err := createOIDCProvider("test", ts.URL, "test-token", flow, signingKey, mappings, redirectURIs)
 	if err != nil {
 		t.Fatal(err)
 	}
 	assert.Len(t, id, 40, "expected a 40 character id")
 	assert.Len(t, secret, 128, "expected a 128 character secret")
 	assert.Equal(t, 123, pk, "got an unexpected provider pk")
+	assert.Equal(t, "https://test.local.gd/callback\nhttps://test.local.gd/other", body["redirect_uris"], "expected newline separated redirect uris")
+}
+
+func TestCreateOIDCClient(t *testing.T) {
+	a := newFakeAuthentik(t)
+	clientset := patchEnvironment(t, a)
+
+	params := map[string]interface{}{"name": "concourse"}
+	result, err := createOIDCClientHandler(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := result.(map[string]interface{})
+	assert.Len(t, a.providers, 1, "expected the provider to be created")
+	assert.Equal(t, a.providers[0].PK, a.applications["concourse"], "expected the application to be created")
+	assert.Equal(t, a.providers[0].ClientSecret, credentials["clientSecret"])
+	stored, err := functions.GetProviderSecret("authentik", functions.OIDCClientKind, "concourse", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, credentials, functions.OIDCClientCredentialsFromData(stored).Result(), "expected the credentials to be stored")
+
+	// an existing client is reused with the stored credentials.
+	result, err = createOIDCClientHandler(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, credentials, result, "expected the stored credentials")
+	assert.Len(t, a.providers, 1, "expected the provider to be reused")
+	assert.Len(t, a.applications, 1, "expected the application to be reused")
+
+	// rotation regenerates the secret of the provider in place.
+	params["rotate"] = true
+	result, err = createOIDCClientHandler(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := result.(map[string]interface{})
+	assert.Equal(t, credentials["clientId"], rotated["clientId"], "expected the client id to be kept")
+	assert.NotEqual(t, credentials["clientSecret"], rotated["clientSecret"], "expected the client secret to be rotated")
+	assert.Equal(t, rotated["clientSecret"], a.providers[0].ClientSecret)
+	assert.Len(t, a.providers, 1)
+
+	// a provider that missed the stored secret, such as after a failed
+	// patch, converges to the stored credentials.
+	a.providers[0].ClientSecret = "stale"
+	result, err = createOIDCClientHandler(map[string]interface{}{"name": "concourse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rotated, result, "expected the stored credentials")
+	assert.Equal(t, rotated["clientSecret"], a.providers[0].ClientSecret, "expected the stored secret to be patched")
+
+	// a provider without an application, or without stored
+	// credentials, is completed.
+	delete(a.applications, "concourse")
+	if err := functions.DeleteProviderSecret("authentik", functions.OIDCClientKind, "concourse", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	result, err = createOIDCClientHandler(map[string]interface{}{"name": "concourse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, a.providers[0].ClientSecret, result.(map[string]interface{})["clientSecret"])
+	assert.Equal(t, a.providers[0].PK, a.applications["concourse"], "expected the application to be created")
+
+	if _, err := deleteOIDCClientHandler(map[string]interface{}{"name": "concourse"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, a.providers, "expected the provider to be deleted")
+	assert.Empty(t, a.applications, "expected the application to be deleted")
+	stored, err = functions.GetProviderSecret("authentik", functions.OIDCClientKind, "concourse", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, stored, "expected the stored credentials to be deleted")
+}
+
+func TestSSOProviderContract(t *testing.T) {
+	a := newFakeAuthentik(t)
+	clientset := patchEnvironment(t, a)
+	providertest.SSOProviderRBAC(t, &authentik{}, componentName, "test", clientset.(*fake.Clientset))
+}
+
+func TestUpdateOIDCClient(t *testing.T) {
+	a := newFakeAuthentik(t)
+	patchEnvironment(t, a)
+	a.providers = []*fakeProvider{{PK: 1, Name: "concourse"}}
+
+	params := map[string]interface{}{
+		"name":          "concourse",
+		"callbackPaths": []interface{}{"/sky/issuer/callback"},
+		"domain":        "local.gd",
+		"tls":           "true",
+		"ingressPort":   "443",
+	}
+	if _, err := updateOIDCClientHandler(params); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://concourse.local.gd/sky/issuer/callback", a.providers[0].RedirectURIs)
+
+	params["name"] = "missing"
+	if _, err := updateOIDCClientHandler(params); err == nil {
+		t.Fatal("expected a missing provider error")
+	}
 }
 
 func TestCreateApplication(t *testing.T) {
@@ -149,6 +261,42 @@ func TestCreateApplication(t *testing.T) {
 	}
 }
 
+func TestDeleteApplication(t *testing.T) {
+	var method, path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+	// missing applications are ignored.
+	if err := deleteApplication("test", ts.URL, "test-token"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "DELETE", method, "got an unexpected request method")
+	assert.Equal(t, "/api/v3/core/applications/test/", path, "got an unexpected request path")
+}
+
+func TestDeleteOIDCProvider(t *testing.T) {
+	deleted := make([]string, 0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			assert.Equal(t, "test", r.URL.Query().Get("name"), "got an unexpected provider search")
+			if _, err := w.Write([]byte(`{"results": [{"pk": 1, "name": "test"}, {"pk": 2, "name": "test2"}]}`)); err != nil {
+				t.Fatal(err)
+			}
+		case "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+	if err := deleteOIDCProvider("test", ts.URL, "test-token"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"/api/v3/providers/oauth2/1/"}, deleted, "got unexpected deleted providers")
+}
+
 func TestCreateAPIToken(t *testing.T) {
 	defer patchAPIToken()()
 	clientset := fake.NewSimpleClientset()
@@ -161,6 +309,7 @@ func TestCreateAPIToken(t *testing.T) {
 		t.Fatal(err)
 	}
 	assert.Equal(t, "test-token", strings.TrimSpace(string(secret.Data["api-token"])), "got an unexpected token value")
+	assert.Equal(t, componentName, secret.Labels["app.kubernetes.io/part-of"], "expected the secret to be part of authentik")
 
 	// check idempotence.
 	if err := createAPIToken(namespace, "test-token", clientset); err != nil {
@@ -168,6 +317,58 @@ func TestCreateAPIToken(t *testing.T) {
 	}
 }
 
+func TestDeleteAPIToken(t *testing.T) {
+	defer patchAPIToken()()
+	clientset := fake.NewSimpleClientset()
+	namespace := "test"
+	if err := createAPIToken(namespace, "test-token", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteAPIToken(namespace, clientset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getAPIToken(namespace, clientset); err == nil {
+		t.Fatal("expected the api token secret to be deleted")
+	}
+	// check idempotence.
+	if err := deleteAPIToken(namespace, clientset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateAPIToken(t *testing.T) {
+	defer patchAPIToken()()
+	var path, authorization, key string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		key = body["key"]
+		if _, err := w.Write([]byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+	clientset := fake.NewSimpleClientset()
+	if err := createAPIToken("test", "test-token", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := rotateAPIToken(ts.URL, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	token, err := getAPIToken("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/api/v3/core/tokens/authentik-bootstrap-token/set_key/", path, "got an unexpected api path")
+	assert.Equal(t, "Bearer test-token", authorization, "expected the previous token to authorize the rotation")
+	assert.Len(t, key, 32, "expected a 32 character token")
+	assert.Equal(t, key, token, "got an unexpected api token secret value")
+}
+
 func TestCreateGroups(t *testing.T) {
 	getGroups := make([]string, 0)
 	postGroups := make([]string, 0)
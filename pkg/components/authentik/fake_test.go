@@ -0,0 +1,470 @@
+package authentik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeGroup is a group of the fake authentik api.
+type fakeGroup struct {
+	PK   string `json:"pk"`
+	Name string `json:"name"`
+}
+
+// fakeProvider is an oauth2 provider of the fake authentik api.
+type fakeProvider struct {
+	PK           int    `json:"pk"`
+	Name         string `json:"name"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURIs string `json:"redirect_uris"`
+}
+
+// fakeAuthentik is an in-memory stand-in for the authentik api.
+type fakeAuthentik struct {
+	*httptest.Server
+	token        string
+	nextPK       int
+	users        []*user
+	groups       []fakeGroup
+	emailStages  []fakeGroup
+	recoveryFlow string
+	providers    []*fakeProvider
+	// applications maps the application slugs to the provider
+	// primary keys.
+	applications map[string]int
+	// recoveryEmails are the user primary keys that a recovery email
+	// was sent to.
+	recoveryEmails []int
+	// passwords maps the user primary keys to the passwords that were
+	// set.
+	passwords map[int]string
+	// objects are the flows, stages, flow stage bindings, tenants, ldap
+	// providers and outposts, by api resource.
+	objects map[string][]map[string]interface{}
+}
+
+// newFakeAuthentik starts the fake authentik api with the sso groups
+// and a recovery flow.
+func newFakeAuthentik(t *testing.T) *fakeAuthentik {
+	a := &fakeAuthentik{
+		token:        "test-token",
+		nextPK:       1,
+		recoveryFlow: "recovery-flow",
+		groups: []fakeGroup{
+			{"group-admins", "admins"},
+			{"group-editors", "editors"},
+			{"group-viewers", "viewers"},
+		},
+		emailStages:  []fakeGroup{{"stage-email", "default-recovery-email"}},
+		applications: map[string]int{},
+		passwords:    map[int]string{},
+		objects: map[string][]map[string]interface{}{
+			"flows/instances": {
+				{"pk": "flow-authorization", "slug": "default-provider-authorization-explicit-consent"},
+				{"pk": "flow-authentication", "slug": "default-authentication-flow"},
+			},
+			"flows/bindings": {},
+			"stages/all": {
+				{"pk": "stage-identification", "name": "default-authentication-identification"},
+				{"pk": "stage-password", "name": "default-authentication-password"},
+				{"pk": "stage-login", "name": "default-authentication-login"},
+			},
+			"stages/authenticator/totp":     {},
+			"stages/authenticator/webauthn": {},
+			"stages/authenticator/validate": {},
+			"core/tenants": {
+				{"tenant_uuid": "tenant-default", "default": true, "flow_authentication": "flow-authentication"},
+			},
+			"providers/ldap":     {},
+			"providers/saml":     {},
+			"outposts/instances": {},
+			"outposts/service_connections/kubernetes": {
+				{"pk": "connection-local", "name": "Local Kubernetes Cluster", "local": true},
+			},
+		},
+	}
+	a.Server = httptest.NewServer(http.HandlerFunc(a.serveHTTP))
+	t.Cleanup(a.Close)
+	return a
+}
+
+// writeResults writes a paginated list response.
+func writeResults(w http.ResponseWriter, results interface{}) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+func (a *fakeAuthentik) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+a.token {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/"), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "core/tenants/current":
+		json.NewEncoder(w).Encode(map[string]interface{}{"flow_recovery": a.recoveryFlow})
+	case path == "core/groups" && r.Method == http.MethodGet:
+		results := []fakeGroup{}
+		for _, g := range a.groups {
+			if g.Name == r.URL.Query().Get("name") {
+				results = append(results, g)
+			}
+		}
+		writeResults(w, results)
+	case path == "core/groups" && r.Method == http.MethodPost:
+		g := fakeGroup{}
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil || g.Name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		g.PK = fmt.Sprintf("group-%d", a.nextPK)
+		a.nextPK++
+		a.groups = append(a.groups, g)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(g)
+	case len(parts) == 3 && parts[0] == "core" && parts[1] == "groups" && r.Method == http.MethodDelete:
+		for i, g := range a.groups {
+			if g.PK == parts[2] {
+				a.groups = append(a.groups[:i], a.groups[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case path == "stages/email" && r.Method == http.MethodGet:
+		results := []fakeGroup{}
+		for _, s := range a.emailStages {
+			if s.Name == r.URL.Query().Get("name") {
+				results = append(results, s)
+			}
+		}
+		writeResults(w, results)
+	case path == "propertymappings/all":
+		writeResults(w, []map[string]string{
+			{"pk": "mapping-email", "managed": "goauthentik.io/providers/oauth2/scope-email"},
+			{"pk": "mapping-openid", "managed": "goauthentik.io/providers/oauth2/scope-openid"},
+			{"pk": "mapping-profile", "managed": "goauthentik.io/providers/oauth2/scope-profile"},
+			{"pk": "mapping-saml-email", "managed": "goauthentik.io/providers/saml/email"},
+			{"pk": "mapping-saml-name", "managed": "goauthentik.io/providers/saml/name"},
+			{"pk": "mapping-saml-username", "managed": "goauthentik.io/providers/saml/username"},
+			{"pk": "mapping-saml-groups", "managed": "goauthentik.io/providers/saml/groups"},
+		})
+	case path == "crypto/certificatekeypairs":
+		writeResults(w, []map[string]string{{"pk": "keypair", "name": "authentik Self-signed Certificate"}})
+	case path == "providers/oauth2" && r.Method == http.MethodPost:
+		p := &fakeProvider{}
+		if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p.PK = a.nextPK
+		a.nextPK++
+		a.providers = append(a.providers, p)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	case len(parts) == 3 && parts[0] == "providers" && parts[1] == "oauth2" && r.Method == http.MethodDelete:
+		pk, _ := strconv.Atoi(parts[2])
+		for i, p := range a.providers {
+			if p.PK == pk {
+				a.providers = append(a.providers[:i], a.providers[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case path == "core/applications" && r.Method == http.MethodGet:
+		results := []map[string]interface{}{}
+		if pk, ok := a.applications[r.URL.Query().Get("slug")]; ok {
+			results = append(results, map[string]interface{}{"slug": r.URL.Query().Get("slug"), "provider": pk})
+		}
+		writeResults(w, results)
+	case path == "core/applications" && r.Method == http.MethodPost:
+		application := struct {
+			Slug     string `json:"slug"`
+			Provider int    `json:"provider"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&application); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, ok := a.applications[application.Slug]; ok {
+			// authentik rejects duplicate slugs.
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		a.applications[application.Slug] = application.Provider
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) == 3 && parts[0] == "core" && parts[1] == "applications" && r.Method == http.MethodDelete:
+		if _, ok := a.applications[parts[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(a.applications, parts[2])
+		w.WriteHeader(http.StatusNoContent)
+	case path == "providers/oauth2" && r.Method == http.MethodGet:
+		results := []*fakeProvider{}
+		for _, p := range a.providers {
+			if p.Name == r.URL.Query().Get("name") {
+				results = append(results, p)
+			}
+		}
+		writeResults(w, results)
+	case len(parts) == 3 && parts[0] == "providers" && parts[1] == "oauth2" && r.Method == http.MethodPatch:
+		pk, _ := strconv.Atoi(parts[2])
+		for _, p := range a.providers {
+			if p.PK == pk {
+				if err := json.NewDecoder(r.Body).Decode(p); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				json.NewEncoder(w).Encode(p)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case path == "core/users" && r.Method == http.MethodGet:
+		results := []*user{}
+		for _, u := range a.users {
+			// the username filter is exact.
+			if u.Username == r.URL.Query().Get("username") {
+				results = append(results, u)
+			}
+		}
+		writeResults(w, results)
+	case path == "core/users" && r.Method == http.MethodPost:
+		u := &user{}
+		if err := json.NewDecoder(r.Body).Decode(u); err != nil || u.Username == "" || u.Name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		u.PK = a.nextPK
+		a.nextPK++
+		a.users = append(a.users, u)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(u)
+	case len(parts) >= 3 && parts[0] == "core" && parts[1] == "users":
+		pk, _ := strconv.Atoi(parts[2])
+		index := -1
+		for i, u := range a.users {
+			if u.PK == pk {
+				index = i
+			}
+		}
+		if index < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		u := a.users[index]
+		switch {
+		case len(parts) == 4 && parts[3] == "recovery":
+			if a.recoveryFlow == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"link": fmt.Sprintf("%s/recovery/%d", a.URL, pk)})
+		case len(parts) == 4 && parts[3] == "recovery_email":
+			if r.URL.Query().Get("email_stage") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			a.recoveryEmails = append(a.recoveryEmails, pk)
+			w.WriteHeader(http.StatusNoContent)
+		case len(parts) == 4 && parts[3] == "set_password" && r.Method == http.MethodPost:
+			body := map[string]string{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["password"] == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			a.passwords[pk] = body["password"]
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPatch:
+			patch := map[string][]string{}
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			u.Groups = patch["groups"]
+			json.NewEncoder(w).Encode(u)
+		case r.Method == http.MethodDelete:
+			a.users = append(a.users[:index], a.users[index+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 4 && parts[0] == "providers" && parts[1] == "saml" && parts[3] == "metadata":
+		for _, p := range a.objects["providers/saml"] {
+			if fmt.Sprint(p["pk"]) == parts[2] {
+				json.NewEncoder(w).Encode(map[string]string{"metadata": fakeSAMLMetadata(a.URL, parts[2])})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		if resource, id, ok := a.objectResource(path); ok {
+			a.serveObjects(w, r, resource, id)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// fakeSAMLCertificate is the base64 der body of the fake saml signing
+// certificate.
+const fakeSAMLCertificate = "ZmFrZS1jZXJ0aWZpY2F0ZQ=="
+
+// fakeSAMLMetadata returns the identity provider metadata of the saml
+// provider.
+func fakeSAMLMetadata(url, provider string) string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="authentik">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo>
+        <ds:X509Data>
+          <ds:X509Certificate>%s</ds:X509Certificate>
+        </ds:X509Data>
+      </ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="%[2]s/application/saml/%[3]s/sso/binding/post/"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%[2]s/application/saml/%[3]s/sso/binding/redirect/"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, fakeSAMLCertificate, url, provider)
+}
+
+// objectResource returns the object api resource of the path, and the
+// object id when the path is an object.
+func (a *fakeAuthentik) objectResource(path string) (string, string, bool) {
+	for resource := range a.objects {
+		if path == resource {
+			return resource, "", true
+		}
+		if strings.HasPrefix(path, resource+"/") {
+			return resource, strings.TrimPrefix(path, resource+"/"), true
+		}
+	}
+	return "", "", false
+}
+
+// serveObjects lists the objects filtered by the query fields, creates
+// objects, and patches and deletes the objects by primary key, slug or
+// tenant uuid. Objects with the name or slug of an existing object are
+// rejected. The ldap and saml providers have integer primary keys.
+func (a *fakeAuthentik) serveObjects(w http.ResponseWriter, r *http.Request, resource, id string) {
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		results := []map[string]interface{}{}
+		for _, object := range a.objects[resource] {
+			match := true
+			for field := range r.URL.Query() {
+				if fmt.Sprint(object[field]) != r.URL.Query().Get(field) {
+					match = false
+				}
+			}
+			if match {
+				results = append(results, object)
+			}
+		}
+		writeResults(w, results)
+	case id == "" && r.Method == http.MethodPost:
+		object := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&object); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, existing := range a.objects[resource] {
+			for _, field := range []string{"name", "slug"} {
+				if object[field] != nil && existing[field] == object[field] {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		object["pk"] = fmt.Sprintf("%s-%d", resource[strings.LastIndex(resource, "/")+1:], a.nextPK)
+		if strings.HasPrefix(resource, "providers/") {
+			object["pk"] = a.nextPK
+		}
+		a.nextPK++
+		a.objects[resource] = append(a.objects[resource], object)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(object)
+	case id != "" && r.Method == http.MethodPatch:
+		for _, object := range a.objects[resource] {
+			if fmt.Sprint(object["pk"]) == id || object["slug"] == id || object["tenant_uuid"] == id {
+				if err := json.NewDecoder(r.Body).Decode(&object); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				json.NewEncoder(w).Encode(object)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case id != "" && r.Method == http.MethodDelete:
+		for i, object := range a.objects[resource] {
+			if fmt.Sprint(object["pk"]) == id || object["slug"] == id {
+				a.objects[resource] = append(a.objects[resource][:i], a.objects[resource][i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// patchEnvironment patches the in cluster namespace, the clientset and
+// the service url, and creates the api token secret. The clientset
+// supports the system inputs apply. The service health
+// check is not delayed.
+func patchEnvironment(t *testing.T, a *fakeAuthentik) kubernetes.Interface {
+	f, err := os.CreateTemp("", "in-cluster-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: apiTokenSecret},
+		Data:       map[string][]byte{"api-token": []byte(a.token)},
+	}
+	if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	previousInClusterNamespace := inClusterNamespace
+	previousNewClientset := newClientset
+	previousServiceURL := serviceURL
+	previousConnectInterval := connectInterval
+	inClusterNamespace = f.Name()
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	serviceURL = a.URL
+	connectInterval = 0
+	t.Cleanup(func() {
+		os.Remove(f.Name())
+		inClusterNamespace = previousInClusterNamespace
+		newClientset = previousNewClientset
+		serviceURL = previousServiceURL
+		connectInterval = previousConnectInterval
+	})
+	return clientset
+}
@@ -0,0 +1,59 @@
+package authentik
+
+import (
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/snapshots"
+)
+
+// serviceURLEnv passes the authentik service url to the hook.
+var serviceURLEnv = hooks.EnvVar{
+	Name:  "SERVICE_URL",
+	Value: "\"{{`{{- if eq .tls true -}}https{{- else -}}http{{- end -}}://authentik`}}\"",
+}
+
+// mfaEnv passes the mfa mode and methods to the hook. The values are
+// quoted, so that yaml does not read off as a boolean.
+var mfaEnv = []hooks.EnvVar{
+	{Name: "AUTHENTIK_MFA", Value: "\"{{ .authentikMFA }}\""},
+	{Name: "AUTHENTIK_MFA_METHODS", Value: "\"{{ .authentikMFAMethods }}\""},
+}
+
+// hookManifest declares the component hooks and their permissions.
+// hooks.yaml is generated from it.
+var hookManifest = hooks.Manifest{
+	Component: componentName,
+	Jobs: []hooks.Job{
+		{
+			Hook: hooks.PreInstallHook,
+			Rules: hooks.JoinRules(
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get", "create"}}},
+				migrations.Rules,
+			),
+		},
+		{
+			Hook:  hooks.PostInstallHook,
+			Env:   append([]hooks.EnvVar{serviceURLEnv}, mfaEnv...),
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		{
+			Hook:  hooks.PostDeleteHook,
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"delete"}}},
+		},
+		{
+			Hook:  hooks.PreUpgrade,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: hooks.JoinRules(snapshots.CreateRules, migrations.Rules),
+		},
+		{
+			Hook:  hooks.PostUpgrade,
+			Env:   append([]hooks.EnvVar{serviceURLEnv}, mfaEnv...),
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		{
+			Hook:  hooks.PostRollback,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: snapshotSpec.RestoreRules(),
+		},
+	},
+}
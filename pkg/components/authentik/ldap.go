@@ -0,0 +1,433 @@
+package authentik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sethvargo/go-password/password"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/logging"
+)
+
+const (
+	// ldapOutpost is the name of the outpost that serves the ldap
+	// providers.
+	ldapOutpost = "trustacks-ldap"
+	// ldapHost is the kubernetes service of the ldap outpost, which is
+	// deployed by the authentik outpost controller.
+	ldapHost = "ak-outpost-" + ldapOutpost
+	// bindPasswordKey is the ldap client secret key of the bind
+	// password.
+	bindPasswordKey = "bind-password"
+)
+
+// ldapBaseDN returns the base dn of the ldap client.
+func ldapBaseDN(name string) string {
+	return fmt.Sprintf("dc=%s,dc=trustacks,dc=io", name)
+}
+
+// ldapBindUsername returns the username of the ldap client bind
+// account.
+func ldapBindUsername(name string) string {
+	return fmt.Sprintf("ldap-%s", name)
+}
+
+// ldapSearchGroup returns the name of the group whose members can
+// search the ldap client directory.
+func ldapSearchGroup(name string) string {
+	return fmt.Sprintf("ldap-%s-search", name)
+}
+
+// ldapApplication returns the slug of the ldap client application,
+// which does not collide with the oidc client application.
+func ldapApplication(name string) string {
+	return fmt.Sprintf("%s-ldap", name)
+}
+
+// providerRef is the primary key and name of an authentik provider.
+type providerRef struct {
+	PK   int    `json:"pk"`
+	Name string `json:"name"`
+}
+
+// getProviderRef gets the provider of the api resource with the name. A
+// nil provider is returned when it does not exist.
+func getProviderRef(resource, name, url, token string) (*providerRef, error) {
+	resp, err := getAPIResource(url, resource, token, fmt.Sprintf("name=%s", name))
+	if err != nil {
+		return nil, err
+	}
+	results := struct {
+		Results []providerRef `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return nil, err
+	}
+	for _, p := range results.Results {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+// outpost is an authentik outpost.
+type outpost struct {
+	PK        string `json:"pk"`
+	Name      string `json:"name"`
+	Providers []int  `json:"providers"`
+}
+
+// getOutpost gets the outpost with the name. A nil outpost is returned
+// when it does not exist.
+func getOutpost(name, url, token string) (*outpost, error) {
+	resp, err := getAPIResource(url, "outposts/instances", token, fmt.Sprintf("name=%s", name))
+	if err != nil {
+		return nil, err
+	}
+	results := struct {
+		Results []outpost `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return nil, err
+	}
+	for _, o := range results.Results {
+		if o.Name == name {
+			return &o, nil
+		}
+	}
+	return nil, nil
+}
+
+// getLocalServiceConnection gets the primary key of the local
+// kubernetes service connection, which deploys the outposts in the
+// authentik cluster.
+func getLocalServiceConnection(url, token string) (string, error) {
+	resp, err := getAPIResource(url, "outposts/service_connections/kubernetes", token, "local=true")
+	if err != nil {
+		return "", err
+	}
+	results := struct {
+		Results []struct {
+			PK    string `json:"pk"`
+			Local bool   `json:"local"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return "", err
+	}
+	for _, connection := range results.Results {
+		if connection.Local {
+			return connection.PK, nil
+		}
+	}
+	return "", errors.New("local kubernetes service connection not found")
+}
+
+// addOutpostProvider adds the ldap provider to the ldap outpost, and
+// creates the outpost when it does not exist.
+func addOutpostProvider(provider int, namespace, url, token string) error {
+	o, err := getOutpost(ldapOutpost, url, token)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		connection, err := getLocalServiceConnection(url, token)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"name":               ldapOutpost,
+			"type":               "ldap",
+			"providers":          []int{provider},
+			"service_connection": connection,
+			"config": map[string]interface{}{
+				"authentik_host":       serviceURL,
+				"kubernetes_namespace": namespace,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = postAPIResource(url, "outposts/instances", token, data)
+		return err
+	}
+	for _, pk := range o.Providers {
+		if pk == provider {
+			return nil
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"providers": append(o.Providers, provider)})
+	if err != nil {
+		return err
+	}
+	_, err = patchAPIResource(url, fmt.Sprintf("outposts/instances/%s", o.PK), token, data)
+	return err
+}
+
+// removeOutpostProvider removes the ldap provider from the ldap
+// outpost. The outpost is deleted with its last provider.
+func removeOutpostProvider(provider int, url, token string) error {
+	o, err := getOutpost(ldapOutpost, url, token)
+	if err != nil || o == nil {
+		return err
+	}
+	providers := []int{}
+	for _, pk := range o.Providers {
+		if pk != provider {
+			providers = append(providers, pk)
+		}
+	}
+	if len(providers) == 0 {
+		return deleteAPIResource(url, fmt.Sprintf("outposts/instances/%s", o.PK), token)
+	}
+	data, err := json.Marshal(map[string]interface{}{"providers": providers})
+	if err != nil {
+		return err
+	}
+	_, err = patchAPIResource(url, fmt.Sprintf("outposts/instances/%s", o.PK), token, data)
+	return err
+}
+
+// applyGroup creates the group if it does not exist, and returns its
+// primary key.
+func applyGroup(name, url, token string) (string, error) {
+	pk, err := findGroupPK(url, token, name)
+	if err != nil || pk != "" {
+		return pk, err
+	}
+	data, err := json.Marshal(map[string]interface{}{"name": name})
+	if err != nil {
+		return "", err
+	}
+	resp, err := postAPIResource(url, "core/groups", token, data)
+	if err != nil {
+		return "", err
+	}
+	created := struct {
+		PK string `json:"pk"`
+	}{}
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return "", err
+	}
+	return created.PK, nil
+}
+
+// applyBindAccount creates the bind account in the search group, and
+// returns its primary key and whether it was created.
+func applyBindAccount(username, group, url, token string) (int, bool, error) {
+	u, err := getUser(url, token, username)
+	if err != nil {
+		return -1, false, err
+	}
+	if u == nil {
+		data, err := json.Marshal(user{Username: username, Name: username, IsActive: true, Groups: []string{group}})
+		if err != nil {
+			return -1, false, err
+		}
+		resp, err := postAPIResource(url, "core/users", token, data)
+		if err != nil {
+			return -1, false, err
+		}
+		created := &user{}
+		if err := json.Unmarshal(resp, created); err != nil {
+			return -1, false, err
+		}
+		return created.PK, true, nil
+	}
+	for _, g := range u.Groups {
+		if g == group {
+			return u.PK, false, nil
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"groups": append(u.Groups, group)})
+	if err != nil {
+		return -1, false, err
+	}
+	_, err = patchAPIResource(url, fmt.Sprintf("core/users/%d", u.PK), token, data)
+	return u.PK, false, err
+}
+
+// setPassword sets the password of the user.
+func setPassword(pk int, pwd, url, token string) error {
+	data, err := json.Marshal(map[string]string{"password": pwd})
+	if err != nil {
+		return err
+	}
+	_, err = postAPIResource(url, fmt.Sprintf("core/users/%d/set_password", pk), token, data)
+	return err
+}
+
+func createLDAPClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.New("name is required")
+	}
+	rotate, _ := params["rotate"].(bool)
+	return createLDAPClient(name, rotate)
+}
+
+// createLDAPClient creates the ldap provider of the client, its
+// application and its bind account, and serves the provider from the
+// ldap outpost. The users bind with the default authentication flow,
+// which is not subject to the toolchain mfa. Existing objects are
+// reused, and the stored bind password is kept unless rotate is set.
+// The client is published to the system inputs of the client
+// component.
+func createLDAPClient(name string, rotate bool) (map[string]interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	group, err := applyGroup(ldapSearchGroup(name), serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := getProviderRef("providers/ldap", name, serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		flow, err := getFlowPK(serviceURL, token, "default-authentication-flow")
+		if err != nil {
+			return nil, err
+		}
+		if flow == "" {
+			return nil, errors.New("flow 'default-authentication-flow' not found")
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"name":               name,
+			"authorization_flow": flow,
+			"base_dn":            ldapBaseDN(name),
+			"search_group":       group,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := postAPIResource(serviceURL, "providers/ldap", token, data)
+		if err != nil {
+			return nil, err
+		}
+		provider = &providerRef{}
+		if err := json.Unmarshal(resp, provider); err != nil {
+			return nil, err
+		}
+	}
+	exists, err := applicationExists(ldapApplication(name), serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := createApplication(provider.PK, ldapApplication(name), serviceURL, token); err != nil {
+			return nil, err
+		}
+	}
+	if err := addOutpostProvider(provider.PK, namespace, serviceURL, token); err != nil {
+		return nil, err
+	}
+	pk, created, err := applyBindAccount(ldapBindUsername(name), group, serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := functions.GetProviderSecret(componentName, functions.LDAPClientKind, name, namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	bindPassword := string(stored[bindPasswordKey])
+	if bindPassword == "" || rotate || created {
+		bindPassword, err = password.Generate(32, 10, 0, false, false)
+		if err != nil {
+			return nil, err
+		}
+		logging.AddSecret(bindPassword)
+		// the password is stored before it is set, so that a failed
+		// update is resumed with the same password.
+		if err := functions.StoreProviderSecret(componentName, functions.LDAPClientKind, name, namespace, map[string][]byte{bindPasswordKey: []byte(bindPassword)}, clientset); err != nil {
+			return nil, err
+		}
+	}
+	// the stored password is set on every call, since setting it is
+	// idempotent and a previous call may have failed to set it.
+	if err := setPassword(pk, bindPassword, serviceURL, token); err != nil {
+		return nil, err
+	}
+	client := &functions.LDAPClient{
+		BaseDN:       ldapBaseDN(name),
+		BindDN:       fmt.Sprintf("cn=%s,ou=users,%s", ldapBindUsername(name), ldapBaseDN(name)),
+		BindPassword: bindPassword,
+		Host:         ldapHost,
+	}
+	if err := functions.PublishLDAPClient(name, namespace, client, clientset); err != nil {
+		return nil, err
+	}
+	return client.Result(), nil
+}
+
+func deleteLDAPClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.New("name is required")
+	}
+	return nil, deleteLDAPClient(name)
+}
+
+// deleteLDAPClient deletes the ldap client application, provider, bind
+// account, search group and stored bind password. The published system
+// inputs are removed with the client component inputs.
+func deleteLDAPClient(name string) error {
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	if err := deleteApplication(ldapApplication(name), serviceURL, token); err != nil {
+		return err
+	}
+	provider, err := getProviderRef("providers/ldap", name, serviceURL, token)
+	if err != nil {
+		return err
+	}
+	if provider != nil {
+		if err := removeOutpostProvider(provider.PK, serviceURL, token); err != nil {
+			return err
+		}
+		if err := deleteAPIResource(serviceURL, fmt.Sprintf("providers/ldap/%d", provider.PK), token); err != nil {
+			return err
+		}
+	}
+	u, err := getUser(serviceURL, token, ldapBindUsername(name))
+	if err != nil {
+		return err
+	}
+	if u != nil {
+		if err := deleteAPIResource(serviceURL, fmt.Sprintf("core/users/%d", u.PK), token); err != nil {
+			return err
+		}
+	}
+	group, err := findGroupPK(serviceURL, token, ldapSearchGroup(name))
+	if err != nil {
+		return err
+	}
+	if group != "" {
+		if err := deleteAPIResource(serviceURL, fmt.Sprintf("core/groups/%s", group), token); err != nil {
+			return err
+		}
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	return functions.DeleteProviderSecret(componentName, functions.LDAPClientKind, name, namespace, clientset)
+}
@@ -0,0 +1,106 @@
+package authentik
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateLDAPClient(t *testing.T) {
+	a := newFakeAuthentik(t)
+	clientset := patchEnvironment(t, a)
+
+	client, err := createLDAPClient("nexus", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"baseDN":       "dc=nexus,dc=trustacks,dc=io",
+		"bindDN":       "cn=ldap-nexus,ou=users,dc=nexus,dc=trustacks,dc=io",
+		"bindPassword": client["bindPassword"],
+		"host":         "ak-outpost-trustacks-ldap",
+	}, client, "got an unexpected ldap client")
+
+	if assert.Len(t, a.objects["providers/ldap"], 1) {
+		provider := a.objects["providers/ldap"][0]
+		assert.Equal(t, "flow-authentication", provider["authorization_flow"], "expected the default authentication flow to bind")
+		assert.Equal(t, "dc=nexus,dc=trustacks,dc=io", provider["base_dn"])
+		group, err := getGroupPK(a.URL, a.token, "ldap-nexus-search")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, group, provider["search_group"])
+		assert.Equal(t, provider["pk"], a.applications["nexus-ldap"], "expected the ldap application")
+	}
+	if assert.Len(t, a.objects["outposts/instances"], 1) {
+		outpost := a.objects["outposts/instances"][0]
+		assert.Equal(t, "ldap", outpost["type"])
+		assert.Equal(t, "connection-local", outpost["service_connection"])
+		assert.Equal(t, []interface{}{float64(a.objects["providers/ldap"][0]["pk"].(int))}, outpost["providers"])
+	}
+	u, err := getUser(a.URL, a.token, "ldap-nexus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, u, "expected the bind account") {
+		assert.Equal(t, client["bindPassword"], a.passwords[u.PK], "expected the bind password to be set")
+	}
+
+	systemVars, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), "system-vars", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"nexus.ldap-base-dn": "dc=nexus,dc=trustacks,dc=io",
+		"nexus.ldap-bind-dn": "cn=ldap-nexus,ou=users,dc=nexus,dc=trustacks,dc=io",
+		"nexus.ldap-host":    "ak-outpost-trustacks-ldap",
+	}, systemVars.Data, "expected the ldap client system vars")
+	systemSecrets, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, client["bindPassword"], string(systemSecrets.Data["nexus.ldap-bind-password"]))
+
+	// a bind account that missed the stored password, such as after a
+	// failed update, is set to the stored password.
+	delete(a.passwords, u.PK)
+	resumed, err := createLDAPClient("nexus", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, client, resumed, "expected the stored bind password")
+	assert.Equal(t, client["bindPassword"], a.passwords[u.PK], "expected the stored bind password to be set")
+
+	// another client is served from the same outpost.
+	if _, err := createLDAPClient("registry", false); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, a.objects["outposts/instances"], 1, "expected the outpost to be shared")
+	assert.Len(t, a.objects["outposts/instances"][0]["providers"], 2)
+
+	if err := deleteLDAPClient("nexus"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, a.objects["providers/ldap"], 1, "expected the nexus provider to be deleted")
+	assert.Len(t, a.objects["outposts/instances"][0]["providers"], 1, "expected the nexus provider to be removed from the outpost")
+	_, ok := a.applications["nexus-ldap"]
+	assert.False(t, ok, "expected the application to be deleted")
+	u, err = getUser(a.URL, a.token, "ldap-nexus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, u, "expected the bind account to be deleted")
+	group, err := findGroupPK(a.URL, a.token, "ldap-nexus-search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, group, "expected the search group to be deleted")
+
+	// the outpost is deleted with its last provider.
+	if err := deleteLDAPClient("registry"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, a.objects["outposts/instances"], "expected the outpost to be deleted")
+}
@@ -0,0 +1,323 @@
+package authentik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// mfa modes of the toolchain logins.
+const (
+	// mfaOff keeps the login flow of the default tenant.
+	mfaOff = "off"
+	// mfaOptional validates the devices of the users that configured
+	// one.
+	mfaOptional = "optional"
+	// mfaRequired makes the users without a device configure one at
+	// their next login.
+	mfaRequired = "required"
+)
+
+// mfaMethods maps the mfa methods, which are the authentik device
+// classes, to the api resource of their setup stage.
+var mfaMethods = map[string]string{
+	"totp":     "stages/authenticator/totp",
+	"webauthn": "stages/authenticator/webauthn",
+}
+
+const (
+	// mfaFlowSlug is the slug of the mfa authentication flow.
+	mfaFlowSlug = "trustacks-authentication"
+	// mfaValidationStage is the name of the mfa validation stage.
+	mfaValidationStage = "trustacks-authentication-mfa"
+)
+
+// mfaFlowBindings are the stages of the mfa authentication flow and
+// their binding order. The mfa flow reuses the stages of the default
+// authentication flow, and validates the device after the password.
+var mfaFlowBindings = []struct {
+	stage string
+	order int
+}{
+	{"default-authentication-identification", 10},
+	{"default-authentication-password", 20},
+	{mfaValidationStage, 30},
+	{"default-authentication-login", 100},
+}
+
+// mfaConfig is the mfa configuration of the toolchain logins.
+type mfaConfig struct {
+	Mode    string
+	Methods []string
+}
+
+// parseMFAConfig parses the mfa mode and the comma separated mfa
+// methods. An empty mode turns mfa off.
+func parseMFAConfig(mode, methods string) (*mfaConfig, error) {
+	config := &mfaConfig{Mode: strings.TrimSpace(mode), Methods: []string{}}
+	switch config.Mode {
+	case "":
+		config.Mode = mfaOff
+	case mfaOff, mfaOptional, mfaRequired:
+	default:
+		return nil, fmt.Errorf("unknown mfa mode '%s', expected off, optional or required", mode)
+	}
+	seen := map[string]bool{}
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.ToLower(strings.TrimSpace(method))
+		if method == "" || seen[method] {
+			continue
+		}
+		if _, ok := mfaMethods[method]; !ok {
+			return nil, fmt.Errorf("unknown mfa method '%s', expected totp or webauthn", method)
+		}
+		seen[method] = true
+		config.Methods = append(config.Methods, method)
+	}
+	if config.Mode != mfaOff && len(config.Methods) == 0 {
+		return nil, fmt.Errorf("mfa mode '%s' requires at least one mfa method", config.Mode)
+	}
+	return config, nil
+}
+
+// applyMFA creates the mfa setup and validation stages and the mfa
+// authentication flow, and binds the flow to the default tenant login.
+// When mfa is off, the default tenant login is only changed back to the
+// default authentication flow if it uses the mfa flow. Existing stages,
+// bindings and flows are updated, so it can be repeated on upgrades.
+func applyMFA(config *mfaConfig, url, token string) error {
+	if config.Mode == mfaOff {
+		mfaFlow, err := getFlowPK(url, token, mfaFlowSlug)
+		if err != nil || mfaFlow == "" {
+			return err
+		}
+		defaultFlow, err := getFlowPK(url, token, "default-authentication-flow")
+		if err != nil {
+			return err
+		}
+		if defaultFlow == "" {
+			return errors.New("flow 'default-authentication-flow' not found")
+		}
+		return setAuthenticationFlow(url, token, defaultFlow, mfaFlow)
+	}
+	setupStages := []string{}
+	for _, method := range config.Methods {
+		stage := map[string]interface{}{"name": fmt.Sprintf("trustacks-authenticator-%s", method)}
+		if method == "totp" {
+			stage["digits"] = 6
+		}
+		pk, err := applyStage(url, token, mfaMethods[method], stage)
+		if err != nil {
+			return err
+		}
+		setupStages = append(setupStages, pk)
+	}
+	// users without a device skip the validation, unless mfa is
+	// required.
+	notConfiguredAction := "skip"
+	if config.Mode == mfaRequired {
+		notConfiguredAction = "configure"
+	}
+	validationStage, err := applyStage(url, token, "stages/authenticator/validate", map[string]interface{}{
+		"name":                  mfaValidationStage,
+		"device_classes":        config.Methods,
+		"not_configured_action": notConfiguredAction,
+		"configuration_stages":  setupStages,
+	})
+	if err != nil {
+		return err
+	}
+	flow, err := applyFlow(url, token)
+	if err != nil {
+		return err
+	}
+	for _, binding := range mfaFlowBindings {
+		stage := validationStage
+		if binding.stage != mfaValidationStage {
+			stage, err = getStagePK(url, token, binding.stage)
+			if err != nil {
+				return err
+			}
+		}
+		if err := bindFlowStage(url, token, flow, stage, binding.order); err != nil {
+			return err
+		}
+	}
+	return setAuthenticationFlow(url, token, flow, "")
+}
+
+// applyStage creates the stage of the api resource, or updates the
+// existing stage with the same name, and returns its primary key.
+func applyStage(url, token, resource string, stage map[string]interface{}) (string, error) {
+	name := stage["name"].(string)
+	resp, err := getAPIResource(url, resource, token, fmt.Sprintf("name=%s", name))
+	if err != nil {
+		return "", err
+	}
+	results := struct {
+		Results []struct {
+			PK   string `json:"pk"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(stage)
+	if err != nil {
+		return "", err
+	}
+	for _, existing := range results.Results {
+		if existing.Name == name {
+			_, err := patchAPIResource(url, fmt.Sprintf("%s/%s", resource, existing.PK), token, data)
+			return existing.PK, err
+		}
+	}
+	resp, err = postAPIResource(url, resource, token, data)
+	if err != nil {
+		return "", err
+	}
+	created := struct {
+		PK string `json:"pk"`
+	}{}
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return "", err
+	}
+	return created.PK, nil
+}
+
+// getStagePK gets the primary key of the stage with the name.
+func getStagePK(url, token, name string) (string, error) {
+	resp, err := getAPIResource(url, "stages/all", token, fmt.Sprintf("name=%s", name))
+	if err != nil {
+		return "", err
+	}
+	results := struct {
+		Results []struct {
+			PK   string `json:"pk"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return "", err
+	}
+	for _, stage := range results.Results {
+		if stage.Name == name {
+			return stage.PK, nil
+		}
+	}
+	return "", fmt.Errorf("stage '%s' not found", name)
+}
+
+// getFlowPK gets the primary key of the flow with the slug. An empty
+// primary key is returned when the flow does not exist.
+func getFlowPK(url, token, slug string) (string, error) {
+	resp, err := getAPIResource(url, "flows/instances", token, fmt.Sprintf("slug=%s", slug))
+	if err != nil {
+		return "", err
+	}
+	f := &flows{}
+	if err := json.Unmarshal(resp, &f); err != nil {
+		return "", err
+	}
+	for _, flow := range f.Results {
+		if flow.Slug == slug {
+			return flow.PK, nil
+		}
+	}
+	return "", nil
+}
+
+// applyFlow creates the mfa authentication flow if it does not exist,
+// and returns its primary key.
+func applyFlow(url, token string) (string, error) {
+	pk, err := getFlowPK(url, token, mfaFlowSlug)
+	if err != nil || pk != "" {
+		return pk, err
+	}
+	data, err := json.Marshal(map[string]string{
+		"name":        "trustacks authentication",
+		"slug":        mfaFlowSlug,
+		"title":       "Welcome to authentik!",
+		"designation": "authentication",
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := postAPIResource(url, "flows/instances", token, data)
+	if err != nil {
+		return "", err
+	}
+	created := struct {
+		PK string `json:"pk"`
+	}{}
+	if err := json.Unmarshal(resp, &created); err != nil {
+		return "", err
+	}
+	return created.PK, nil
+}
+
+// bindFlowStage binds the stage to the flow with the order, unless the
+// flow already has the binding.
+func bindFlowStage(url, token, flow, stage string, order int) error {
+	resp, err := getAPIResource(url, "flows/bindings", token, fmt.Sprintf("target=%s", flow))
+	if err != nil {
+		return err
+	}
+	results := struct {
+		Results []struct {
+			Target string `json:"target"`
+			Stage  string `json:"stage"`
+			Order  int    `json:"order"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return err
+	}
+	for _, binding := range results.Results {
+		if binding.Target == flow && binding.Stage == stage && binding.Order == order {
+			return nil
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"target": flow, "stage": stage, "order": order})
+	if err != nil {
+		return err
+	}
+	_, err = postAPIResource(url, "flows/bindings", token, data)
+	return err
+}
+
+// setAuthenticationFlow sets the login flow of the default tenant. When
+// current is set, the login flow is only changed from that flow.
+func setAuthenticationFlow(url, token, flow, current string) error {
+	resp, err := getAPIResource(url, "core/tenants", token, "default=true")
+	if err != nil {
+		return err
+	}
+	results := struct {
+		Results []struct {
+			UUID               string `json:"tenant_uuid"`
+			Default            bool   `json:"default"`
+			FlowAuthentication string `json:"flow_authentication"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return err
+	}
+	for _, tenant := range results.Results {
+		if !tenant.Default {
+			continue
+		}
+		if tenant.FlowAuthentication == flow || (current != "" && tenant.FlowAuthentication != current) {
+			return nil
+		}
+		data, err := json.Marshal(map[string]string{"flow_authentication": flow})
+		if err != nil {
+			return err
+		}
+		_, err = patchAPIResource(url, fmt.Sprintf("core/tenants/%s", tenant.UUID), token, data)
+		return err
+	}
+	return errors.New("default tenant not found")
+}
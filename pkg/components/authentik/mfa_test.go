@@ -0,0 +1,135 @@
+package authentik
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMFAConfig(t *testing.T) {
+	config, err := parseMFAConfig("required", " TOTP, webauthn,totp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &mfaConfig{Mode: mfaRequired, Methods: []string{"totp", "webauthn"}}, config)
+	config, err = parseMFAConfig("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, mfaOff, config.Mode, "expected mfa to be off by default")
+
+	for _, tc := range []struct {
+		mode    string
+		methods string
+	}{
+		{"always", "totp"},
+		{"required", "sms"},
+		{"optional", ""},
+	} {
+		if _, err := parseMFAConfig(tc.mode, tc.methods); err == nil {
+			t.Errorf("expected an error for mode '%s' and methods '%s'", tc.mode, tc.methods)
+		}
+	}
+}
+
+// loginFlow returns the login flow of the fake default tenant.
+func loginFlow(a *fakeAuthentik) interface{} {
+	return a.objects["core/tenants"][0]["flow_authentication"]
+}
+
+func TestApplyMFA(t *testing.T) {
+	a := newFakeAuthentik(t)
+
+	// mfa off keeps the default login flow.
+	if err := applyMFA(&mfaConfig{Mode: mfaOff}, a.URL, a.token); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "flow-authentication", loginFlow(a))
+
+	config := &mfaConfig{Mode: mfaRequired, Methods: []string{"totp", "webauthn"}}
+	for i := 0; i < 2; i++ {
+		if err := applyMFA(config, a.URL, a.token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Len(t, a.objects["stages/authenticator/totp"], 1, "expected the totp setup stage to be created once")
+	assert.Len(t, a.objects["stages/authenticator/webauthn"], 1, "expected the webauthn setup stage to be created once")
+	assert.Len(t, a.objects["stages/authenticator/validate"], 1, "expected the validation stage to be created once")
+	validation := a.objects["stages/authenticator/validate"][0]
+	assert.Equal(t, []interface{}{"totp", "webauthn"}, validation["device_classes"])
+	assert.Equal(t, "configure", validation["not_configured_action"], "expected the users to configure a device")
+	assert.Equal(t, []interface{}{
+		a.objects["stages/authenticator/totp"][0]["pk"],
+		a.objects["stages/authenticator/webauthn"][0]["pk"],
+	}, validation["configuration_stages"])
+
+	flow, err := getFlowPK(a.URL, a.token, mfaFlowSlug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, flow, "expected the mfa flow to be created")
+	assert.Len(t, a.objects["flows/instances"], 3, "expected the mfa flow to be created once")
+	bindings := map[interface{}]interface{}{}
+	for _, binding := range a.objects["flows/bindings"] {
+		assert.Equal(t, flow, binding["target"])
+		bindings[binding["stage"]] = binding["order"]
+	}
+	assert.Len(t, a.objects["flows/bindings"], 4, "expected the stages to be bound once")
+	assert.Equal(t, map[interface{}]interface{}{
+		"stage-identification": float64(10),
+		"stage-password":       float64(20),
+		validation["pk"]:       float64(30),
+		"stage-login":          float64(100),
+	}, bindings, "got unexpected flow stage bindings")
+	assert.Equal(t, flow, loginFlow(a), "expected the mfa flow to be the default login")
+
+	// an upgrade changes the validation stage in place.
+	if err := applyMFA(&mfaConfig{Mode: mfaOptional, Methods: []string{"webauthn"}}, a.URL, a.token); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, a.objects["stages/authenticator/validate"], 1)
+	validation = a.objects["stages/authenticator/validate"][0]
+	assert.Equal(t, []interface{}{"webauthn"}, validation["device_classes"])
+	assert.Equal(t, "skip", validation["not_configured_action"], "expected the users without a device to skip the validation")
+	assert.Len(t, a.objects["flows/bindings"], 4)
+
+	// turning mfa off restores the default login flow.
+	if err := applyMFA(&mfaConfig{Mode: mfaOff}, a.URL, a.token); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "flow-authentication", loginFlow(a))
+}
+
+func TestApplyMFACustomLoginFlow(t *testing.T) {
+	a := newFakeAuthentik(t)
+	// mfa off keeps a login flow that is not the mfa flow.
+	if err := applyMFA(&mfaConfig{Mode: mfaRequired, Methods: []string{"totp"}}, a.URL, a.token); err != nil {
+		t.Fatal(err)
+	}
+	a.objects["core/tenants"][0]["flow_authentication"] = "flow-custom"
+	if err := applyMFA(&mfaConfig{Mode: mfaOff}, a.URL, a.token); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "flow-custom", loginFlow(a))
+}
+
+func TestPostUpgradeMFA(t *testing.T) {
+	a := newFakeAuthentik(t)
+	patchEnvironment(t, a)
+	t.Setenv("AUTHENTIK_MFA", "required")
+	t.Setenv("AUTHENTIK_MFA_METHODS", "totp")
+	c := &authentik{}
+	if err := c.postUpgrade(); err != nil {
+		t.Fatal(err)
+	}
+	flow, err := getFlowPK(a.URL, a.token, mfaFlowSlug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, flow, loginFlow(a), "expected the mfa flow to be the default login")
+
+	t.Setenv("AUTHENTIK_MFA", "sometimes")
+	if err := c.postUpgrade(); err == nil {
+		t.Fatal("expected an invalid mfa mode error")
+	}
+}
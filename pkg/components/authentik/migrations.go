@@ -0,0 +1,36 @@
+package authentik
+
+import (
+	"context"
+
+	"github.com/trustacks/catalog/pkg/migrations"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// componentMigrations are the authentik chart upgrade migrations. A
+// migration runs on the upgrade to the chart version it targets, so it
+// stays pending until config.yaml pins that version.
+var componentMigrations = []migrations.Migration{
+	{
+		From:        "2022.7.2",
+		To:          "2022.8.1",
+		Description: "label the api token secret as part of authentik",
+		Run:         labelAPITokenSecret,
+	},
+}
+
+// labelAPITokenSecret adds the part-of label to the api token secret
+// of the installs that predate the label set by createAPIToken.
+func labelAPITokenSecret(namespace string, clientset kubernetes.Interface) error {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), apiTokenSecret, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels["app.kubernetes.io/part-of"] = componentName
+	_, err = clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	return err
+}
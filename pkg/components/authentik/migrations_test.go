@@ -0,0 +1,72 @@
+package authentik
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLabelAPITokenSecret(t *testing.T) {
+	// the api token secret of an install that predates the label.
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: apiTokenSecret, Namespace: "test"},
+		Data:       map[string][]byte{"api-token": []byte("test-token")},
+	})
+	if err := labelAPITokenSecret("test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), apiTokenSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, componentName, secret.Labels["app.kubernetes.io/part-of"], "got an unexpected part-of label")
+}
+
+func TestComponentMigrations(t *testing.T) {
+	var conf *catalog.ComponentConfig
+	if err := yaml.Unmarshal(config, &conf); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range componentMigrations {
+		clientset := fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: apiTokenSecret, Namespace: "test"},
+		})
+		// install at the chart version the migration upgrades from.
+		migrations.Register(componentName, m.From, componentMigrations...)
+		if err := migrations.SetVersion(componentName, "test", clientset); err != nil {
+			t.Fatal(err)
+		}
+		// the migration is pending at the pinned chart version only
+		// once config.yaml moves to the version it targets.
+		migrations.Register(componentName, conf.Version, componentMigrations...)
+		pending, err := migrations.Pending(componentName, "test", clientset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version.MustParseGeneric(conf.Version).LessThan(version.MustParseGeneric(m.To)) {
+			assert.Empty(t, pending, "expected %s to wait for the chart bump", m.Name())
+		} else {
+			assert.Equal(t, []migrations.Migration{m}, pending, "expected %s to be pending", m.Name())
+		}
+		migrations.Register(componentName, m.To, componentMigrations...)
+		applied, err := migrations.Run(componentName, "test", false, clientset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, applied, 1, "expected the chart bump migration to run")
+		secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), apiTokenSecret, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, componentName, secret.Labels["app.kubernetes.io/part-of"], "got an unexpected part-of label")
+	}
+	migrations.Register(componentName, conf.Version, componentMigrations...)
+}
@@ -0,0 +1,58 @@
+package authentik
+
+// CreateOIDCClient creates the oidc client provider and application.
+func (c *authentik) CreateOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return createOIDCClientHandler(params)
+}
+
+// GetOIDCClient gets the oidc client credentials and redirect uris.
+func (c *authentik) GetOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return getOIDCClientHandler(params)
+}
+
+// UpdateOIDCClient updates the oidc client redirect uris.
+func (c *authentik) UpdateOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return updateOIDCClientHandler(params)
+}
+
+// DeleteOIDCClient deletes the oidc client provider and application.
+func (c *authentik) DeleteOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return deleteOIDCClientHandler(params)
+}
+
+// CreateUser creates the user and an invite link.
+func (c *authentik) CreateUser(params map[string]interface{}) (interface{}, error) {
+	return createUserHandler(params)
+}
+
+// AddUserToGroup adds the user to the group.
+func (c *authentik) AddUserToGroup(params map[string]interface{}) (interface{}, error) {
+	return addUserToGroupHandler(params)
+}
+
+// RemoveUser deletes the user.
+func (c *authentik) RemoveUser(params map[string]interface{}) (interface{}, error) {
+	return removeUserHandler(params)
+}
+
+// CreateLDAPClient creates the ldap provider, application and bind
+// account, and adds the provider to the ldap outpost.
+func (c *authentik) CreateLDAPClient(params map[string]interface{}) (interface{}, error) {
+	return createLDAPClientHandler(params)
+}
+
+// DeleteLDAPClient deletes the ldap provider, application and bind
+// account.
+func (c *authentik) DeleteLDAPClient(params map[string]interface{}) (interface{}, error) {
+	return deleteLDAPClientHandler(params)
+}
+
+// CreateSAMLClient creates the saml provider and application.
+func (c *authentik) CreateSAMLClient(params map[string]interface{}) (interface{}, error) {
+	return createSAMLClientHandler(params)
+}
+
+// DeleteSAMLClient deletes the saml provider and application.
+func (c *authentik) DeleteSAMLClient(params map[string]interface{}) (interface{}, error) {
+	return deleteSAMLClientHandler(params)
+}
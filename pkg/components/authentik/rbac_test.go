@@ -0,0 +1,101 @@
+package authentik
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
+	"github.com/trustacks/catalog/pkg/rbac"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// initializeOnce adds the component hooks once per test binary.
+var initializeOnce sync.Once
+
+func TestHookRBAC(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"results": []}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	previousServiceURL := serviceURL
+	serviceURL = ts.URL
+	defer func() { serviceURL = previousServiceURL }()
+
+	// patch the in cluster namespace file.
+	f, err := os.CreateTemp("", "in-cluster-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	previousInClusterNamespace := inClusterNamespace
+	inClusterNamespace = f.Name()
+	defer func() {
+		os.Remove(f.Name())
+		inClusterNamespace = previousInClusterNamespace
+	}()
+
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	previousNewClientset := newClientset
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	defer func() { newClientset = previousNewClientset }()
+
+	initializeOnce.Do(func() {
+		cat, err := catalog.NewComponentCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		Initialize(cat)
+	})
+	manifests, err := rbac.RenderManifests(string(hookManifests), map[string]interface{}{"image": "test", "tls": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the hooks through the release lifecycle.
+	var grants []rbac.Grant
+	accesses := []rbac.Access{}
+	for _, step := range []struct {
+		hook     string
+		revision string
+	}{
+		{hooks.PreInstallHook, "1"},
+		{hooks.PostInstallHook, "1"},
+		{hooks.PreUpgrade, "2"},
+		{hooks.PostUpgrade, "2"},
+		{hooks.PostRollback, "1"},
+		{hooks.PostDeleteHook, "3"},
+	} {
+		t.Setenv("RELEASE_REVISION", step.revision)
+		hookAccesses, err := rbac.Record(clientset, func() error { return hooks.Call(componentName, step.hook) })
+		if err != nil {
+			t.Fatalf("%s: %s", step.hook, err)
+		}
+		grants, err = rbac.Grants(manifests, "test", componentName+"-"+step.hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := rbac.Verify(grants, hookAccesses)
+		assert.Empty(t, report.Missing, "%s is missing permissions:\n%s", step.hook, report)
+		accesses = append(accesses, hookAccesses...)
+	}
+
+	// every hook job shares the role. restore patches the component
+	// keys of the shared system secrets. authentik adds none, but the
+	// snapshot rules are shared by every component.
+	report := rbac.Verify(grants, accesses)
+	assert.Equal(t, []rbac.Grant{{Namespace: "test", Resource: "secrets", Verb: "patch"}}, report.Unused, "got unexpected unused grants:\n%s", report)
+}
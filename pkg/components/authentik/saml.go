@@ -0,0 +1,223 @@
+package authentik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/trustacks/catalog/pkg/functions"
+)
+
+// samlMappingPrefix is the prefix of the managed saml property
+// mappings.
+const samlMappingPrefix = "goauthentik.io/providers/saml/"
+
+// defaultSAMLAttributeMappings are the attribute mappings of the saml
+// clients that do not name any.
+var defaultSAMLAttributeMappings = []string{"email", "name", "username", "groups"}
+
+// samlApplication returns the slug of the saml client application,
+// which does not collide with the oidc client application.
+func samlApplication(name string) string {
+	return fmt.Sprintf("%s-saml", name)
+}
+
+// getSAMLPropertyMappings gets the primary keys of the managed saml
+// property mappings of the attributes, such as email or groups.
+func getSAMLPropertyMappings(url, token string, attributes []string) ([]string, error) {
+	resp, err := getAPIResource(url, "propertymappings/all", token, "")
+	if err != nil {
+		return nil, err
+	}
+	pm := &propertyMappings{}
+	if err := json.Unmarshal(resp, &pm); err != nil {
+		return nil, err
+	}
+	pks := []string{}
+	for _, attribute := range attributes {
+		pk := ""
+		for _, p := range pm.Results {
+			if p.Managed == samlMappingPrefix+attribute {
+				pk = p.PK
+			}
+		}
+		if pk == "" {
+			return nil, fmt.Errorf("saml attribute mapping '%s' not found", attribute)
+		}
+		pks = append(pks, pk)
+	}
+	return pks, nil
+}
+
+// getSAMLMetadata gets the identity provider metadata of the saml
+// provider.
+func getSAMLMetadata(provider int, url, token string) (string, error) {
+	resp, err := getAPIResource(url, fmt.Sprintf("providers/saml/%d/metadata", provider), token, "")
+	if err != nil {
+		return "", err
+	}
+	metadata := struct {
+		Metadata string `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(resp, &metadata); err != nil {
+		return "", err
+	}
+	return metadata.Metadata, nil
+}
+
+func createSAMLClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.New("name is required")
+	}
+	acsURL, ok := params["acsURL"].(string)
+	if !ok || acsURL == "" {
+		return nil, errors.New("acsURL is required")
+	}
+	entityID, ok := params["entityID"].(string)
+	if !ok || entityID == "" {
+		return nil, errors.New("entityID is required")
+	}
+	attributes := []string{}
+	mappings, _ := params["attributeMappings"].([]interface{})
+	for _, mapping := range mappings {
+		attributes = append(attributes, fmt.Sprint(mapping))
+	}
+	if len(attributes) == 0 {
+		attributes = defaultSAMLAttributeMappings
+	}
+	return createSAMLClient(name, acsURL, entityID, attributes)
+}
+
+// createSAMLClient creates the saml provider of the client and its
+// application. The provider of an existing client is updated with the
+// acs url, entity id, attribute mappings and signing key. The identity
+// provider metadata is fetched after every change of the provider, and
+// the cache is only written when the metadata changed.
+func createSAMLClient(name, acsURL, entityID string, attributes []string) (map[string]interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := getSAMLPropertyMappings(serviceURL, token, attributes)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := getCertificateKeypair(serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{
+		"name":              name,
+		"acs_url":           acsURL,
+		"audience":          entityID,
+		"sp_binding":        "post",
+		"property_mappings": mappings,
+		"signing_kp":        signingKey,
+	}
+	provider, err := getProviderRef("providers/saml", name, serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		flow, err := getAuthorizationFlow(serviceURL, token)
+		if err != nil {
+			return nil, err
+		}
+		body["authorization_flow"] = flow
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := postAPIResource(serviceURL, "providers/saml", token, data)
+		if err != nil {
+			return nil, err
+		}
+		provider = &providerRef{}
+		if err := json.Unmarshal(resp, provider); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := patchAPIResource(serviceURL, fmt.Sprintf("providers/saml/%d", provider.PK), token, data); err != nil {
+			return nil, err
+		}
+	}
+	exists, err := applicationExists(samlApplication(name), serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := createApplication(provider.PK, samlApplication(name), serviceURL, token); err != nil {
+			return nil, err
+		}
+	}
+	stored, err := functions.GetProviderSecret(componentName, functions.SAMLClientKind, name, namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := getSAMLMetadata(provider.PK, serviceURL, token)
+	if err != nil {
+		return nil, err
+	}
+	if cached := functions.SAMLClientFromData(stored); cached != nil && cached.Metadata == metadata {
+		return cached.Result(), nil
+	}
+	client, err := functions.ParseSAMLMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := functions.StoreProviderSecret(componentName, functions.SAMLClientKind, name, namespace, client.Data(), clientset); err != nil {
+		return nil, err
+	}
+	return client.Result(), nil
+}
+
+func deleteSAMLClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.New("name is required")
+	}
+	return nil, deleteSAMLClient(name)
+}
+
+// deleteSAMLClient deletes the saml client application, provider and
+// cached metadata.
+func deleteSAMLClient(name string) error {
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	if err := deleteApplication(samlApplication(name), serviceURL, token); err != nil {
+		return err
+	}
+	provider, err := getProviderRef("providers/saml", name, serviceURL, token)
+	if err != nil {
+		return err
+	}
+	if provider != nil {
+		if err := deleteAPIResource(serviceURL, fmt.Sprintf("providers/saml/%d", provider.PK), token); err != nil {
+			return err
+		}
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	return functions.DeleteProviderSecret(componentName, functions.SAMLClientKind, name, namespace, clientset)
+}
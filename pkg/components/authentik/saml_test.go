@@ -0,0 +1,86 @@
+package authentik
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateSAMLClient(t *testing.T) {
+	a := newFakeAuthentik(t)
+	clientset := patchEnvironment(t, a)
+
+	client, err := createSAMLClient("sonarqube", "http://sonarqube.local.gd/oauth2/callback/saml", "sonarqube", []string{"email", "groups"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, a.objects["providers/saml"], 1) {
+		return
+	}
+	provider := a.objects["providers/saml"][0]
+	assert.Equal(t, "http://sonarqube.local.gd/oauth2/callback/saml", provider["acs_url"])
+	assert.Equal(t, "sonarqube", provider["audience"], "expected the entity id to be the audience")
+	assert.Equal(t, []interface{}{"mapping-saml-email", "mapping-saml-groups"}, provider["property_mappings"])
+	assert.Equal(t, "keypair", provider["signing_kp"])
+	assert.Equal(t, "flow-authorization", provider["authorization_flow"])
+	assert.Equal(t, provider["pk"], a.applications["sonarqube-saml"], "expected the saml application")
+
+	metadata := fakeSAMLMetadata(a.URL, "1")
+	assert.Equal(t, map[string]interface{}{
+		"metadata":    metadata,
+		"ssoURL":      a.URL + "/application/saml/1/sso/binding/redirect/",
+		"certificate": "-----BEGIN CERTIFICATE-----\n" + fakeSAMLCertificate + "\n-----END CERTIFICATE-----\n",
+	}, client, "got an unexpected saml client")
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "authentik-saml-client-sonarqube", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, metadata, string(secret.Data["metadata.xml"]), "expected the metadata to be cached")
+
+	// an existing client is updated, and its metadata is fetched
+	// again. The fake metadata names the provider, so a changed
+	// provider changes the metadata.
+	a.objects["providers/saml"][0]["pk"] = 2
+	updated, err := createSAMLClient("sonarqube", "https://sonarqube.local.gd/oauth2/callback/saml", "sonarqube", []string{"username"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, a.objects["providers/saml"], 1, "expected the provider to be reused")
+	assert.Equal(t, "https://sonarqube.local.gd/oauth2/callback/saml", provider["acs_url"])
+	assert.Equal(t, []interface{}{"mapping-saml-username"}, provider["property_mappings"])
+	assert.Equal(t, fakeSAMLMetadata(a.URL, "2"), updated["metadata"], "expected the metadata to be fetched again")
+	secret, err = clientset.CoreV1().Secrets("test").Get(context.TODO(), "authentik-saml-client-sonarqube", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, updated["metadata"], string(secret.Data["metadata.xml"]), "expected the cached metadata to be updated")
+
+	// unchanged metadata is not written to the cache again.
+	fakeClientset := clientset.(*fake.Clientset)
+	fakeClientset.ClearActions()
+	cached, err := createSAMLClient("sonarqube", "https://sonarqube.local.gd/oauth2/callback/saml", "sonarqube", []string{"username"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, updated, cached, "expected the cached metadata")
+	for _, action := range fakeClientset.Actions() {
+		assert.False(t, action.Matches("update", "secrets") || action.Matches("create", "secrets"), "expected the cache to be kept")
+	}
+
+	if _, err := createSAMLClient("sonarqube", "https://sonarqube.local.gd/oauth2/callback/saml", "sonarqube", []string{"phone"}); err == nil {
+		t.Fatal("expected an unknown attribute mapping error")
+	}
+
+	if err := deleteSAMLClient("sonarqube"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, a.objects["providers/saml"], "expected the provider to be deleted")
+	_, ok := a.applications["sonarqube-saml"]
+	assert.False(t, ok, "expected the application to be deleted")
+	if _, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "authentik-saml-client-sonarqube", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the cached metadata to be deleted")
+	}
+}
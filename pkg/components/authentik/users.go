@@ -0,0 +1,268 @@
+package authentik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/trustacks/catalog/pkg/logging"
+)
+
+// user represents an authentik user.
+type user struct {
+	PK       int      `json:"pk,omitempty"`
+	Username string   `json:"username"`
+	Name     string   `json:"name"`
+	Email    string   `json:"email"`
+	IsActive bool     `json:"is_active"`
+	Groups   []string `json:"groups"`
+	// LastLogin is unset until the user first logs in.
+	LastLogin *string `json:"last_login,omitempty"`
+}
+
+// getUser gets the user with the username. A nil user is returned
+// when it does not exist.
+func getUser(url, token, username string) (*user, error) {
+	resp, err := getAPIResource(url, "core/users", token, fmt.Sprintf("username=%s", username))
+	if err != nil {
+		return nil, err
+	}
+	results := struct {
+		Results []user `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return nil, err
+	}
+	for _, u := range results.Results {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+
+// getGroupPK gets the primary key of the group.
+func getGroupPK(url, token, name string) (string, error) {
+	pk, err := findGroupPK(url, token, name)
+	if err != nil {
+		return "", err
+	}
+	if pk == "" {
+		return "", fmt.Errorf("group '%s' not found", name)
+	}
+	return pk, nil
+}
+
+// findGroupPK gets the primary key of the group. An empty primary key
+// is returned when the group does not exist.
+func findGroupPK(url, token, name string) (string, error) {
+	resp, err := getAPIResource(url, "core/groups", token, fmt.Sprintf("name=%s", name))
+	if err != nil {
+		return "", err
+	}
+	results := struct {
+		Results []struct {
+			PK   string `json:"pk"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return "", err
+	}
+	for _, g := range results.Results {
+		if g.Name == name {
+			return g.PK, nil
+		}
+	}
+	return "", nil
+}
+
+// checkRecoveryFlow checks that the default tenant has a recovery flow,
+// which is required to create the invite links.
+func checkRecoveryFlow(url, token string) error {
+	resp, err := getAPIResource(url, "core/tenants/current", token, "")
+	if err != nil {
+		return err
+	}
+	tenant := struct {
+		FlowRecovery string `json:"flow_recovery"`
+	}{}
+	if err := json.Unmarshal(resp, &tenant); err != nil {
+		return err
+	}
+	if tenant.FlowRecovery == "" {
+		return errors.New("the default tenant has no recovery flow to create the invite links")
+	}
+	return nil
+}
+
+// getRecoveryLink creates a recovery link, which the user follows to
+// set a password.
+func getRecoveryLink(url, token string, pk int) (string, error) {
+	resp, err := getAPIResource(url, fmt.Sprintf("core/users/%d/recovery", pk), token, "")
+	if err != nil {
+		return "", err
+	}
+	link := struct {
+		Link string `json:"link"`
+	}{}
+	if err := json.Unmarshal(resp, &link); err != nil {
+		return "", err
+	}
+	return link.Link, nil
+}
+
+// sendRecoveryEmail sends a recovery link to the user email address
+// with the email stage.
+func sendRecoveryEmail(url, token string, pk int, emailStage string) error {
+	resp, err := getAPIResource(url, "stages/email", token, fmt.Sprintf("name=%s", emailStage))
+	if err != nil {
+		return err
+	}
+	results := struct {
+		Results []struct {
+			PK   string `json:"pk"`
+			Name string `json:"name"`
+		} `json:"results"`
+	}{}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return err
+	}
+	for _, stage := range results.Results {
+		if stage.Name == emailStage {
+			_, err := getAPIResource(url, fmt.Sprintf("core/users/%d/recovery_email", pk), token, fmt.Sprintf("email_stage=%s", stage.PK))
+			return err
+		}
+	}
+	return fmt.Errorf("email stage '%s' not found", emailStage)
+}
+
+// inviteUser creates an invite link of the user. The link is also
+// sent to the user when an email stage is provided.
+func inviteUser(url, token string, u *user, emailStage string) (string, error) {
+	link, err := getRecoveryLink(url, token, u.PK)
+	if err != nil {
+		return "", err
+	}
+	logging.AddSecret(link)
+	if emailStage != "" {
+		logging.Info("send invite email", "username", u.Username)
+		if err := sendRecoveryEmail(url, token, u.PK, emailStage); err != nil {
+			return "", err
+		}
+	}
+	return link, nil
+}
+
+// createUserHandler creates the user and an invite link. The link is
+// also sent to the user when an email stage is provided. Existing users
+// are kept, and are invited again until they first log in, so that a
+// failed invite is resumed.
+func createUserHandler(params map[string]interface{}) (interface{}, error) {
+	username, ok := params["username"].(string)
+	if !ok || username == "" {
+		return nil, errors.New("username is required")
+	}
+	email, _ := params["email"].(string)
+	name, _ := params["name"].(string)
+	if name == "" {
+		name = username
+	}
+	emailStage, _ := params["emailStage"].(string)
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := getUser(serviceURL, token, username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.LastLogin != nil {
+		return map[string]interface{}{"username": username, "created": false}, nil
+	}
+	if err := checkRecoveryFlow(serviceURL, token); err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		link, err := inviteUser(serviceURL, token, existing, emailStage)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"username": username, "created": false, "link": link}, nil
+	}
+	data, err := json.Marshal(user{Username: username, Name: name, Email: email, IsActive: true, Groups: []string{}})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postAPIResource(serviceURL, "core/users", token, data)
+	if err != nil {
+		return nil, err
+	}
+	created := &user{}
+	if err := json.Unmarshal(resp, created); err != nil {
+		return nil, err
+	}
+	link, err := inviteUser(serviceURL, token, created, emailStage)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"username": username, "created": true, "link": link}, nil
+}
+
+// addUserToGroupHandler adds the user to the group.
+func addUserToGroupHandler(params map[string]interface{}) (interface{}, error) {
+	username, ok := params["username"].(string)
+	if !ok || username == "" {
+		return nil, errors.New("username is required")
+	}
+	group, ok := params["group"].(string)
+	if !ok || group == "" {
+		return nil, errors.New("group is required")
+	}
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	u, err := getUser(serviceURL, token, username)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, fmt.Errorf("user '%s' not found", username)
+	}
+	pk, err := getGroupPK(serviceURL, token, group)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range u.Groups {
+		if g == pk {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"groups": append(u.Groups, pk)})
+	if err != nil {
+		return nil, err
+	}
+	_, err = patchAPIResource(serviceURL, fmt.Sprintf("core/users/%d", u.PK), token, data)
+	return nil, err
+}
+
+// removeUserHandler deletes the user. Missing users are ignored.
+func removeUserHandler(params map[string]interface{}) (interface{}, error) {
+	username, ok := params["username"].(string)
+	if !ok || username == "" {
+		return nil, errors.New("username is required")
+	}
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	u, err := getUser(serviceURL, token, username)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, nil
+	}
+	return nil, deleteAPIResource(serviceURL, fmt.Sprintf("core/users/%d", u.PK), token)
+}
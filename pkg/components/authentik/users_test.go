@@ -0,0 +1,103 @@
+package authentik
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/functions"
+)
+
+func TestCreateUser(t *testing.T) {
+	a := newFakeAuthentik(t)
+	patchEnvironment(t, a)
+
+	if _, err := createUserHandler(map[string]interface{}{}); err == nil {
+		t.Fatal("expected a missing username error")
+	}
+	result, err := createUserHandler(map[string]interface{}{"username": "jdoe", "email": "jdoe@example.com", "emailStage": "default-recovery-email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := result.(map[string]interface{})
+	assert.Equal(t, true, v["created"])
+	assert.Equal(t, a.URL+"/recovery/1", v["link"], "expected a recovery link")
+	assert.Equal(t, []*user{{PK: 1, Username: "jdoe", Name: "jdoe", Email: "jdoe@example.com", IsActive: true, Groups: []string{}}}, a.users)
+	assert.Equal(t, []int{1}, a.recoveryEmails, "expected the invite email to be sent")
+
+	// existing users are kept, and are invited again until they log
+	// in, so that a failed invite is resumed.
+	result, err = createUserHandler(map[string]interface{}{"username": "jdoe", "emailStage": "default-recovery-email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"username": "jdoe", "created": false, "link": a.URL + "/recovery/1"}, result)
+	assert.Len(t, a.users, 1)
+	assert.Equal(t, []int{1, 1}, a.recoveryEmails, "expected the invite email to be sent again")
+
+	lastLogin := "2022-08-01T00:00:00Z"
+	a.users[0].LastLogin = &lastLogin
+	result, err = createUserHandler(map[string]interface{}{"username": "jdoe", "emailStage": "default-recovery-email"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"username": "jdoe", "created": false}, result, "expected no invite after the first login")
+	assert.Len(t, a.recoveryEmails, 2)
+
+	if _, err := createUserHandler(map[string]interface{}{"username": "rroe", "emailStage": "missing"}); err == nil {
+		t.Fatal("expected a missing email stage error")
+	}
+	a.recoveryFlow = ""
+	if _, err := createUserHandler(map[string]interface{}{"username": "other"}); err == nil {
+		t.Fatal("expected a missing recovery flow error")
+	}
+}
+
+func TestAddUserToGroup(t *testing.T) {
+	a := newFakeAuthentik(t)
+	patchEnvironment(t, a)
+	a.users = []*user{{PK: 1, Username: "jdoe", Name: "jdoe", IsActive: true, Groups: []string{"group-viewers"}}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := addUserToGroupHandler(map[string]interface{}{"username": "jdoe", "group": "editors"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, []string{"group-viewers", "group-editors"}, a.users[0].Groups, "expected the group to be added once")
+
+	if _, err := addUserToGroupHandler(map[string]interface{}{"username": "jdoe", "group": "missing"}); err == nil {
+		t.Fatal("expected a missing group error")
+	}
+	if _, err := addUserToGroupHandler(map[string]interface{}{"username": "missing", "group": "editors"}); err == nil {
+		t.Fatal("expected a missing user error")
+	}
+}
+
+func TestRemoveUser(t *testing.T) {
+	a := newFakeAuthentik(t)
+	patchEnvironment(t, a)
+	a.users = []*user{{PK: 1, Username: "jdoe", Name: "jdoe", IsActive: true}}
+
+	if _, err := removeUserHandler(map[string]interface{}{"username": "jdoe"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, a.users, "expected the user to be deleted")
+	// missing users are ignored.
+	if _, err := removeUserHandler(map[string]interface{}{"username": "jdoe"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	a := newFakeAuthentik(t)
+	patchEnvironment(t, a)
+	t.Cleanup(functions.PatchSSOProvider("authentik", &authentik{}))
+
+	params := []byte(`{"provider": "authentik", "users": "- username: jdoe\n  groups: [admins]\n"}`)
+	for i := 0; i < 2; i++ {
+		if _, err := functions.Call("import-users", params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Len(t, a.users, 1, "expected a repeated import to keep the user")
+	assert.Equal(t, []string{"group-admins"}, a.users[0].Groups)
+}
@@ -0,0 +1,87 @@
+package components
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/trustacks/catalog/pkg/catalog"
+)
+
+var (
+	// testCatalog is the catalog of every component. the components
+	// can only be initialized once per test binary.
+	testCatalog *catalog.ComponentCatalog
+	catalogOnce sync.Once
+)
+
+// newCatalog returns the catalog of every component.
+func newCatalog(t *testing.T) *catalog.ComponentCatalog {
+	catalogOnce.Do(func() {
+		c, err := catalog.NewComponentCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		Initialize(c)
+		testCatalog = c
+	})
+	return testCatalog
+}
+
+// TestMirror verifies that the registryMirror and chartMirror
+// parameters rewrite every chart and image reference of the components,
+// and that the rewritten images are listed in the component artifacts.
+// The manifest repository stays the upstream chart url.
+func TestMirror(t *testing.T) {
+	cat := newCatalog(t)
+	for name := range cat.Components {
+		if err := cat.LintMirror(name); err != nil {
+			t.Error(err)
+		}
+	}
+	data, err := json.Marshal(cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Components map[string]struct {
+			Repository string `json:"repository"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for name, component := range manifest.Components {
+		if !strings.HasPrefix(component.Repository, "https://") {
+			t.Errorf("%s: expected the manifest repository to be a url, got '%s'", name, component.Repository)
+		}
+	}
+}
+
+func TestSSOIssuer(t *testing.T) {
+	cat := newCatalog(t)
+	tests := []struct {
+		sso    string
+		issuer string
+	}{
+		{"authentik", "https://authentik.local.gd/application/o/%s/"},
+		{"keycloak", "https://keycloak.local.gd/realms/toolchain"},
+		{"dex", "https://dex.local.gd"},
+	}
+	for _, component := range []string{"argo-cd", "concourse"} {
+		for _, tc := range tests {
+			values, err := cat.RenderValues(component, map[string]string{"sso": tc.sso})
+			if err != nil {
+				t.Fatal(err)
+			}
+			issuer := tc.issuer
+			if strings.Contains(issuer, "%s") {
+				issuer = strings.Replace(issuer, "%s", component, 1)
+			}
+			if !strings.Contains(values, `issuer: "`+issuer+`"`) {
+				t.Errorf("%s: expected the %s issuer '%s'", component, tc.sso, issuer)
+			}
+		}
+	}
+}
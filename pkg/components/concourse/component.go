@@ -13,7 +13,6 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
@@ -23,6 +22,13 @@ import (
 	"github.com/trustacks/catalog/pkg/catalog"
 	"github.com/trustacks/catalog/pkg/functions"
 	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/inputs"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/roles"
+	"github.com/trustacks/catalog/pkg/rollout"
+	"github.com/trustacks/catalog/pkg/snapshots"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
@@ -34,9 +40,10 @@ import (
 
 const (
 	// componentName is the name of the component.
-	componentName          = "concourse"
+	componentName = "concourse"
+	// callbackPath is the oidc redirect path of the concourse web.
+	callbackPath           = "/sky/issuer/callback"
 	systemVarsName         = "system-vars"
-	systemSecretsName      = "system-secrets"
 	applicationVarsName    = "application-vars"
 	applicationSecretsName = "application-secrets"
 )
@@ -52,13 +59,14 @@ type concourse struct {
 	catalog.BaseComponent
 }
 
+// snapshotSpec contains the resources managed by the component hooks.
+var snapshotSpec = snapshots.Spec{
+	Secrets: []string{"concourse-web", "concourse-worker"},
+}
+
 // preInstall creates the concourse oidc client and secrets.
 func (c *concourse) preInstall() error {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return err
-	}
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := newClientset()
 	if err != nil {
 		return err
 	}
@@ -66,15 +74,57 @@ func (c *concourse) preInstall() error {
 	if err != nil {
 		return err
 	}
+	metrics.Step("create-oidc-client")
 	provider := os.Getenv("SSO_PROVIDER")
 	clientId, clientSecret, err := createOIDCClient(provider)
 	if err != nil {
 		return err
 	}
+	metrics.Step("create-secrets")
 	if err := createSecrets(clientId, clientSecret, namespace, clientset); err != nil {
 		return err
 	}
-	return nil
+	return migrations.SetVersion(componentName, namespace, clientset)
+}
+
+// preDelete destroys the application teams.
+func (c *concourse) preDelete() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	pwd, err := getSystemUserPassword(namespace, clientset)
+	if err != nil {
+		return err
+	}
+	cli, err := downloadFlyCLI(serviceURL)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(cli)
+	logging.Info("destroy application teams")
+	return destroyTeams(pwd, cli, runFlyCmdOutput)
+}
+
+// postDelete deletes the oidc client and the web and worker secrets.
+func (c *concourse) postDelete() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("delete oidc client")
+	if err := deleteOIDCClient(os.Getenv("SSO_PROVIDER")); err != nil {
+		return err
+	}
+	return deleteSecrets(namespace, clientset)
 }
 
 // generateRSAKeyPair creates an RSA private and public key pair.
@@ -98,41 +148,64 @@ func generateRSAKeyPair() ([]byte, []byte, error) {
 	return privateKeyBuf.Bytes(), ssh.MarshalAuthorizedKey(publicKey), nil
 }
 
-// createSecrets creates the web and worker secrets.
-func createSecrets(clientId, clientSecret, namespace string, clientset kubernetes.Interface) error {
+// generateSecrets generates the web and worker keys and the system
+// user credentials.
+func generateSecrets() (map[string][]byte, map[string][]byte, error) {
 	hostKey, hostKeyPub, err := generateRSAKeyPair()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	workerKey, workerKeyPub, err := generateRSAKeyPair()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	sessionSigningKey, _, err := generateRSAKeyPair()
+	if err != nil {
+		return nil, nil, err
+	}
+	pwd, err := password.Generate(32, 10, 0, false, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	logging.AddSecret(pwd)
+	webData := map[string][]byte{
+		"host-key":            hostKey,
+		"session-signing-key": sessionSigningKey,
+		"worker-key-pub":      workerKeyPub,
+		"local-users":         []byte(fmt.Sprintf("trustacks:%s", pwd)),
+	}
+	workerData := map[string][]byte{
+		"host-key-pub": hostKeyPub,
+		"worker-key":   workerKey,
+	}
+	return webData, workerData, nil
+}
+
+// createSecrets creates the web and worker secrets.
+func createSecrets(clientId, clientSecret, namespace string, clientset kubernetes.Interface) error {
+	webData, workerData, err := generateSecrets()
 	if err != nil {
 		return err
 	}
+	webData["oidc-client-id"] = []byte(clientId)
+	webData["oidc-client-secret"] = []byte(clientSecret)
 	webSecrets := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "concourse-web",
+			Labels: map[string]string{
+				"app.kubernetes.io/part-of": componentName,
+			},
 		},
-		Data: map[string][]byte{
-			"host-key":            hostKey,
-			"session-signing-key": sessionSigningKey,
-			"worker-key-pub":      workerKeyPub,
-			"oidc-client-id":      []byte(clientId),
-			"oidc-client-secret":  []byte(clientSecret),
-			"local-users":         []byte(fmt.Sprintf("trustacks:%s", password.MustGenerate(32, 10, 0, false, false))),
-		},
+		Data: webData,
 	}
 	workerSecrets := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "concourse-worker",
+			Labels: map[string]string{
+				"app.kubernetes.io/part-of": componentName,
+			},
 		},
-		Data: map[string][]byte{
-			"host-key-pub": hostKeyPub,
-			"worker-key":   workerKey,
-		},
+		Data: workerData,
 	}
 	for _, secret := range []*corev1.Secret{webSecrets, workerSecrets} {
 		if _, err := clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
@@ -142,9 +215,66 @@ func createSecrets(clientId, clientSecret, namespace string, clientset kubernete
 	return nil
 }
 
+// rotateSecretsHandler rotates the concourse keys and system user
+// credentials and restarts the web and worker workloads.
+func rotateSecretsHandler(_ map[string]interface{}) (interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("rotate web and worker secrets")
+	if err := rotateSecrets(namespace, clientset); err != nil {
+		return nil, err
+	}
+	if err := rollout.RestartDeployment(namespace, "concourse-web", clientset); err != nil {
+		return nil, err
+	}
+	return nil, rollout.RestartStatefulSet(namespace, "concourse-worker", clientset)
+}
+
+// rotateSecrets replaces the keys and system user credentials in the
+// web and worker secrets. The oidc client credentials are kept.
+func rotateSecrets(namespace string, clientset kubernetes.Interface) error {
+	webData, workerData, err := generateSecrets()
+	if err != nil {
+		return err
+	}
+	for name, data := range map[string]map[string][]byte{"concourse-web": webData, "concourse-worker": workerData} {
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for k, v := range data {
+			secret.Data[k] = v
+		}
+		if _, err := clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSecrets deletes the web and worker secrets.
+func deleteSecrets(namespace string, clientset kubernetes.Interface) error {
+	for _, name := range []string{"concourse-web", "concourse-worker"} {
+		err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+	}
+	return nil
+}
+
 // createOIDCClient creates the concourse oidc client.
 func createOIDCClient(provider string) (string, string, error) {
-	params := []byte(fmt.Sprintf(`{"name": "%s", "provider": "%s"}`, componentName, provider))
+	params, err := functions.OIDCClientParams(componentName, provider, callbackPath)
+	if err != nil {
+		return "", "", err
+	}
 	result, err := functions.Call("create-oidc-client", params)
 	if err != nil {
 		return "", "", err
@@ -156,6 +286,23 @@ func createOIDCClient(provider string) (string, string, error) {
 	return v["clientId"].(string), v["clientSecret"].(string), nil
 }
 
+// updateOIDCClient updates the concourse oidc client redirect uris.
+func updateOIDCClient(provider string) error {
+	params, err := functions.OIDCClientParams(componentName, provider, callbackPath)
+	if err != nil {
+		return err
+	}
+	_, err = functions.Call("update-oidc-client", params)
+	return err
+}
+
+// deleteOIDCClient deletes the concourse oidc client.
+func deleteOIDCClient(provider string) error {
+	params := []byte(fmt.Sprintf(`{"name": "%s", "provider": "%s"}`, componentName, provider))
+	_, err := functions.Call("delete-oidc-client", params)
+	return err
+}
+
 // downloadFlyCLI downloads the concourse fly cli.
 func downloadFlyCLI(url string) (string, error) {
 	f, err := os.CreateTemp("", "fly-cli")
@@ -188,11 +335,79 @@ func createApplicationHandler(params map[string]interface{}) (interface{}, error
 	if !ok {
 		return nil, errors.New("toolchain is required")
 	}
-	config, err := rest.InClusterConfig()
+	// the registry mirror is optional.
+	registryMirror, _ := params["registryMirror"].(string)
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	// the fly cli is served by the in-cluster concourse web service, so
+	// it is not affected by the mirrors.
+	cli, err := downloadFlyCLI(serviceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(cli)
+	if err := createApplication(toolchain, name, registryMirror, clientset, cli, runFlyCmd); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// updateApplicationHandler downloads the fly cli and updates the
+// application pipeline and team with the current application inputs.
+func updateApplicationHandler(params map[string]interface{}) (interface{}, error) {
+	// the pipeline and team are set in place.
+	return createApplicationHandler(params)
+}
+
+// deleteApplicationHandler downloads the fly cli and destroys the
+// application team.
+func deleteApplicationHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	toolchain, ok := params["toolchain"].(string)
+	if !ok {
+		return nil, errors.New("toolchain is required")
+	}
+	clientset, err := newClientset()
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	cli, err := downloadFlyCLI(serviceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(cli)
+	return nil, deleteApplication(toolchain, name, clientset, cli, runFlyCmdOutput)
+}
+
+// deleteApplication destroys the application team and its pipeline.
+// Missing teams are ignored.
+func deleteApplication(toolchain, name string, clientset kubernetes.Interface, cli string, flyCmd func(cli string, args ...string) ([]byte, error)) error {
+	team := fmt.Sprintf("%s-%s", toolchain, name)
+	exists, err := teamExists(toolchain, team, clientset, cli, flyCmd)
+	if err != nil || !exists {
+		return err
+	}
+	_, err = flyCmd(cli, "destroy-team", "--team-name", team, "--non-interactive")
+	return err
+}
+
+// applicationStatusHandler downloads the fly cli and gets the
+// application pipeline status.
+func applicationStatusHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	toolchain, ok := params["toolchain"].(string)
+	if !ok {
+		return nil, errors.New("toolchain is required")
+	}
+	clientset, err := newClientset()
 	if err != nil {
 		return nil, err
 	}
@@ -201,17 +416,86 @@ func createApplicationHandler(params map[string]interface{}) (interface{}, error
 		return nil, err
 	}
 	defer os.Remove(cli)
-	if err := createApplication(toolchain, name, clientset, cli, runFlyCmd); err != nil {
+	return applicationStatus(toolchain, name, clientset, cli, runFlyCmdOutput)
+}
+
+// applicationStatus gets whether the application pipeline exists, is
+// paused, and the status of its latest build.
+func applicationStatus(toolchain, name string, clientset kubernetes.Interface, cli string, flyCmd func(cli string, args ...string) ([]byte, error)) (map[string]interface{}, error) {
+	status := map[string]interface{}{"exists": false}
+	team := fmt.Sprintf("%s-%s", toolchain, name)
+	exists, err := teamExists(toolchain, team, clientset, cli, flyCmd)
+	if err != nil || !exists {
+		return status, err
+	}
+	out, err := flyCmd(cli, "pipelines", "--team", team, "--json")
+	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	pipelines := []struct {
+		Name   string `json:"name"`
+		Paused bool   `json:"paused"`
+	}{}
+	if err := json.Unmarshal(out, &pipelines); err != nil {
+		return nil, err
+	}
+	for _, pipeline := range pipelines {
+		if pipeline.Name != name {
+			continue
+		}
+		status["exists"] = true
+		status["paused"] = pipeline.Paused
+		out, err := flyCmd(cli, "builds", "--team", team, "--pipeline", name, "--count", "1", "--json")
+		if err != nil {
+			return nil, err
+		}
+		builds := []struct {
+			Status string `json:"status"`
+		}{}
+		if err := json.Unmarshal(out, &builds); err != nil {
+			return nil, err
+		}
+		if len(builds) > 0 {
+			status["lastBuild"] = builds[0].Status
+		}
+	}
+	return status, nil
+}
+
+// teamExists logs in as the system user and checks if the team exists.
+func teamExists(toolchain, team string, clientset kubernetes.Interface, cli string, flyCmd func(cli string, args ...string) ([]byte, error)) (bool, error) {
+	pwd, err := getSystemUserPassword(fmt.Sprintf("trustacks-toolchain-%s", toolchain), clientset)
+	if err != nil {
+		return false, err
+	}
+	if _, err := flyCmd(cli, "login", "-c", serviceURL, "--username", "trustacks", "--password", pwd); err != nil {
+		return false, err
+	}
+	out, err := flyCmd(cli, "teams", "--json")
+	if err != nil {
+		return false, err
+	}
+	teams := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(out, &teams); err != nil {
+		return false, err
+	}
+	for _, existing := range teams {
+		if existing.Name == team {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 //go:embed pipeline.gotxt
 var pipelineTemplate string
 
-// createApplication creates the application pipeline.
-func createApplication(toolchain, name string, clientset kubernetes.Interface, cli string, flyCmd func(cli string, args ...string) error) error {
+// createApplication creates the application pipeline. The pipeline
+// resource type images are pulled from the registry mirror when it is
+// set.
+func createApplication(toolchain, name, registryMirror string, clientset kubernetes.Interface, cli string, flyCmd func(cli string, args ...string) error) error {
 	namespace := fmt.Sprintf("trustacks-toolchain-%s", toolchain)
 	if err := copyApplicationInputs(toolchain, name, clientset); err != nil {
 		return err
@@ -228,33 +512,25 @@ func createApplication(toolchain, name string, clientset kubernetes.Interface, c
 		return err
 	}
 	// create the pipeline template.
-	var tmplBuf bytes.Buffer
-	tmpl, err := template.New("pipeline").Parse(pipelineTemplate)
+	pipelineConfig, err := renderPipeline(vars, secrets, registryMirror)
 	if err != nil {
 		return err
 	}
-	if err = tmpl.Execute(&tmplBuf, map[string]interface{}{
-		"vars":    vars,
-		"secrets": secrets,
-	}); err != nil {
-		return err
-	}
 	pipeline, err := os.CreateTemp("", "pipeline")
 	if err != nil {
 		return err
 	}
 	defer os.Remove(pipeline.Name())
-	if _, err := pipeline.Write(tmplBuf.Bytes()); err != nil {
+	if _, err := pipeline.Write(pipelineConfig); err != nil {
 		return err
 	}
 	pipeline.Close()
 
 	// get the system user password.
-	webSecrets, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), "concourse-web", metav1.GetOptions{})
+	pwd, err := getSystemUserPassword(namespace, clientset)
 	if err != nil {
 		return err
 	}
-	pwd := strings.Split(string(webSecrets.Data["local-users"]), ":")[1]
 
 	// execute fly commands.
 	team := fmt.Sprintf("%s-%s", toolchain, name)
@@ -264,7 +540,7 @@ func createApplication(toolchain, name string, clientset kubernetes.Interface, c
 	if err := flyCmd(cli, "sync"); err != nil {
 		return err
 	}
-	if err := flyCmd(cli, "set-team", "--team-name", team, "--local-user", "trustacks", "--non-interactive"); err != nil {
+	if err := setTeam(team, cli, flyCmd); err != nil {
 		return err
 	}
 	if err := flyCmd(cli, "set-pipeline", "--team", team, "-p", name, "-c", pipeline.Name(), "--non-interactive", "--load-vars-from", varsFrom); err != nil {
@@ -273,6 +549,159 @@ func createApplication(toolchain, name string, clientset kubernetes.Interface, c
 	return flyCmd(cli, "unpause-pipeline", "-p", name, "--team", team)
 }
 
+// teamRoles maps the toolchain roles to the concourse team roles.
+var teamRoles = map[roles.Role]string{
+	roles.Admin:  "owner",
+	roles.Editor: "member",
+	roles.Viewer: "viewer",
+}
+
+// teamRoleAuth contains the users and groups of a team role.
+type teamRoleAuth struct {
+	Users  []string `yaml:"users,omitempty"`
+	Groups []string `yaml:"groups,omitempty"`
+}
+
+// teamRole is a concourse team role.
+type teamRole struct {
+	Name  string        `yaml:"name"`
+	Local *teamRoleAuth `yaml:"local,omitempty"`
+	OIDC  *teamRoleAuth `yaml:"oidc,omitempty"`
+}
+
+// teamConfig creates the fly set-team config of the application teams.
+// The system user owns the teams, and the sso groups are bound to the
+// team roles of the toolchain role model.
+func teamConfig() ([]byte, error) {
+	config := struct {
+		Roles []*teamRole `yaml:"roles"`
+	}{
+		Roles: []*teamRole{{Name: "owner", Local: &teamRoleAuth{Users: []string{"trustacks"}}}},
+	}
+	for _, binding := range roles.Bindings(teamRoles) {
+		var role *teamRole
+		for _, r := range config.Roles {
+			if r.Name == binding.Role {
+				role = r
+			}
+		}
+		if role == nil {
+			role = &teamRole{Name: binding.Role}
+			config.Roles = append(config.Roles, role)
+		}
+		if role.OIDC == nil {
+			role.OIDC = &teamRoleAuth{}
+		}
+		role.OIDC.Groups = append(role.OIDC.Groups, binding.Group)
+	}
+	return yaml.Marshal(config)
+}
+
+// setTeam creates or updates the team with the application team roles.
+func setTeam(team, cli string, flyCmd func(cli string, args ...string) error) error {
+	data, err := teamConfig()
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "team-config")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	f.Close()
+	return flyCmd(cli, "set-team", "--team-name", team, "--config", f.Name(), "--non-interactive")
+}
+
+// syncTeams updates the roles of every team except the main team,
+// whose roles are set by the chart values.
+func syncTeams(pwd, cli string, flyCmd func(cli string, args ...string) ([]byte, error)) error {
+	if _, err := flyCmd(cli, "login", "-c", serviceURL, "--username", "trustacks", "--password", pwd); err != nil {
+		return err
+	}
+	out, err := flyCmd(cli, "teams", "--json")
+	if err != nil {
+		return err
+	}
+	teams := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(out, &teams); err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if team.Name == "main" {
+			continue
+		}
+		err := setTeam(team.Name, cli, func(cli string, args ...string) error {
+			_, err := flyCmd(cli, args...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderPipeline renders the application pipeline config.
+func renderPipeline(vars, secrets []string, registryMirror string) ([]byte, error) {
+	var tmplBuf bytes.Buffer
+	tmpl, err := template.New("pipeline").Parse(pipelineTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err = tmpl.Execute(&tmplBuf, map[string]interface{}{
+		"vars":           vars,
+		"secrets":        secrets,
+		"registryMirror": registryMirror,
+	}); err != nil {
+		return nil, err
+	}
+	return tmplBuf.Bytes(), nil
+}
+
+// getSystemUserPassword gets the trustacks local user password.
+func getSystemUserPassword(namespace string, clientset kubernetes.Interface) (string, error) {
+	webSecrets, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), "concourse-web", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(string(webSecrets.Data["local-users"]), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("local user credentials not found")
+	}
+	return parts[1], nil
+}
+
+// destroyTeams destroys every team except the main team.
+func destroyTeams(pwd, cli string, flyCmd func(cli string, args ...string) ([]byte, error)) error {
+	if _, err := flyCmd(cli, "login", "-c", serviceURL, "--username", "trustacks", "--password", pwd); err != nil {
+		return err
+	}
+	out, err := flyCmd(cli, "teams", "--json")
+	if err != nil {
+		return err
+	}
+	teams := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(out, &teams); err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if team.Name == "main" {
+			continue
+		}
+		if _, err := flyCmd(cli, "destroy-team", "--team-name", team.Name, "--non-interactive"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // copyApplicationInputs copies the application variables and secrets
 // inputs to the application namespace.
 func copyApplicationInputs(toolchain, name string, clientset kubernetes.Interface) error {
@@ -355,15 +784,21 @@ func getApplicationVars(toolchain, name string, clientset kubernetes.Interface)
 	return vars, f.Name(), nil
 }
 
-// getApplicationSecrets gets the application secrets list.
+// getApplicationSecrets gets the application secrets list. The
+// system secrets are copied to the application secrets as plaintext,
+// since concourse resolves the ((application-secrets)) pipeline vars
+// from the application namespace secret as is, and the pipeline only
+// holds the toolchain age public key, which cannot decrypt them. The
+// copy lives in the application namespace, so it is left out of the
+// toolchain backup.
 func getApplicationSecrets(toolchain, name string, clientset kubernetes.Interface) ([]string, error) {
 	toolchainNamespace := fmt.Sprintf("trustacks-toolchain-%s", toolchain)
 	applicationNamespace := fmt.Sprintf("trustacks-application-%s-%s", toolchain, name)
-	systemSecrets, err := clientset.CoreV1().Secrets(toolchainNamespace).Get(context.TODO(), systemSecretsName, metav1.GetOptions{})
+	systemSecrets, err := inputs.GetSystemSecrets(toolchainNamespace, clientset)
 	if err != nil {
 		return nil, err
 	}
-	patch, err := json.Marshal(systemSecrets.Data)
+	patch, err := json.Marshal(systemSecrets)
 	if err != nil {
 		return nil, err
 	}
@@ -402,15 +837,141 @@ func setAgePublicKey(toolchain, name string, clientset kubernetes.Interface) err
 
 // flyCmd runs the fly command with the provided arguments.
 func runFlyCmd(cli string, args ...string) error {
+	_, err := runFlyCmdOutput(cli, args...)
+	return err
+}
+
+// runFlyCmdOutput runs the fly command with the provided arguments
+// and returns the command output.
+var runFlyCmdOutput = func(cli string, args ...string) ([]byte, error) {
 	args = append([]string{"-t", "default"}, args...)
 	var outBuf, errBuf bytes.Buffer
 	command := exec.Command(cli, args...)
 	command.Stdout = &outBuf
 	command.Stderr = &errBuf
 	if err := command.Run(); err != nil {
-		return fmt.Errorf("%s: %s", err.Error(), errBuf.String())
+		return nil, fmt.Errorf("%s: %s", err.Error(), errBuf.String())
 	}
-	return nil
+	return outBuf.Bytes(), nil
+}
+
+// preUpgrade snapshots the hook managed resources, updates the oidc
+// client redirect uris and runs the pending chart migrations.
+func (c *concourse) preUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	// the hook is rendered for the upgrade revision, so the current
+	// state belongs to the previous revision.
+	logging.Info("snapshot hook managed resources")
+	if err := snapshots.Create(componentName, revision-1, snapshotSpec, namespace, clientset); err != nil {
+		return err
+	}
+	logging.Info("update oidc client redirect uris")
+	if err := updateOIDCClient(os.Getenv("SSO_PROVIDER")); err != nil {
+		return err
+	}
+	_, err = migrations.Run(componentName, namespace, false, clientset)
+	return err
+}
+
+// postUpgrade updates the application team roles.
+func (c *concourse) postUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	pwd, err := getSystemUserPassword(namespace, clientset)
+	if err != nil {
+		return err
+	}
+	cli, err := downloadFlyCLI(serviceURL)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(cli)
+	logging.Info("sync application team roles")
+	return syncTeams(pwd, cli, runFlyCmdOutput)
+}
+
+// postRollback restores the hook managed resources of the revision
+// rolled back to.
+func (c *concourse) postRollback() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	// rollbacks run the hooks rendered for the target revision.
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	logging.Info("restore hook managed resources")
+	return snapshots.Restore(componentName, revision, namespace, clientset)
+}
+
+// migrateHandler runs or lists the pending chart migrations.
+func migrateHandler(params map[string]interface{}) (interface{}, error) {
+	dryRun, _ := params["dryRun"].(bool)
+	return runMigrations(dryRun)
+}
+
+// RotateSecrets rotates the component credentials.
+func (c *concourse) RotateSecrets(params map[string]interface{}) (interface{}, error) {
+	return rotateSecretsHandler(params)
+}
+
+// Migrate runs or lists the pending chart migrations.
+func (c *concourse) Migrate(params map[string]interface{}) (interface{}, error) {
+	return migrateHandler(params)
+}
+
+// runMigrations runs the pending chart migrations and returns their
+// names.
+func runMigrations(dryRun bool) ([]string, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := migrations.Run(componentName, namespace, dryRun, clientset)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(pending))
+	for i, m := range pending {
+		names[i] = m.Name()
+	}
+	return names, nil
+}
+
+// newClientset creates the in-cluster kubernetes clientset.
+var newClientset = func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
 }
 
 // getNamespace gets the current kubernetes namespace.
@@ -435,29 +996,38 @@ var applicationHookManifests []byte
 func Initialize(c *catalog.ComponentCatalog) {
 	var conf *catalog.ComponentConfig
 	if err := yaml.Unmarshal(config, &conf); err != nil {
-		log.Fatal(err)
+		logging.Fatal("error loading the component config", "error", err)
 	}
 	component := &concourse{
 		catalog.BaseComponent{
 			Repo:             conf.Repo,
+			MirrorRepo:       conf.MirrorRepository(),
 			Chart:            conf.Chart,
 			Version:          conf.Version,
 			Values:           conf.Values,
 			Hooks:            string(hookManifests),
 			ApplicationHooks: string(applicationHookManifests),
+			Artifacts:        conf.Artifacts(),
 		},
 	}
 	c.AddComponent(componentName, component)
+	migrations.Register(componentName, conf.Version)
 
 	// configure hooks.
 	for hook, fn := range map[string]func() error{
 		hooks.PreInstallHook: component.preInstall,
+		hooks.PreDeleteHook:  component.preDelete,
+		hooks.PostDeleteHook: component.postDelete,
+		hooks.PreUpgrade:     component.preUpgrade,
+		hooks.PostUpgrade:    component.postUpgrade,
+		hooks.PostRollback:   component.postRollback,
 	} {
 		if err := hooks.AddHook(componentName, hook, fn); err != nil {
-			log.Fatal(err)
+			logging.Fatal("error adding the component hook", "hook", hook, "error", err)
 		}
 	}
 
 	// configure functions.
-	functions.AddCreateApplicationHandler("concourse", createApplicationHandler)
+	functions.RegisterCIProvider(componentName, component)
+	functions.RegisterComponent(componentName, component)
 }
@@ -0,0 +1,64 @@
+package concourse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// fakeFly is an in-memory stand-in for the fly cli commands of the
+// functions. It keeps the teams and their pipelines.
+type fakeFly struct {
+	teams map[string]map[string]bool
+}
+
+// newFakeFly creates the fake fly cli with the main team.
+func newFakeFly() *fakeFly {
+	return &fakeFly{teams: map[string]map[string]bool{"main": {}}}
+}
+
+// run runs the fly command. The command flags are parsed as flag value
+// pairs, except for the flags that are followed by another flag.
+func (f *fakeFly) run(cli string, args ...string) ([]byte, error) {
+	flags := map[string]string{}
+	for i := 1; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			flags[args[i]] = args[i+1]
+			i++
+		}
+	}
+	switch args[0] {
+	case "login", "sync", "unpause-pipeline":
+		return nil, nil
+	case "set-team":
+		if _, ok := f.teams[flags["--team-name"]]; !ok {
+			f.teams[flags["--team-name"]] = map[string]bool{}
+		}
+		return nil, nil
+	case "set-pipeline":
+		pipelines, ok := f.teams[flags["--team"]]
+		if !ok {
+			return nil, fmt.Errorf("team '%s' not found", flags["--team"])
+		}
+		pipelines[flags["-p"]] = true
+		return nil, nil
+	case "destroy-team":
+		delete(f.teams, flags["--team-name"])
+		return nil, nil
+	case "teams":
+		teams := []map[string]string{}
+		for name := range f.teams {
+			teams = append(teams, map[string]string{"name": name})
+		}
+		return json.Marshal(teams)
+	case "pipelines":
+		pipelines := []map[string]interface{}{}
+		for name := range f.teams[flags["--team"]] {
+			pipelines = append(pipelines, map[string]interface{}{"name": name, "paused": false})
+		}
+		return json.Marshal(pipelines)
+	case "builds":
+		return []byte(`[{"id": 1, "status": "succeeded"}]`), nil
+	}
+	return nil, fmt.Errorf("unknown command '%s'", args[0])
+}
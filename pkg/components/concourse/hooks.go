@@ -0,0 +1,51 @@
+package concourse
+
+import (
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/snapshots"
+)
+
+// hookManifest declares the component hooks and their permissions.
+// hooks.yaml is generated from it.
+var hookManifest = hooks.Manifest{
+	Component: componentName,
+	Jobs: []hooks.Job{
+		{
+			Hook: hooks.PreInstallHook,
+			Env:  append([]hooks.EnvVar{hooks.SSOProviderEnv}, hooks.IngressEnv...),
+			Rules: hooks.JoinRules(
+				functions.SSOProviderRules(componentName),
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"create"}}},
+				migrations.Rules,
+			),
+		},
+		{
+			Hook:  hooks.PreDeleteHook,
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		{
+			Hook: hooks.PostDeleteHook,
+			Env:  []hooks.EnvVar{hooks.SSOProviderEnv},
+			Rules: hooks.JoinRules(
+				functions.SSOProviderRules(componentName),
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"delete"}}},
+			),
+		},
+		{
+			Hook:  hooks.PreUpgrade,
+			Env:   append([]hooks.EnvVar{hooks.ReleaseRevisionEnv, hooks.SSOProviderEnv}, hooks.IngressEnv...),
+			Rules: hooks.JoinRules(functions.SSOProviderRules(componentName), snapshots.CreateRules, migrations.Rules),
+		},
+		{
+			Hook:  hooks.PostUpgrade,
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		{
+			Hook:  hooks.PostRollback,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: snapshotSpec.RestoreRules(),
+		},
+	},
+}
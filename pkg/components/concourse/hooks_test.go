@@ -0,0 +1,28 @@
+package concourse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/hooks/hookstest"
+)
+
+func TestHookManifests(t *testing.T) {
+	hookstest.CheckManifest(t, hookManifest, "hooks.yaml")
+}
+
+func TestApplicationHookJobSpec(t *testing.T) {
+	// the application hook jobs use the same pod settings as the
+	// generated hook jobs.
+	for _, param := range []string{
+		"hookResources",
+		"hookNodeSelector",
+		"hookTolerations",
+		"hookImagePullSecrets",
+		"hookSecurityContext",
+		"hookBackoffLimit",
+		"hookActiveDeadlineSeconds",
+	} {
+		assert.Contains(t, string(applicationHookManifests), "{{ ."+param+" }}", "expected the %s parameter in the application hooks", param)
+	}
+}
@@ -0,0 +1,21 @@
+package concourse
+
+// CreateApplication creates the application pipeline and team.
+func (c *concourse) CreateApplication(params map[string]interface{}) (interface{}, error) {
+	return createApplicationHandler(params)
+}
+
+// UpdateApplication updates the application pipeline and team.
+func (c *concourse) UpdateApplication(params map[string]interface{}) (interface{}, error) {
+	return updateApplicationHandler(params)
+}
+
+// DeleteApplication destroys the application team and pipeline.
+func (c *concourse) DeleteApplication(params map[string]interface{}) (interface{}, error) {
+	return deleteApplicationHandler(params)
+}
+
+// ApplicationStatus gets the application pipeline status.
+func (c *concourse) ApplicationStatus(params map[string]interface{}) (interface{}, error) {
+	return applicationStatusHandler(params)
+}
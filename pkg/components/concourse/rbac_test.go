@@ -0,0 +1,175 @@
+package concourse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
+	"github.com/trustacks/catalog/pkg/rbac"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// initializeOnce adds the component hooks once per test binary.
+var initializeOnce sync.Once
+
+// flyCLIStub is a fly cli that succeeds without output, except for
+// the teams list.
+const flyCLIStub = `#!/bin/sh
+case " $* " in
+*" teams "*) echo '[]' ;;
+esac
+`
+
+// patchFlyCLI serves the fly cli stub as the concourse service.
+func patchFlyCLI() func() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(flyCLIStub))
+	}))
+	previousServiceURL := serviceURL
+	serviceURL = ts.URL
+	return func() {
+		serviceURL = previousServiceURL
+		ts.Close()
+	}
+}
+
+func TestHookRBAC(t *testing.T) {
+	defer patchFlyCLI()()
+
+	// patch the in cluster namespace file.
+	f, err := os.CreateTemp("", "in-cluster-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	previousInClusterNamespace := inClusterNamespace
+	inClusterNamespace = f.Name()
+	defer func() {
+		os.Remove(f.Name())
+		inClusterNamespace = previousInClusterNamespace
+	}()
+
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	previousNewClientset := newClientset
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	defer func() { newClientset = previousNewClientset }()
+
+	defer functions.PatchMockFunction("create-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"clientId": "test-id", "clientSecret": "test-secret"}, nil
+	})()
+	defer functions.PatchMockFunction("delete-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	})()
+	defer functions.PatchMockFunction("update-oidc-client", func(params map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	})()
+
+	initializeOnce.Do(func() {
+		cat, err := catalog.NewComponentCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		Initialize(cat)
+	})
+	manifests, err := rbac.RenderManifests(string(hookManifests), map[string]interface{}{"image": "test", "sso": "authentik"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the hooks through the release lifecycle.
+	var grants []rbac.Grant
+	accesses := []rbac.Access{}
+	for _, step := range []struct {
+		hook     string
+		revision string
+	}{
+		{hooks.PreInstallHook, "1"},
+		{hooks.PreUpgrade, "2"},
+		{hooks.PostUpgrade, "2"},
+		{hooks.PostRollback, "1"},
+		{hooks.PreDeleteHook, "3"},
+		{hooks.PostDeleteHook, "3"},
+	} {
+		t.Setenv("RELEASE_REVISION", step.revision)
+		hookAccesses, err := rbac.Record(clientset, func() error { return hooks.Call(componentName, step.hook) })
+		if err != nil {
+			t.Fatalf("%s: %s", step.hook, err)
+		}
+		grants, err = rbac.Grants(manifests, "test", componentName+"-"+step.hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := rbac.Verify(grants, hookAccesses)
+		assert.Empty(t, report.Missing, "%s is missing permissions:\n%s", step.hook, report)
+		accesses = append(accesses, hookAccesses...)
+	}
+
+	// every hook job shares the role. restore patches the component
+	// keys of the shared system secrets. concourse adds none, but the
+	// snapshot rules are shared by every component. the sso provider
+	// functions are mocked, so the grants on the authentik secrets are
+	// unused.
+	report := rbac.Verify(grants, accesses)
+	unused := []rbac.Grant{}
+	for _, grant := range report.Unused {
+		if !strings.HasPrefix(grant.Name, "authentik-") {
+			unused = append(unused, grant)
+		}
+	}
+	assert.Equal(t, []rbac.Grant{{Namespace: "test", Resource: "secrets", Verb: "patch"}}, unused, "got unexpected unused grants:\n%s", report)
+}
+
+func TestApplicationHookRBAC(t *testing.T) {
+	defer patchFlyCLI()()
+
+	// the toolchain inputs and concourse secrets exist before the
+	// application is created.
+	namespace := "trustacks-toolchain-test"
+	clientset := fake.NewSimpleClientset(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "system-vars", Namespace: namespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "application-test-vars", Namespace: namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "system-secrets", Namespace: namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "application-test-secrets", Namespace: namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sops-age", Namespace: namespace}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "concourse-web", Namespace: namespace},
+			Data:       map[string][]byte{"local-users": []byte("trustacks:test")},
+		},
+	)
+	previousNewClientset := newClientset
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	defer func() { newClientset = previousNewClientset }()
+
+	manifests, err := rbac.RenderManifests(string(applicationHookManifests), map[string]interface{}{"image": "test", "toolchain": "test", "application": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accesses, err := rbac.Record(clientset, func() error {
+		_, err := createApplicationHandler(map[string]interface{}{"provider": "concourse", "toolchain": "test", "name": "test"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := rbac.Grants(manifests, namespace, "application-test-post-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := rbac.Verify(grants, accesses)
+	assert.Empty(t, report.Missing, "create-application is missing permissions:\n%s", report)
+	assert.Empty(t, report.Unused, "got unused grants:\n%s", report)
+}
@@ -0,0 +1,530 @@
+package dex
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sethvargo/go-password/password"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/rollout"
+	"github.com/trustacks/catalog/pkg/snapshots"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// componentName is the name of the component.
+	componentName = "dex"
+	// deploymentName is the dex deployment restarted when the config
+	// changes.
+	deploymentName = "dex"
+	// configKey is the config secret key read by dex.
+	configKey = "config.yaml"
+)
+
+var (
+	// inClusterNamespace is the path to the in-cluster namespace.
+	inClusterNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// configSecret is the dex config secret. It is managed by the hooks
+// and functions instead of the chart so the static clients are kept
+// across upgrades.
+var configSecret = "dex-config"
+
+type dex struct {
+	catalog.BaseComponent
+}
+
+// snapshotSpec contains the resources managed by the component hooks.
+var snapshotSpec = snapshots.Spec{
+	Secrets: []string{configSecret},
+}
+
+// staticClient is a dex static oauth2 client.
+type staticClient struct {
+	ID           string   `yaml:"id"`
+	Name         string   `yaml:"name"`
+	Secret       string   `yaml:"secret"`
+	RedirectURIs []string `yaml:"redirectURIs,omitempty"`
+}
+
+// dexConfig contains the dex config fields. The storage, web and
+// oauth2 fields are set by the hooks.
+type dexConfig struct {
+	Issuer        string                   `yaml:"issuer"`
+	Storage       map[string]interface{}   `yaml:"storage"`
+	Web           map[string]interface{}   `yaml:"web"`
+	OAuth2        map[string]interface{}   `yaml:"oauth2"`
+	Connectors    []map[string]interface{} `yaml:"connectors"`
+	StaticClients []staticClient           `yaml:"staticClients"`
+}
+
+// newConfig creates the dex config with the issuer and the upstream
+// identity provider connectors.
+func newConfig(issuer, connectors string) (*dexConfig, error) {
+	config := &dexConfig{
+		Issuer: issuer,
+		Storage: map[string]interface{}{
+			"type":   "kubernetes",
+			"config": map[string]interface{}{"inCluster": true},
+		},
+		Web:           map[string]interface{}{"http": "0.0.0.0:5556"},
+		OAuth2:        map[string]interface{}{"skipApprovalScreen": true},
+		Connectors:    []map[string]interface{}{},
+		StaticClients: []staticClient{},
+	}
+	if connectors != "" {
+		if err := json.Unmarshal([]byte(connectors), &config.Connectors); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// getConfig gets the dex config from the config secret.
+func getConfig(namespace string, clientset kubernetes.Interface) (*corev1.Secret, *dexConfig, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), configSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	config := &dexConfig{}
+	if err := yaml.Unmarshal(secret.Data[configKey], config); err != nil {
+		return nil, nil, err
+	}
+	for _, client := range config.StaticClients {
+		logging.AddSecret(client.Secret)
+	}
+	return secret, config, nil
+}
+
+// updateConfig writes the dex config to the config secret.
+func updateConfig(namespace string, secret *corev1.Secret, config *dexConfig, clientset kubernetes.Interface) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[configKey] = data
+	_, err = clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	return err
+}
+
+// modifyConfig reads the dex config, applies modify and writes the
+// config back. The config is read again when the secret was updated
+// since it was read, so concurrent modifications are not lost. The
+// config is left untouched when modify reports no change.
+func modifyConfig(namespace string, clientset kubernetes.Interface, modify func(config *dexConfig) (bool, error)) (bool, error) {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, config, err := getConfig(namespace, clientset)
+		if err != nil {
+			return err
+		}
+		changed, err = modify(config)
+		if err != nil || !changed {
+			return err
+		}
+		return updateConfig(namespace, secret, config, clientset)
+	})
+	return changed, err
+}
+
+// applyConfig creates the config secret, or updates the issuer and
+// connectors of the existing config. The static clients are kept.
+func applyConfig(namespace, issuer, connectors string, clientset kubernetes.Interface) error {
+	config, err := newConfig(issuer, connectors)
+	if err != nil {
+		return err
+	}
+	_, err = modifyConfig(namespace, clientset, func(existing *dexConfig) (bool, error) {
+		config.StaticClients = existing.StaticClients
+		*existing = *config
+		return true, nil
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return err
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: configSecret,
+				Labels: map[string]string{
+					"app.kubernetes.io/part-of": componentName,
+				},
+			},
+			Data: map[string][]byte{configKey: data},
+		}
+		_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		return err
+	}
+	return nil
+}
+
+// preInstall creates the dex config.
+func (c *dex) preInstall() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("create dex config")
+	if err := applyConfig(namespace, os.Getenv("DEX_ISSUER"), os.Getenv("DEX_CONNECTORS"), clientset); err != nil {
+		return err
+	}
+	return migrations.SetVersion(componentName, namespace, clientset)
+}
+
+// preUpgrade snapshots the hook managed resources, updates the dex
+// config and runs the pending chart migrations.
+func (c *dex) preUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	// the hook is rendered for the upgrade revision, so the current
+	// state belongs to the previous revision.
+	logging.Info("snapshot hook managed resources")
+	if err := snapshots.Create(componentName, revision-1, snapshotSpec, namespace, clientset); err != nil {
+		return err
+	}
+	logging.Info("update dex config")
+	if err := applyConfig(namespace, os.Getenv("DEX_ISSUER"), os.Getenv("DEX_CONNECTORS"), clientset); err != nil {
+		return err
+	}
+	_, err = migrations.Run(componentName, namespace, false, clientset)
+	return err
+}
+
+// postUpgrade restarts dex to load the updated config.
+func (c *dex) postUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("restart dex")
+	return rollout.RestartDeployment(namespace, deploymentName, clientset)
+}
+
+// postRollback restores the hook managed resources of the revision
+// rolled back to and restarts dex to load the restored config.
+func (c *dex) postRollback() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	// rollbacks run the hooks rendered for the target revision.
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	logging.Info("restore hook managed resources")
+	if err := snapshots.Restore(componentName, revision, namespace, clientset); err != nil {
+		return err
+	}
+	return rollout.RestartDeployment(namespace, deploymentName, clientset)
+}
+
+// postDelete removes the config secret.
+func (c *dex) postDelete() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("delete dex config")
+	err = clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), configSecret, metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
+
+// createOIDCClientHandler creates the oidc client.
+func createOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	rotate, _ := params["rotate"].(bool)
+	return createOIDCClient(name, redirectURIs, rotate, namespace, clientset)
+}
+
+// createOIDCClient adds a static client to the dex config and restarts
+// dex. The dex config secret stores the client credentials, so an
+// existing client keeps its secret and only its redirect uris are
+// updated. The client secret is regenerated when rotate is set.
+func createOIDCClient(name string, redirectURIs []string, rotate bool, namespace string, clientset kubernetes.Interface) (map[string]interface{}, error) {
+	clientSecret := ""
+	_, err := modifyConfig(namespace, clientset, func(config *dexConfig) (bool, error) {
+		clientSecret = ""
+		clients := []staticClient{}
+		for _, client := range config.StaticClients {
+			if client.ID != name {
+				clients = append(clients, client)
+			} else if !rotate {
+				clientSecret = client.Secret
+			}
+		}
+		if clientSecret == "" {
+			generated, err := password.Generate(128, 96, 0, false, true)
+			if err != nil {
+				return false, err
+			}
+			clientSecret = generated
+		}
+		logging.AddSecret(clientSecret)
+		config.StaticClients = append(clients, staticClient{ID: name, Name: name, Secret: clientSecret, RedirectURIs: redirectURIs})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := rollout.RestartDeployment(namespace, deploymentName, clientset); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"clientId": name, "clientSecret": clientSecret}, nil
+}
+
+// getOIDCClientHandler gets the oidc client.
+func getOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	client, err := getOIDCClient(name, namespace, clientset)
+	if client == nil || err != nil {
+		// a nil map is not a nil result.
+		return nil, err
+	}
+	return client, nil
+}
+
+// getOIDCClient gets the credentials and the redirect uris of the
+// static client. A nil client is returned when it does not exist.
+func getOIDCClient(name, namespace string, clientset kubernetes.Interface) (map[string]interface{}, error) {
+	_, config, err := getConfig(namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range config.StaticClients {
+		if client.ID == name {
+			redirectURIs := client.RedirectURIs
+			if redirectURIs == nil {
+				redirectURIs = []string{}
+			}
+			return map[string]interface{}{"clientId": client.ID, "clientSecret": client.Secret, "redirectUris": redirectURIs}, nil
+		}
+	}
+	return nil, nil
+}
+
+// updateOIDCClientHandler updates the oidc client redirect uris.
+func updateOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	return nil, updateOIDCClient(name, redirectURIs, namespace, clientset)
+}
+
+// updateOIDCClient sets the redirect uris of the static client and
+// restarts dex.
+func updateOIDCClient(name string, redirectURIs []string, namespace string, clientset kubernetes.Interface) error {
+	_, err := modifyConfig(namespace, clientset, func(config *dexConfig) (bool, error) {
+		found := false
+		for i, client := range config.StaticClients {
+			if client.ID == name {
+				config.StaticClients[i].RedirectURIs = redirectURIs
+				found = true
+			}
+		}
+		if !found {
+			return false, fmt.Errorf("oidc client '%s' not found", name)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	return rollout.RestartDeployment(namespace, deploymentName, clientset)
+}
+
+// deleteOIDCClientHandler deletes the oidc client.
+func deleteOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	return nil, deleteOIDCClient(name, namespace, clientset)
+}
+
+// deleteOIDCClient removes the static client from the dex config and
+// restarts dex. Clients that do not exist are ignored.
+func deleteOIDCClient(name, namespace string, clientset kubernetes.Interface) error {
+	changed, err := modifyConfig(namespace, clientset, func(config *dexConfig) (bool, error) {
+		clients := []staticClient{}
+		for _, client := range config.StaticClients {
+			if client.ID != name {
+				clients = append(clients, client)
+			}
+		}
+		if len(clients) == len(config.StaticClients) {
+			return false, nil
+		}
+		config.StaticClients = clients
+		return true, nil
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	return rollout.RestartDeployment(namespace, deploymentName, clientset)
+}
+
+// newClientset creates the in-cluster kubernetes clientset.
+var newClientset = func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// getNamespace gets the current kubernetes namespace.
+func getNamespace() (string, error) {
+	data, err := os.ReadFile(inClusterNamespace)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+//go:embed config.yaml
+var config []byte
+
+//go:embed hooks.yaml
+var hookManifests []byte
+
+// Initialize adds the component to the catalog and configures hooks.
+func Initialize(c *catalog.ComponentCatalog) {
+	var conf *catalog.ComponentConfig
+	if err := yaml.Unmarshal(config, &conf); err != nil {
+		logging.Fatal("error loading the component config", "error", err)
+	}
+	component := &dex{
+		catalog.BaseComponent{
+			Repo:       conf.Repo,
+			MirrorRepo: conf.MirrorRepository(),
+			Chart:      conf.Chart,
+			Version:    conf.Version,
+			Values:     conf.Values,
+			Hooks:      string(hookManifests),
+			Artifacts:  conf.Artifacts(),
+		},
+	}
+	c.AddComponent(componentName, component)
+	migrations.Register(componentName, conf.Version)
+
+	// configure hooks.
+	for hook, fn := range map[string]func() error{
+		hooks.PreInstallHook: component.preInstall,
+		hooks.PostDeleteHook: component.postDelete,
+		hooks.PreUpgrade:     component.preUpgrade,
+		hooks.PostUpgrade:    component.postUpgrade,
+		hooks.PostRollback:   component.postRollback,
+	} {
+		if err := hooks.AddHook(componentName, hook, fn); err != nil {
+			logging.Fatal("error adding the component hook", "hook", hook, "error", err)
+		}
+	}
+
+	// configure functions.
+	functions.RegisterSSOProvider(componentName, component)
+}
@@ -0,0 +1,272 @@
+package dex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions/providertest"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetChart(t *testing.T) {
+	var conf *catalog.ComponentConfig
+	if err := yaml.Unmarshal(config, &conf); err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/%s-%s.tgz", conf.Repo, conf.Chart, conf.Version)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatal("failed retrieving the helm chart")
+	}
+}
+
+// newTestClientset creates a fake clientset with the dex deployment.
+func newTestClientset(t *testing.T) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName}}
+	if _, err := clientset.AppsV1().Deployments("test").Create(context.TODO(), deployment, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return clientset
+}
+
+// restarted reports whether the dex deployment was restarted.
+func restarted(t *testing.T, clientset *fake.Clientset) bool {
+	deployment, err := clientset.AppsV1().Deployments("test").Get(context.TODO(), deploymentName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := deployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]
+	return ok
+}
+
+func TestApplyConfig(t *testing.T) {
+	clientset := newTestClientset(t)
+	connectors := `[{"type": "github", "id": "github", "name": "GitHub", "config": {"clientID": "test"}}]`
+	if err := applyConfig("test", "https://dex.local.gd", connectors, clientset); err != nil {
+		t.Fatal(err)
+	}
+	_, config, err := getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://dex.local.gd", config.Issuer)
+	assert.Equal(t, "kubernetes", config.Storage["type"])
+	assert.Equal(t, "github", config.Connectors[0]["type"])
+	assert.Empty(t, config.StaticClients)
+
+	// check that the static clients are kept when the config is
+	// updated.
+	if _, err := createOIDCClient("test", nil, false, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig("test", "https://dex.local.gd:8443", "[]", clientset); err != nil {
+		t.Fatal(err)
+	}
+	_, config, err = getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://dex.local.gd:8443", config.Issuer)
+	assert.Empty(t, config.Connectors)
+	assert.Len(t, config.StaticClients, 1, "expected the static clients to be kept")
+
+	if err := applyConfig("test", "https://dex.local.gd", "invalid", clientset); err == nil {
+		t.Fatal("expected a connectors parsing error")
+	}
+}
+
+func TestOIDCClient(t *testing.T) {
+	clientset := newTestClientset(t)
+	if err := applyConfig("test", "https://dex.local.gd", "[]", clientset); err != nil {
+		t.Fatal(err)
+	}
+	redirectURIs := []string{"https://concourse.local.gd/sky/issuer/callback"}
+	result, err := createOIDCClient("concourse", redirectURIs, false, "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "concourse", result["clientId"], "got an unexpected client id")
+	assert.Len(t, result["clientSecret"], 128, "expected a 128 character secret")
+	assert.True(t, restarted(t, clientset), "expected dex to be restarted")
+	_, config, err := getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []staticClient{{ID: "concourse", Name: "concourse", Secret: result["clientSecret"].(string), RedirectURIs: redirectURIs}}, config.StaticClients)
+
+	// the redirect uris follow the ingress parameters.
+	if err := updateOIDCClient("concourse", []string{"http://concourse.local.gd:8081/sky/issuer/callback"}, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	_, config, err = getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"http://concourse.local.gd:8081/sky/issuer/callback"}, config.StaticClients[0].RedirectURIs)
+	assert.Equal(t, result["clientSecret"], config.StaticClients[0].Secret, "expected the client secret to be kept")
+	if err := updateOIDCClient("missing", nil, "test", clientset); err == nil {
+		t.Fatal("expected a missing client error")
+	}
+
+	// an existing client keeps its secret.
+	reused, err := createOIDCClient("concourse", redirectURIs, false, "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, result, reused, "expected the existing credentials")
+	if _, err := createOIDCClient("argo-cd", nil, false, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	_, config, err = getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, config.StaticClients, 2)
+	assert.Equal(t, redirectURIs, config.StaticClients[0].RedirectURIs, "expected the redirect uris to be updated")
+
+	// rotation regenerates the client secret.
+	rotated, err := createOIDCClient("concourse", redirectURIs, true, "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, result["clientSecret"], rotated["clientSecret"], "expected the client secret to be rotated")
+	_, config, err = getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, config.StaticClients, 2)
+	assert.Equal(t, rotated["clientSecret"], config.StaticClients[1].Secret)
+
+	if err := deleteOIDCClient("concourse", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	_, config, err = getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "argo-cd", config.StaticClients[0].ID)
+	assert.Len(t, config.StaticClients, 1, "expected the client to be deleted")
+
+	// missing clients and configs are ignored.
+	if err := deleteOIDCClient("concourse", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteOIDCClient("concourse", "other", clientset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addResourceVersionReactor rejects the secret updates of a stale
+// resource version with a conflict, as the api server does.
+func addResourceVersionReactor(clientset *fake.Clientset) {
+	var mu sync.Mutex
+	gvr := corev1.SchemeGroupVersion.WithResource("secrets")
+	clientset.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		secret := action.(k8stesting.UpdateAction).GetObject().(*corev1.Secret).DeepCopy()
+		current, err := clientset.Tracker().Get(gvr, action.GetNamespace(), secret.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		if current.(*corev1.Secret).ResourceVersion != secret.ResourceVersion {
+			return true, nil, apierrors.NewConflict(gvr.GroupResource(), secret.Name, errors.New("the object has been modified"))
+		}
+		version, _ := strconv.Atoi(secret.ResourceVersion)
+		secret.ResourceVersion = strconv.Itoa(version + 1)
+		return true, secret, clientset.Tracker().Update(gvr, secret, action.GetNamespace())
+	})
+}
+
+func TestCreateOIDCClientConcurrently(t *testing.T) {
+	clientset := newTestClientset(t)
+	addResourceVersionReactor(clientset)
+	if err := applyConfig("test", "https://dex.local.gd", "[]", clientset); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"argo-cd", "concourse", "sonarqube", "grafana", "harbor"}
+	// another client is created between the first read of the config
+	// and its update, so the first update conflicts.
+	gvr := corev1.SchemeGroupVersion.WithResource("secrets")
+	interfered := false
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := clientset.Tracker().Get(gvr, action.GetNamespace(), configSecret)
+		if err != nil || interfered {
+			return true, obj, err
+		}
+		interfered = true
+		secret := obj.(*corev1.Secret).DeepCopy()
+		config := &dexConfig{}
+		if err := yaml.Unmarshal(secret.Data[configKey], config); err != nil {
+			return true, nil, err
+		}
+		config.StaticClients = append(config.StaticClients, staticClient{ID: "other", Name: "other"})
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return true, nil, err
+		}
+		secret.Data[configKey] = data
+		version, _ := strconv.Atoi(secret.ResourceVersion)
+		secret.ResourceVersion = strconv.Itoa(version + 1)
+		return true, obj, clientset.Tracker().Update(gvr, secret, action.GetNamespace())
+	})
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			_, err := createOIDCClient(name, nil, false, "test", clientset)
+			errs <- err
+		}(name)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, config, err := getConfig("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, client := range config.StaticClients {
+		ids = append(ids, client.ID)
+	}
+	assert.ElementsMatch(t, append(names, "other"), ids, "expected no client to be lost")
+}
+
+func TestSSOProviderContract(t *testing.T) {
+	clientset := newTestClientset(t)
+	patchEnvironment(t, clientset)
+	if err := applyConfig("test", "https://dex.local.gd", "[]", clientset); err != nil {
+		t.Fatal(err)
+	}
+	providertest.SSOProviderRBAC(t, &dex{}, componentName, "test", clientset)
+}
+
+func TestValuesNames(t *testing.T) {
+	// the hooks use the chart deployment and config secret names.
+	assert.Contains(t, string(config), "fullnameOverride: "+deploymentName)
+	assert.Contains(t, string(config), "name: "+configSecret)
+}
@@ -0,0 +1,59 @@
+package dex
+
+import (
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/snapshots"
+)
+
+var (
+	// issuerEnv passes the dex issuer url to the hook.
+	issuerEnv = hooks.EnvVar{
+		Name:  "DEX_ISSUER",
+		Value: `"{{- if eq .tls "true" -}}https{{- else -}}http{{- end -}}://dex.{{ .domain }}{{- if and (ne .ingressPort "443") (ne .ingressPort "80") -}}:{{ .ingressPort }}{{- end -}}"`,
+	}
+	// connectorsEnv passes the upstream identity provider connectors
+	// to the hook. They contain the connector client secrets, so they
+	// are passed through the hook env secret.
+	connectorsEnv = hooks.EnvVar{Name: "DEX_CONNECTORS", Value: "{{ .dexConnectors | toJson }}", Secret: true}
+	// restartRules are the permissions used to restart dex.
+	restartRules = []hooks.Rule{{APIGroup: "apps", Resources: []string{"deployments"}, Verbs: []string{"patch"}}}
+)
+
+// hookManifest declares the component hooks and their permissions.
+// hooks.yaml is generated from it.
+var hookManifest = hooks.Manifest{
+	Component: componentName,
+	Jobs: []hooks.Job{
+		{
+			Hook: hooks.PreInstallHook,
+			Env:  []hooks.EnvVar{issuerEnv, connectorsEnv},
+			Rules: hooks.JoinRules(
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get", "create", "update"}}},
+				migrations.Rules,
+			),
+		},
+		{
+			Hook:  hooks.PostDeleteHook,
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"delete"}}},
+		},
+		{
+			Hook: hooks.PreUpgrade,
+			Env:  []hooks.EnvVar{hooks.ReleaseRevisionEnv, issuerEnv, connectorsEnv},
+			Rules: hooks.JoinRules(
+				snapshots.CreateRules,
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get", "update"}}},
+				migrations.Rules,
+			),
+		},
+		{
+			Hook:  hooks.PostUpgrade,
+			Rules: restartRules,
+		},
+		{
+			Hook:  hooks.PostRollback,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: hooks.JoinRules(snapshotSpec.RestoreRules(), restartRules),
+		},
+	},
+}
@@ -0,0 +1,62 @@
+package dex
+
+import "github.com/trustacks/catalog/pkg/functions"
+
+// CreateOIDCClient creates the static oidc client.
+func (c *dex) CreateOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return createOIDCClientHandler(params)
+}
+
+// GetOIDCClient gets the static oidc client credentials and redirect
+// uris.
+func (c *dex) GetOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return getOIDCClientHandler(params)
+}
+
+// UpdateOIDCClient updates the static oidc client redirect uris.
+func (c *dex) UpdateOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return updateOIDCClientHandler(params)
+}
+
+// DeleteOIDCClient deletes the static oidc client.
+func (c *dex) DeleteOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return deleteOIDCClientHandler(params)
+}
+
+// CreateUser is not supported, dex has no users of its own and
+// federates the connector users.
+func (c *dex) CreateUser(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// AddUserToGroup is not supported, the groups are claimed by the
+// connectors.
+func (c *dex) AddUserToGroup(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// RemoveUser is not supported.
+func (c *dex) RemoveUser(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// CreateLDAPClient is not supported, dex does not serve ldap.
+func (c *dex) CreateLDAPClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// DeleteLDAPClient is not supported.
+func (c *dex) DeleteLDAPClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// CreateSAMLClient is not supported, dex is not a saml identity
+// provider.
+func (c *dex) CreateSAMLClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// DeleteSAMLClient is not supported.
+func (c *dex) DeleteSAMLClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
@@ -0,0 +1,172 @@
+package dex
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/rbac"
+	"k8s.io/client-go/kubernetes"
+)
+
+// initializeOnce adds the component hooks once per test binary.
+var initializeOnce sync.Once
+
+// initialize adds the component hooks and migrations.
+func initialize(t *testing.T) {
+	initializeOnce.Do(func() {
+		cat, err := catalog.NewComponentCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		Initialize(cat)
+	})
+}
+
+// patchEnvironment patches the in cluster namespace and the clientset.
+func patchEnvironment(t *testing.T, clientset kubernetes.Interface) {
+	f, err := os.CreateTemp("", "in-cluster-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	previousInClusterNamespace := inClusterNamespace
+	previousNewClientset := newClientset
+	inClusterNamespace = f.Name()
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	t.Cleanup(func() {
+		os.Remove(f.Name())
+		inClusterNamespace = previousInClusterNamespace
+		newClientset = previousNewClientset
+	})
+}
+
+func TestHookRBAC(t *testing.T) {
+	clientset := newTestClientset(t)
+	patchEnvironment(t, clientset)
+	t.Setenv("DEX_ISSUER", "https://dex.local.gd")
+	t.Setenv("DEX_CONNECTORS", "[]")
+
+	initialize(t)
+	manifests, err := rbac.RenderManifests(string(hookManifests), map[string]interface{}{"image": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the hooks through the release lifecycle.
+	var grants []rbac.Grant
+	accesses := []rbac.Access{}
+	for _, step := range []struct {
+		hook     string
+		revision string
+	}{
+		{hooks.PreInstallHook, "1"},
+		{hooks.PreUpgrade, "2"},
+		{hooks.PostUpgrade, "2"},
+		{hooks.PostRollback, "1"},
+		{hooks.PostDeleteHook, "3"},
+	} {
+		t.Setenv("RELEASE_REVISION", step.revision)
+		hookAccesses, err := rbac.Record(clientset, func() error { return hooks.Call(componentName, step.hook) })
+		if err != nil {
+			t.Fatalf("%s: %s", step.hook, err)
+		}
+		grants, err = rbac.Grants(manifests, "test", componentName+"-"+step.hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := rbac.Verify(grants, hookAccesses)
+		assert.Empty(t, report.Missing, "%s is missing permissions:\n%s", step.hook, report)
+		accesses = append(accesses, hookAccesses...)
+	}
+
+	// every hook job shares the role. restore patches the component
+	// keys of the shared system secrets. dex adds none, but the
+	// snapshot rules are shared by every component.
+	report := rbac.Verify(grants, accesses)
+	assert.Equal(t, []rbac.Grant{{Namespace: "test", Resource: "secrets", Verb: "patch"}}, report.Unused, "got unexpected unused grants:\n%s", report)
+}
+
+// TestPreInstallRBAC verifies that the pre install hook permissions
+// cover creating the dex config and updating an existing one, such as
+// the config left by a previous install.
+func TestPreInstallRBAC(t *testing.T) {
+	t.Setenv("DEX_ISSUER", "https://dex.local.gd")
+	t.Setenv("DEX_CONNECTORS", "[]")
+	initialize(t)
+	manifest := hooks.Manifest{Component: componentName, Jobs: []hooks.Job{hookManifest.Jobs[0]}}
+	data, err := manifest.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := rbac.RenderManifests(string(data), map[string]interface{}{"image": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := rbac.Grants(manifests, "test", componentName+"-"+hooks.PreInstallHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, existing := range []bool{false, true} {
+		clientset := newTestClientset(t)
+		patchEnvironment(t, clientset)
+		if existing {
+			if err := applyConfig("test", "https://dex.local.gd", "[]", clientset); err != nil {
+				t.Fatal(err)
+			}
+		}
+		accesses, err := rbac.Record(clientset, (&dex{}).preInstall)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := rbac.Verify(grants, accesses)
+		assert.Empty(t, report.Missing, "existing config %t: the pre install hook is missing permissions:\n%s", existing, report)
+	}
+}
+
+// TestFunctionRBAC verifies that the oidc client functions only use
+// the sso provider permissions granted to the calling hooks.
+func TestFunctionRBAC(t *testing.T) {
+	clientset := newTestClientset(t)
+	patchEnvironment(t, clientset)
+	if err := applyConfig("test", "https://dex.local.gd", "[]", clientset); err != nil {
+		t.Fatal(err)
+	}
+	manifest := hooks.Manifest{
+		Component: "caller",
+		Jobs:      []hooks.Job{{Hook: hooks.PreInstallHook, Rules: functions.SSOProviderRules("test")}},
+	}
+	data, err := manifest.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := rbac.RenderManifests(string(data), map[string]interface{}{"image": "test", "sso": "dex"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := rbac.Grants(manifests, "test", "caller-"+hooks.PreInstallHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accesses, err := rbac.Record(clientset, func() error {
+		if _, err := createOIDCClientHandler(map[string]interface{}{"name": "test"}); err != nil {
+			return err
+		}
+		if _, err := updateOIDCClientHandler(map[string]interface{}{"name": "test"}); err != nil {
+			return err
+		}
+		_, err := deleteOIDCClientHandler(map[string]interface{}{"name": "test"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := rbac.Verify(grants, accesses)
+	assert.Empty(t, report.Missing, "the oidc client functions are missing permissions:\n%s", report)
+}
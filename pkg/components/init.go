@@ -6,10 +6,14 @@ import (
 	"github.com/trustacks/catalog/pkg/components/argocd"
 	"github.com/trustacks/catalog/pkg/components/authentik"
 	"github.com/trustacks/catalog/pkg/components/concourse"
+	"github.com/trustacks/catalog/pkg/components/dex"
+	"github.com/trustacks/catalog/pkg/components/keycloak"
 )
 
 func Initialize(catalog *catalog.ComponentCatalog) {
 	authentik.Initialize(catalog)
 	concourse.Initialize(catalog)
 	argocd.Initialize(catalog)
+	keycloak.Initialize(catalog)
+	dex.Initialize(catalog)
 }
@@ -0,0 +1,840 @@
+package keycloak
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-password/password"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/roles"
+	"github.com/trustacks/catalog/pkg/snapshots"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// componentName is the name of the component.
+	componentName = "keycloak"
+	// realm is the keycloak realm of the toolchain users and clients.
+	realm = "toolchain"
+	// adminUser is the keycloak master realm admin user.
+	adminUser = "admin"
+	// adminClientID is the master realm client used by the hooks and
+	// functions to call the admin api.
+	adminClientID = "trustacks-admin"
+)
+
+var (
+	// inClusterNamespace is the path to the in-cluster namespace.
+	inClusterNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// serviceURL is the keycloak kubernetes service name.
+	serviceURL = "http://keycloak"
+)
+
+// adminSecret is the secret where the admin password and the admin
+// client secret are stored.
+var adminSecret = "keycloak-admin"
+
+type keycloak struct {
+	catalog.BaseComponent
+}
+
+// snapshotSpec contains the resources managed by the component hooks.
+var snapshotSpec = snapshots.Spec{
+	Secrets: []string{adminSecret},
+}
+
+// preInstall creates the admin password and admin client secret.
+func (c *keycloak) preInstall() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("create admin credentials")
+	if err := createAdminSecret(namespace, clientset); err != nil {
+		return err
+	}
+	return migrations.SetVersion(componentName, namespace, clientset)
+}
+
+// postInstall bootstraps the admin client, then creates the toolchain
+// realm and user groups.
+func (c *keycloak) postInstall() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	credentials, err := getAdminCredentials(namespace, clientset)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+	metrics.Step("health-check")
+	if err := healthCheckService(serviceURL, connectInterval, ctx); err != nil {
+		return err
+	}
+	logging.Info("create admin client")
+	metrics.Step("create-admin-client")
+	if err := createAdminClient(serviceURL, credentials); err != nil {
+		return err
+	}
+	token, err := getClientToken(serviceURL, credentials.clientSecret)
+	if err != nil {
+		return err
+	}
+	logging.Info("create toolchain realm")
+	metrics.Step("create-realm")
+	if err := createRealm(serviceURL, token); err != nil {
+		return err
+	}
+	logging.Info("create keycloak user groups")
+	metrics.Step("create-groups")
+	return createGroups(serviceURL, token)
+}
+
+// postDelete removes the admin secret so a reinstall bootstraps new
+// credentials.
+func (c *keycloak) postDelete() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	logging.Info("delete admin credentials")
+	err = clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), adminSecret, metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
+
+// preUpgrade snapshots the hook managed resources and runs the
+// pending chart migrations.
+func (c *keycloak) preUpgrade() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	// the hook is rendered for the upgrade revision, so the current
+	// state belongs to the previous revision.
+	logging.Info("snapshot hook managed resources")
+	if err := snapshots.Create(componentName, revision-1, snapshotSpec, namespace, clientset); err != nil {
+		return err
+	}
+	_, err = migrations.Run(componentName, namespace, false, clientset)
+	return err
+}
+
+// postRollback restores the hook managed resources of the revision
+// rolled back to.
+func (c *keycloak) postRollback() error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	// rollbacks run the hooks rendered for the target revision.
+	revision, err := snapshots.ReleaseRevision()
+	if err != nil {
+		return err
+	}
+	logging.Info("restore hook managed resources")
+	return snapshots.Restore(componentName, revision, namespace, clientset)
+}
+
+// adminCredentials contains the admin password and the admin client
+// secret.
+type adminCredentials struct {
+	password     string
+	clientSecret string
+}
+
+// createAdminSecret creates the admin secret. The chart reads the
+// admin password from it. An existing secret is kept.
+func createAdminSecret(namespace string, clientset kubernetes.Interface) error {
+	_, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), adminSecret, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	adminPassword, err := password.Generate(32, 10, 0, false, false)
+	if err != nil {
+		return err
+	}
+	clientSecret, err := password.Generate(32, 10, 0, false, false)
+	if err != nil {
+		return err
+	}
+	logging.AddSecret(adminPassword)
+	logging.AddSecret(clientSecret)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: adminSecret,
+			Labels: map[string]string{
+				"app.kubernetes.io/part-of": componentName,
+			},
+		},
+		Data: map[string][]byte{
+			"admin-password": []byte(adminPassword),
+			"client-secret":  []byte(clientSecret),
+		},
+	}
+	_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	return err
+}
+
+// getAdminCredentials gets the admin secret values.
+func getAdminCredentials(namespace string, clientset kubernetes.Interface) (*adminCredentials, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), adminSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &adminCredentials{
+		password:     strings.TrimSpace(string(secret.Data["admin-password"])),
+		clientSecret: strings.TrimSpace(string(secret.Data["client-secret"])),
+	}, nil
+}
+
+// getToken requests an access token from the master realm.
+func getToken(serviceURL string, form url.Values) (string, error) {
+	resp, err := http.PostForm(fmt.Sprintf("%s/realms/master/protocol/openid-connect/token", serviceURL), form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("token error: %s", body)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", err
+	}
+	logging.AddSecret(token.AccessToken)
+	return token.AccessToken, nil
+}
+
+// getAdminToken gets an access token for the admin user. It is only
+// used to bootstrap the admin client.
+func getAdminToken(serviceURL, adminPassword string) (string, error) {
+	return getToken(serviceURL, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"admin-cli"},
+		"username":   {adminUser},
+		"password":   {adminPassword},
+	})
+}
+
+// getClientToken gets an access token for the admin client.
+func getClientToken(serviceURL, clientSecret string) (string, error) {
+	return getToken(serviceURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {adminClientID},
+		"client_secret": {clientSecret},
+	})
+}
+
+// errNotFound is returned by the admin api requests when the resource
+// does not exist.
+var errNotFound = errors.New("not found")
+
+// apiRequest sends the admin api request for the resource and returns
+// the response body.
+func apiRequest(method, serviceURL, resource, token string, data []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewBuffer(data)
+	}
+	uri := fmt.Sprintf("%s/admin/realms", serviceURL)
+	if resource != "" {
+		uri = fmt.Sprintf("%s/%s", uri, resource)
+	}
+	req, err := http.NewRequest(method, uri, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("'%s' %s error: %s", resource, strings.ToLower(method), body)
+	}
+	return body, nil
+}
+
+// getAPIResource gets the admin api resource and decodes it into v.
+func getAPIResource(serviceURL, resource, token string, v interface{}) error {
+	body, err := apiRequest(http.MethodGet, serviceURL, resource, token, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// postAPIResource posts the admin api resource.
+func postAPIResource(serviceURL, resource, token string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = apiRequest(http.MethodPost, serviceURL, resource, token, data)
+	return err
+}
+
+// putAPIResource puts the admin api resource.
+func putAPIResource(serviceURL, resource, token string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = apiRequest(http.MethodPut, serviceURL, resource, token, data)
+	return err
+}
+
+// client represents a keycloak client.
+type client struct {
+	ID                        string   `json:"id,omitempty"`
+	ClientID                  string   `json:"clientId"`
+	Secret                    string   `json:"secret,omitempty"`
+	Protocol                  string   `json:"protocol"`
+	PublicClient              bool     `json:"publicClient"`
+	StandardFlowEnabled       bool     `json:"standardFlowEnabled"`
+	DirectAccessGrantsEnabled bool     `json:"directAccessGrantsEnabled"`
+	ServiceAccountsEnabled    bool     `json:"serviceAccountsEnabled"`
+	RedirectURIs              []string `json:"redirectUris,omitempty"`
+}
+
+// getClient gets the client of the realm with the client id. A nil
+// client is returned when it does not exist.
+func getClient(serviceURL, realm, clientID, token string) (*client, error) {
+	clients := []client{}
+	if err := getAPIResource(serviceURL, fmt.Sprintf("%s/clients?clientId=%s", realm, url.QueryEscape(clientID)), token, &clients); err != nil {
+		return nil, err
+	}
+	for _, c := range clients {
+		if c.ClientID == clientID {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// role represents a keycloak realm role.
+type role struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// createAdminClient creates the master realm service account client
+// used by the hooks and functions, and grants it the realm admin role.
+// The admin user token is only used when the client does not exist.
+func createAdminClient(serviceURL string, credentials *adminCredentials) error {
+	token, err := getAdminToken(serviceURL, credentials.password)
+	if err != nil {
+		return err
+	}
+	existing, err := getClient(serviceURL, "master", adminClientID, token)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	c := client{
+		ClientID:               adminClientID,
+		Secret:                 credentials.clientSecret,
+		Protocol:               "openid-connect",
+		ServiceAccountsEnabled: true,
+	}
+	if err := postAPIResource(serviceURL, "master/clients", token, c); err != nil {
+		return err
+	}
+	created, err := getClient(serviceURL, "master", adminClientID, token)
+	if err != nil {
+		return err
+	}
+	if created == nil {
+		return errors.New("admin client not found")
+	}
+	user := struct {
+		ID string `json:"id"`
+	}{}
+	if err := getAPIResource(serviceURL, fmt.Sprintf("master/clients/%s/service-account-user", created.ID), token, &user); err != nil {
+		return err
+	}
+	admin := role{}
+	if err := getAPIResource(serviceURL, "master/roles/admin", token, &admin); err != nil {
+		return err
+	}
+	return postAPIResource(serviceURL, fmt.Sprintf("master/users/%s/role-mappings/realm", user.ID), token, []role{admin})
+}
+
+// clientScope represents a keycloak client scope.
+type clientScope struct {
+	ID              string           `json:"id,omitempty"`
+	Name            string           `json:"name"`
+	Protocol        string           `json:"protocol"`
+	ProtocolMappers []protocolMapper `json:"protocolMappers,omitempty"`
+}
+
+// protocolMapper represents a keycloak protocol mapper.
+type protocolMapper struct {
+	Name           string            `json:"name"`
+	Protocol       string            `json:"protocol"`
+	ProtocolMapper string            `json:"protocolMapper"`
+	Config         map[string]string `json:"config"`
+}
+
+// groupsScope is the client scope that adds the user group names to
+// the groups claim.
+var groupsScope = clientScope{
+	Name:     "groups",
+	Protocol: "openid-connect",
+	ProtocolMappers: []protocolMapper{
+		{
+			Name:           "groups",
+			Protocol:       "openid-connect",
+			ProtocolMapper: "oidc-group-membership-mapper",
+			Config: map[string]string{
+				"claim.name":           "groups",
+				"full.path":            "false",
+				"id.token.claim":       "true",
+				"access.token.claim":   "true",
+				"userinfo.token.claim": "true",
+			},
+		},
+	},
+}
+
+// createRealm creates the toolchain realm and adds the groups client
+// scope to its default client scopes.
+func createRealm(serviceURL, token string) error {
+	var existing map[string]interface{}
+	err := getAPIResource(serviceURL, realm, token, &existing)
+	if errors.Is(err, errNotFound) {
+		// realms are created at the admin realms root.
+		err = postAPIResource(serviceURL, "", token, map[string]interface{}{"realm": realm, "enabled": true})
+	}
+	if err != nil {
+		return err
+	}
+	scopes := []clientScope{}
+	if err := getAPIResource(serviceURL, realm+"/client-scopes", token, &scopes); err != nil {
+		return err
+	}
+	id := ""
+	for _, scope := range scopes {
+		if scope.Name == groupsScope.Name {
+			id = scope.ID
+		}
+	}
+	if id == "" {
+		if err := postAPIResource(serviceURL, realm+"/client-scopes", token, groupsScope); err != nil {
+			return err
+		}
+		if err := getAPIResource(serviceURL, realm+"/client-scopes", token, &scopes); err != nil {
+			return err
+		}
+		for _, scope := range scopes {
+			if scope.Name == groupsScope.Name {
+				id = scope.ID
+			}
+		}
+	}
+	if id == "" {
+		return errors.New("groups client scope not found")
+	}
+	return putAPIResource(serviceURL, fmt.Sprintf("%s/default-default-client-scopes/%s", realm, id), token, map[string]string{})
+}
+
+// group represents a keycloak group.
+type group struct {
+	Name string `json:"name"`
+}
+
+// createGroups creates the user groups of the toolchain role model.
+func createGroups(serviceURL, token string) error {
+	for _, r := range roles.Groups {
+		name := r.Name
+		// check if the group already exists. the search matches
+		// substrings.
+		groups := []group{}
+		if err := getAPIResource(serviceURL, fmt.Sprintf("%s/groups?search=%s", realm, name), token, &groups); err != nil {
+			return err
+		}
+		exists := false
+		for _, g := range groups {
+			if g.Name == name {
+				exists = true
+			}
+		}
+		if exists {
+			continue
+		}
+		if err := postAPIResource(serviceURL, realm+"/groups", token, group{Name: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createOIDCClientHandler creates the oidc client.
+func createOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		return nil, err
+	}
+	rotate, _ := params["rotate"].(bool)
+	return createOIDCClient(name, redirectURIs, rotate)
+}
+
+// createOIDCClient creates a confidential oidc client in the
+// toolchain realm. An existing client is updated with the redirect uris
+// and its stored credentials are returned. The client secret is
+// regenerated when rotate is set, or when no credentials are stored.
+func createOIDCClient(name string, redirectURIs []string, rotate bool) (map[string]interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := getClient(serviceURL, realm, name, token)
+	if err != nil {
+		return nil, err
+	}
+	var credentials *functions.OIDCClientCredentials
+	if existing != nil && !rotate {
+		stored, err := functions.GetProviderSecret(componentName, functions.OIDCClientKind, name, namespace, clientset)
+		if err != nil {
+			return nil, err
+		}
+		credentials = functions.OIDCClientCredentialsFromData(stored)
+	}
+	if credentials == nil {
+		secret, err := password.Generate(128, 96, 0, false, true)
+		if err != nil {
+			return nil, err
+		}
+		logging.AddSecret(secret)
+		credentials = &functions.OIDCClientCredentials{ClientID: name, ClientSecret: secret}
+	}
+	// the credentials are stored before the client is changed, so that
+	// a failed change is resumed with the same credentials.
+	if err := functions.StoreProviderSecret(componentName, functions.OIDCClientKind, name, namespace, credentials.Data(), clientset); err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		existing.Secret = credentials.ClientSecret
+		existing.RedirectURIs = redirectURIs
+		if err := putAPIResource(serviceURL, fmt.Sprintf("%s/clients/%s", realm, existing.ID), token, existing); err != nil {
+			return nil, err
+		}
+		return credentials.Result(), nil
+	}
+	c := client{
+		ClientID:            name,
+		Secret:              credentials.ClientSecret,
+		Protocol:            "openid-connect",
+		StandardFlowEnabled: true,
+		RedirectURIs:        redirectURIs,
+	}
+	if err := postAPIResource(serviceURL, realm+"/clients", token, c); err != nil {
+		return nil, err
+	}
+	return credentials.Result(), nil
+}
+
+// getOIDCClientHandler gets the oidc client.
+func getOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	client, err := getOIDCClient(name)
+	if client == nil || err != nil {
+		// a nil map is not a nil result.
+		return nil, err
+	}
+	return client, nil
+}
+
+// getOIDCClient gets the stored credentials and the redirect uris of
+// the oidc client in the toolchain realm. A nil client is returned when
+// it does not exist.
+func getOIDCClient(name string) (map[string]interface{}, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return nil, err
+	}
+	token, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	c, err := getClient(serviceURL, realm, name, token)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	stored, err := functions.GetProviderSecret(componentName, functions.OIDCClientKind, name, namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	credentials := functions.OIDCClientCredentialsFromData(stored)
+	if credentials == nil {
+		credentials = &functions.OIDCClientCredentials{ClientID: name}
+	}
+	client := credentials.Result()
+	client["redirectUris"] = c.RedirectURIs
+	if c.RedirectURIs == nil {
+		client["redirectUris"] = []string{}
+	}
+	return client, nil
+}
+
+// updateOIDCClientHandler updates the oidc client redirect uris.
+func updateOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		return nil, err
+	}
+	return nil, updateOIDCClient(name, redirectURIs)
+}
+
+// updateOIDCClient sets the redirect uris of the oidc client in the
+// toolchain realm.
+func updateOIDCClient(name string, redirectURIs []string) error {
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	c, err := getClient(serviceURL, realm, name, token)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("oidc client '%s' not found", name)
+	}
+	c.RedirectURIs = redirectURIs
+	return putAPIResource(serviceURL, fmt.Sprintf("%s/clients/%s", realm, c.ID), token, c)
+}
+
+// deleteOIDCClientHandler deletes the oidc client.
+func deleteOIDCClientHandler(params map[string]interface{}) (interface{}, error) {
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	return nil, deleteOIDCClient(name)
+}
+
+// deleteOIDCClient deletes the oidc client from the toolchain realm,
+// and its stored credentials. Clients that do not exist are ignored.
+func deleteOIDCClient(name string) error {
+	clientset, err := newClientset()
+	if err != nil {
+		return err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return err
+	}
+	token, err := connect()
+	if err != nil {
+		return err
+	}
+	c, err := getClient(serviceURL, realm, name, token)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		_, err = apiRequest(http.MethodDelete, serviceURL, fmt.Sprintf("%s/clients/%s", realm, c.ID), token, nil)
+		if err != nil && !errors.Is(err, errNotFound) {
+			return err
+		}
+	}
+	return functions.DeleteProviderSecret(componentName, functions.OIDCClientKind, name, namespace, clientset)
+}
+
+// connectInterval is the interval of the service health check in
+// seconds.
+var connectInterval = 2
+
+// connect waits for the keycloak service and returns an admin client
+// access token.
+func connect() (string, error) {
+	clientset, err := newClientset()
+	if err != nil {
+		return "", err
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+	if err := healthCheckService(serviceURL, connectInterval, ctx); err != nil {
+		return "", err
+	}
+	credentials, err := getAdminCredentials(namespace, clientset)
+	if err != nil {
+		return "", err
+	}
+	return getClientToken(serviceURL, credentials.clientSecret)
+}
+
+// newClientset creates the in-cluster kubernetes clientset.
+var newClientset = func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// getNamespace gets the current kubernetes namespace.
+func getNamespace() (string, error) {
+	data, err := os.ReadFile(inClusterNamespace)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// healthCheckService checks the health of the keycloak service.
+func healthCheckService(url string, interval int, ctx context.Context) error {
+	for {
+		select {
+		case <-time.After(time.Second * time.Duration(interval)):
+			if _, err := http.Get(url); err != nil {
+				logging.Debug("service health check failed", "error", err)
+				continue
+			}
+		case <-ctx.Done():
+			return errors.New("service health check timeout")
+		}
+		break
+	}
+	return nil
+}
+
+//go:embed config.yaml
+var config []byte
+
+//go:embed hooks.yaml
+var hookManifests []byte
+
+// Initialize adds the component to the catalog and configures hooks.
+func Initialize(c *catalog.ComponentCatalog) {
+	var conf *catalog.ComponentConfig
+	if err := yaml.Unmarshal(config, &conf); err != nil {
+		logging.Fatal("error loading the component config", "error", err)
+	}
+	component := &keycloak{
+		catalog.BaseComponent{
+			Repo:       conf.Repo,
+			MirrorRepo: conf.MirrorRepository(),
+			Chart:      conf.Chart,
+			Version:    conf.Version,
+			Values:     conf.Values,
+			Hooks:      string(hookManifests),
+			Artifacts:  conf.Artifacts(),
+		},
+	}
+	c.AddComponent(componentName, component)
+	migrations.Register(componentName, conf.Version)
+
+	// configure hooks.
+	for hook, fn := range map[string]func() error{
+		hooks.PreInstallHook:  component.preInstall,
+		hooks.PostInstallHook: component.postInstall,
+		hooks.PostDeleteHook:  component.postDelete,
+		hooks.PreUpgrade:      component.preUpgrade,
+		hooks.PostRollback:    component.postRollback,
+	} {
+		if err := hooks.AddHook(componentName, hook, fn); err != nil {
+			logging.Fatal("error adding the component hook", "hook", hook, "error", err)
+		}
+	}
+
+	// configure functions.
+	functions.RegisterSSOProvider(componentName, component)
+}
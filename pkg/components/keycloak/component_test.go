@@ -0,0 +1,237 @@
+package keycloak
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/functions/providertest"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetChart(t *testing.T) {
+	var conf *catalog.ComponentConfig
+	if err := yaml.Unmarshal(config, &conf); err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/%s-%s.tgz", conf.Repo, conf.Chart, conf.Version)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatal("failed retrieving the helm chart")
+	}
+}
+
+// patchEnvironment patches the in cluster namespace, the clientset and
+// the service url. The service health check is not delayed.
+func patchEnvironment(t *testing.T, clientset kubernetes.Interface, url string) {
+	f, err := os.CreateTemp("", "in-cluster-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	previousInClusterNamespace := inClusterNamespace
+	previousNewClientset := newClientset
+	previousServiceURL := serviceURL
+	previousConnectInterval := connectInterval
+	inClusterNamespace = f.Name()
+	newClientset = func() (kubernetes.Interface, error) { return clientset, nil }
+	serviceURL = url
+	connectInterval = 0
+	t.Cleanup(func() {
+		os.Remove(f.Name())
+		inClusterNamespace = previousInClusterNamespace
+		newClientset = previousNewClientset
+		serviceURL = previousServiceURL
+		connectInterval = previousConnectInterval
+	})
+}
+
+// createTestAdminSecret creates the admin secret with known values.
+func createTestAdminSecret(t *testing.T, clientset kubernetes.Interface) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: adminSecret},
+		Data: map[string][]byte{
+			"admin-password": []byte("test-password"),
+			"client-secret":  []byte("test-client-secret"),
+		},
+	}
+	if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateAdminSecret(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	if err := createAdminSecret("test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := getAdminCredentials("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, credentials.password, 32, "expected a 32 character admin password")
+	assert.Len(t, credentials.clientSecret, 32, "expected a 32 character client secret")
+
+	// check that an existing secret is kept.
+	if err := createAdminSecret("test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	existing, err := getAdminCredentials("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, credentials, existing, "expected the admin credentials to be kept")
+}
+
+func TestPostInstall(t *testing.T) {
+	kc := newFakeKeycloak(t, "test-password")
+	clientset := fake.NewSimpleClientset()
+	createTestAdminSecret(t, clientset)
+	patchEnvironment(t, clientset, kc.URL)
+
+	c := &keycloak{}
+	if err := c.postInstall(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, kc.clients["master"], 1, "expected the admin client to be created")
+	adminClient := kc.clients["master"][0]
+	assert.Equal(t, adminClientID, adminClient.ClientID)
+	assert.Equal(t, "test-client-secret", adminClient.Secret)
+	assert.True(t, adminClient.ServiceAccountsEnabled, "expected the admin client service account to be enabled")
+	assert.Equal(t, []role{{ID: "role-admin", Name: "admin"}}, kc.roleMappings[adminClient.ID], "expected the admin role to be granted")
+	assert.True(t, kc.realms[realm], "expected the toolchain realm to be created")
+	assert.Equal(t, []group{{"admins"}, {"editors"}, {"viewers"}}, kc.groups)
+	assert.Len(t, kc.scopes, 1, "expected the groups client scope to be created")
+	assert.Equal(t, "oidc-group-membership-mapper", kc.scopes[0].ProtocolMappers[0].ProtocolMapper)
+	assert.Equal(t, []string{kc.scopes[0].ID}, kc.defaultScopes, "expected the groups client scope to be a default scope")
+
+	// check idempotence.
+	if err := c.postInstall(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, kc.clients["master"], 1, "expected the admin client to be reused")
+	assert.Len(t, kc.groups, 3, "expected the groups to be reused")
+	assert.Len(t, kc.scopes, 1, "expected the groups client scope to be reused")
+}
+
+func TestOIDCClient(t *testing.T) {
+	kc := newFakeKeycloak(t, "test-password")
+	clientset := fake.NewSimpleClientset()
+	createTestAdminSecret(t, clientset)
+	patchEnvironment(t, clientset, kc.URL)
+	credentials, err := getAdminCredentials("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := createAdminClient(kc.URL, credentials); err != nil {
+		t.Fatal(err)
+	}
+	kc.realms[realm] = true
+
+	if _, err := createOIDCClientHandler(map[string]interface{}{}); err == nil {
+		t.Fatal("expected a missing name error")
+	}
+	params := map[string]interface{}{
+		"name":          "test",
+		"callbackPaths": []interface{}{"/auth/callback"},
+		"domain":        "local.gd",
+		"tls":           "false",
+		"ingressPort":   "8081",
+	}
+	result, err := createOIDCClientHandler(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := result.(map[string]interface{})
+	assert.Equal(t, "test", v["clientId"], "got an unexpected client id")
+	assert.Len(t, v["clientSecret"], 128, "expected a 128 character secret")
+	assert.Len(t, kc.clients[realm], 1, "expected the client to be created")
+	assert.Equal(t, v["clientSecret"], kc.clients[realm][0].Secret)
+	assert.True(t, kc.clients[realm][0].StandardFlowEnabled, "expected the authorization code flow to be enabled")
+	assert.Equal(t, []string{"http://test.local.gd:8081/auth/callback"}, kc.clients[realm][0].RedirectURIs)
+	stored, err := functions.GetProviderSecret("keycloak", functions.OIDCClientKind, "test", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, v, functions.OIDCClientCredentialsFromData(stored).Result(), "expected the credentials to be stored")
+
+	// an existing client is reused with the stored credentials.
+	result, err = createOIDCClientHandler(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, v, result, "expected the stored credentials")
+	assert.Len(t, kc.clients[realm], 1, "expected the client to be reused")
+
+	// rotation regenerates the client secret in place.
+	params["rotate"] = true
+	result, err = createOIDCClientHandler(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(params, "rotate")
+	rotated := result.(map[string]interface{})
+	assert.NotEqual(t, v["clientSecret"], rotated["clientSecret"], "expected the client secret to be rotated")
+	assert.Equal(t, rotated["clientSecret"], kc.clients[realm][0].Secret)
+	assert.Len(t, kc.clients[realm], 1)
+	v = rotated
+
+	// the redirect uris follow the ingress parameters.
+	params["ingressPort"] = "443"
+	params["tls"] = "true"
+	if _, err := updateOIDCClientHandler(params); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"https://test.local.gd/auth/callback"}, kc.clients[realm][0].RedirectURIs)
+	assert.Equal(t, v["clientSecret"], kc.clients[realm][0].Secret, "expected the client secret to be kept")
+
+	if _, err := deleteOIDCClientHandler(map[string]interface{}{"name": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, kc.clients[realm], "expected the client to be deleted")
+	stored, err = functions.GetProviderSecret("keycloak", functions.OIDCClientKind, "test", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, stored, "expected the stored credentials to be deleted")
+	// missing clients are ignored.
+	if _, err := deleteOIDCClientHandler(map[string]interface{}{"name": "test"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSOProviderContract(t *testing.T) {
+	kc := newFakeKeycloak(t, "test-password")
+	clientset := fake.NewSimpleClientset()
+	createTestAdminSecret(t, clientset)
+	patchEnvironment(t, clientset, kc.URL)
+	c := &keycloak{}
+	if err := c.postInstall(); err != nil {
+		t.Fatal(err)
+	}
+	providertest.SSOProviderRBAC(t, c, componentName, "test", clientset)
+}
+
+func TestGetClientTokenUnauthorized(t *testing.T) {
+	kc := newFakeKeycloak(t, "test-password")
+	if _, err := getClientToken(kc.URL, "invalid"); err == nil {
+		t.Fatal("expected a token error")
+	}
+	if _, err := getAdminToken(kc.URL, "invalid"); err == nil {
+		t.Fatal("expected a token error")
+	}
+}
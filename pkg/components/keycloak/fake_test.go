@@ -0,0 +1,213 @@
+package keycloak
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeKeycloak is a local fake of the keycloak token endpoint and the
+// admin api resources used by the hooks and functions.
+type fakeKeycloak struct {
+	*httptest.Server
+	t             *testing.T
+	mu            sync.Mutex
+	adminPassword string
+	tokens        map[string]bool
+	realms        map[string]bool
+	clients       map[string][]client
+	roleMappings  map[string][]role
+	scopes        []clientScope
+	defaultScopes []string
+	groups        []group
+	nextID        int
+}
+
+// newFakeKeycloak starts a fake keycloak with the admin user password.
+func newFakeKeycloak(t *testing.T, adminPassword string) *fakeKeycloak {
+	f := &fakeKeycloak{
+		t:             t,
+		adminPassword: adminPassword,
+		tokens:        map[string]bool{},
+		realms:        map[string]bool{"master": true},
+		clients:       map[string][]client{},
+		roleMappings:  map[string][]role{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// id returns a new resource id.
+func (f *fakeKeycloak) id() string {
+	f.nextID++
+	return fmt.Sprintf("id-%d", f.nextID)
+}
+
+// issue returns a new access token.
+func (f *fakeKeycloak) issue(w http.ResponseWriter) {
+	token := f.id()
+	f.tokens[token] = true
+	f.write(w, map[string]string{"access_token": token})
+}
+
+func (f *fakeKeycloak) write(w http.ResponseWriter, v interface{}) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeKeycloak) read(r *http.Request, v interface{}) {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// token handles the master realm token endpoint.
+func (f *fakeKeycloak) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.t.Fatal(err)
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		if r.PostForm.Get("client_id") == "admin-cli" && r.PostForm.Get("username") == adminUser && r.PostForm.Get("password") == f.adminPassword {
+			f.issue(w)
+			return
+		}
+	case "client_credentials":
+		for _, c := range f.clients["master"] {
+			if c.ClientID == r.PostForm.Get("client_id") && c.Secret == r.PostForm.Get("client_secret") && c.ServiceAccountsEnabled {
+				if len(f.roleMappings[c.ID]) == 0 {
+					break
+				}
+				f.issue(w)
+				return
+			}
+		}
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"error": "invalid_grant"}`))
+}
+
+func (f *fakeKeycloak) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/" {
+		return
+	}
+	if r.URL.Path == "/realms/master/protocol/openid-connect/token" && r.Method == http.MethodPost {
+		f.token(w, r)
+		return
+	}
+	if !f.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/realms"), "/")
+	if len(path) == 1 && r.Method == http.MethodPost {
+		rep := map[string]interface{}{}
+		f.read(r, &rep)
+		name := rep["realm"].(string)
+		if f.realms[name] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.realms[name] = true
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	if len(path) < 2 || !f.realms[path[1]] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	realm := path[1]
+	resource := strings.Join(path[2:], "/")
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		f.write(w, map[string]interface{}{"realm": realm})
+	case resource == "clients" && r.Method == http.MethodGet:
+		clients := []client{}
+		for _, c := range f.clients[realm] {
+			if id := r.URL.Query().Get("clientId"); id == "" || id == c.ClientID {
+				clients = append(clients, c)
+			}
+		}
+		f.write(w, clients)
+	case resource == "clients" && r.Method == http.MethodPost:
+		c := client{}
+		f.read(r, &c)
+		for _, existing := range f.clients[realm] {
+			if existing.ClientID == c.ClientID {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		c.ID = f.id()
+		f.clients[realm] = append(f.clients[realm], c)
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(resource, "clients/") && strings.HasSuffix(resource, "/service-account-user") && r.Method == http.MethodGet:
+		f.write(w, map[string]string{"id": "user-" + path[3]})
+	case strings.HasPrefix(resource, "clients/") && r.Method == http.MethodPut:
+		for i, c := range f.clients[realm] {
+			if c.ID == path[3] {
+				f.read(r, &f.clients[realm][i])
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case strings.HasPrefix(resource, "clients/") && r.Method == http.MethodDelete:
+		for i, c := range f.clients[realm] {
+			if c.ID == path[3] {
+				f.clients[realm] = append(f.clients[realm][:i], f.clients[realm][i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case resource == "roles/admin" && r.Method == http.MethodGet:
+		f.write(w, role{ID: "role-admin", Name: "admin"})
+	case strings.HasPrefix(resource, "users/user-") && strings.HasSuffix(resource, "/role-mappings/realm") && r.Method == http.MethodPost:
+		roles := []role{}
+		f.read(r, &roles)
+		id := strings.TrimPrefix(path[3], "user-")
+		f.roleMappings[id] = append(f.roleMappings[id], roles...)
+		w.WriteHeader(http.StatusNoContent)
+	case resource == "client-scopes" && r.Method == http.MethodGet:
+		f.write(w, f.scopes)
+	case resource == "client-scopes" && r.Method == http.MethodPost:
+		scope := clientScope{}
+		f.read(r, &scope)
+		scope.ID = f.id()
+		f.scopes = append(f.scopes, scope)
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(resource, "default-default-client-scopes/") && r.Method == http.MethodPut:
+		f.defaultScopes = append(f.defaultScopes, path[3])
+		w.WriteHeader(http.StatusNoContent)
+	case resource == "groups" && r.Method == http.MethodGet:
+		groups := []group{}
+		for _, g := range f.groups {
+			if strings.Contains(g.Name, r.URL.Query().Get("search")) {
+				groups = append(groups, g)
+			}
+		}
+		f.write(w, groups)
+	case resource == "groups" && r.Method == http.MethodPost:
+		g := group{}
+		f.read(r, &g)
+		for _, existing := range f.groups {
+			if existing.Name == g.Name {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		f.groups = append(f.groups, g)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
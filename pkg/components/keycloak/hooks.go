@@ -0,0 +1,40 @@
+package keycloak
+
+import (
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/migrations"
+	"github.com/trustacks/catalog/pkg/snapshots"
+)
+
+// hookManifest declares the component hooks and their permissions.
+// hooks.yaml is generated from it.
+var hookManifest = hooks.Manifest{
+	Component: componentName,
+	Jobs: []hooks.Job{
+		{
+			Hook: hooks.PreInstallHook,
+			Rules: hooks.JoinRules(
+				[]hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get", "create"}}},
+				migrations.Rules,
+			),
+		},
+		{
+			Hook:  hooks.PostInstallHook,
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		{
+			Hook:  hooks.PostDeleteHook,
+			Rules: []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"delete"}}},
+		},
+		{
+			Hook:  hooks.PreUpgrade,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: hooks.JoinRules(snapshots.CreateRules, migrations.Rules),
+		},
+		{
+			Hook:  hooks.PostRollback,
+			Env:   []hooks.EnvVar{hooks.ReleaseRevisionEnv},
+			Rules: snapshotSpec.RestoreRules(),
+		},
+	},
+}
@@ -0,0 +1,61 @@
+package keycloak
+
+import "github.com/trustacks/catalog/pkg/functions"
+
+// CreateOIDCClient creates the oidc client.
+func (c *keycloak) CreateOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return createOIDCClientHandler(params)
+}
+
+// GetOIDCClient gets the oidc client credentials and redirect uris.
+func (c *keycloak) GetOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return getOIDCClientHandler(params)
+}
+
+// UpdateOIDCClient updates the oidc client redirect uris.
+func (c *keycloak) UpdateOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return updateOIDCClientHandler(params)
+}
+
+// DeleteOIDCClient deletes the oidc client.
+func (c *keycloak) DeleteOIDCClient(params map[string]interface{}) (interface{}, error) {
+	return deleteOIDCClientHandler(params)
+}
+
+// CreateUser is not supported, the toolchain realm users are managed
+// in keycloak.
+func (c *keycloak) CreateUser(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// AddUserToGroup is not supported.
+func (c *keycloak) AddUserToGroup(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// RemoveUser is not supported.
+func (c *keycloak) RemoveUser(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// CreateLDAPClient is not supported, keycloak federates ldap
+// directories but does not serve ldap.
+func (c *keycloak) CreateLDAPClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// DeleteLDAPClient is not supported.
+func (c *keycloak) DeleteLDAPClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// CreateSAMLClient is not supported, the keycloak functions only manage
+// oidc clients.
+func (c *keycloak) CreateSAMLClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
+
+// DeleteSAMLClient is not supported.
+func (c *keycloak) DeleteSAMLClient(params map[string]interface{}) (interface{}, error) {
+	return nil, functions.ErrUnsupported
+}
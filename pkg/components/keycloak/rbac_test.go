@@ -0,0 +1,75 @@
+package keycloak
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/rbac"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// initializeOnce adds the component hooks once per test binary.
+var initializeOnce sync.Once
+
+func TestHookRBAC(t *testing.T) {
+	kc := newFakeKeycloak(t, "")
+	clientset := fake.NewSimpleClientset()
+	patchEnvironment(t, clientset, kc.URL)
+
+	initializeOnce.Do(func() {
+		cat, err := catalog.NewComponentCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		Initialize(cat)
+	})
+	manifests, err := rbac.RenderManifests(string(hookManifests), map[string]interface{}{"image": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the hooks through the release lifecycle.
+	var grants []rbac.Grant
+	accesses := []rbac.Access{}
+	for _, step := range []struct {
+		hook     string
+		revision string
+	}{
+		{hooks.PreInstallHook, "1"},
+		{hooks.PostInstallHook, "1"},
+		{hooks.PreUpgrade, "2"},
+		{hooks.PostRollback, "1"},
+		{hooks.PostDeleteHook, "3"},
+	} {
+		if step.hook == hooks.PostInstallHook {
+			// the chart sets the admin password from the secret
+			// created by the pre-install hook.
+			credentials, err := getAdminCredentials("test", clientset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kc.adminPassword = credentials.password
+		}
+		t.Setenv("RELEASE_REVISION", step.revision)
+		hookAccesses, err := rbac.Record(clientset, func() error { return hooks.Call(componentName, step.hook) })
+		if err != nil {
+			t.Fatalf("%s: %s", step.hook, err)
+		}
+		grants, err = rbac.Grants(manifests, "test", componentName+"-"+step.hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := rbac.Verify(grants, hookAccesses)
+		assert.Empty(t, report.Missing, "%s is missing permissions:\n%s", step.hook, report)
+		accesses = append(accesses, hookAccesses...)
+	}
+
+	// every hook job shares the role. restore patches the component
+	// keys of the shared system secrets. keycloak adds none, but the
+	// snapshot rules are shared by every component.
+	report := rbac.Verify(grants, accesses)
+	assert.Equal(t, []rbac.Grant{{Namespace: "test", Resource: "secrets", Verb: "patch"}}, report.Unused, "got unexpected unused grants:\n%s", report)
+}
@@ -0,0 +1,140 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/trustacks/catalog/pkg/backup"
+	"github.com/trustacks/catalog/pkg/inputs"
+	"github.com/trustacks/catalog/pkg/logging"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newClientset creates the in-cluster kubernetes clientset.
+var newClientset = func() (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// backupPath returns the backup file path. Directories, such as a
+// mounted persistent volume, get a timestamped backup file.
+func backupPath(path, toolchain string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, fmt.Sprintf("%s-%s.tar.gz.age", toolchain, time.Now().UTC().Format("20060102150405")))
+	}
+	return path
+}
+
+// backupToolchain writes the catalog managed toolchain objects to an
+// age encrypted tarball.
+func backupToolchain(params map[string]interface{}) (interface{}, error) {
+	toolchain, ok := params["toolchain"].(string)
+	if !ok {
+		return nil, errors.New("toolchain is required")
+	}
+	path, ok := params["path"].(string)
+	if !ok {
+		return nil, errors.New("path is required")
+	}
+	namespace := fmt.Sprintf("trustacks-toolchain-%s", toolchain)
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	var recipient age.Recipient
+	if publicKey, ok := params["recipient"].(string); ok {
+		recipient, err = age.ParseX25519Recipient(strings.TrimSpace(publicKey))
+	} else {
+		recipient, err = inputs.GetToolchainRecipient(namespace, clientset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	path = backupPath(path, toolchain)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	manifest, err := backup.Backup(namespace, recipient, f, clientset)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("backed up the toolchain", "objects", len(manifest.Entries), "path", path)
+	return manifest, nil
+}
+
+// restoreToolchain restores the catalog managed toolchain objects
+// from a backup or, in verify mode, diffs the backup against the
+// live objects.
+func restoreToolchain(params map[string]interface{}) (interface{}, error) {
+	toolchain, ok := params["toolchain"].(string)
+	if !ok {
+		return nil, errors.New("toolchain is required")
+	}
+	path, ok := params["path"].(string)
+	if !ok {
+		return nil, errors.New("path is required")
+	}
+	verify, _ := params["verify"].(bool)
+	namespace := fmt.Sprintf("trustacks-toolchain-%s", toolchain)
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	// the toolchain key is lost with the namespace, so an identity
+	// file is required to restore a deleted toolchain.
+	var identities []age.Identity
+	if identityFile, ok := params["identityFile"].(string); ok {
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		identities, err = age.ParseIdentities(f)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		identities, err = inputs.GetToolchainIdentities(namespace, clientset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if verify {
+		differences, err := backup.Verify(namespace, identities, f, clientset)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range differences {
+			logging.Info("backup difference", "difference", d)
+		}
+		logging.Info("verified the backup", "differences", len(differences))
+		return differences, nil
+	}
+	manifest, err := backup.Restore(namespace, identities, f, clientset)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("restored the toolchain", "objects", len(manifest.Entries), "path", path)
+	return manifest, nil
+}
+
+func init() {
+	registerMethod("backup", backupToolchain)
+	registerMethod("restore", restoreToolchain)
+}
@@ -0,0 +1,53 @@
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/backup"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBackupAndRestoreToolchain(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	defer func(fn func() (kubernetes.Interface, error)) { newClientset = fn }(newClientset)
+	newClientset = func() (kubernetes.Interface, error) {
+		return clientset, nil
+	}
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	identityFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "backup.tar.gz.age")
+	params := fmt.Sprintf(`{"toolchain": "test", "path": "%s", "recipient": "%s"}`, path, identity.Recipient())
+	if _, err := Call("backup", []byte(params)); err != nil {
+		t.Fatal(err)
+	}
+	params = fmt.Sprintf(`{"toolchain": "test", "path": "%s", "identityFile": "%s", "verify": true}`, path, identityFile)
+	result, err := Call("restore", []byte(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, result.([]backup.Difference), "expected no differences")
+
+	_, err = Call("backup", []byte(`{"path": "backup"}`))
+	assert.Equal(t, "toolchain is required", err.Error(), "expected a toolchain required error")
+	_, err = Call("restore", []byte(`{"toolchain": "test"}`))
+	assert.Equal(t, "path is required", err.Error(), "expected a path required error")
+}
+
+func TestBackupPath(t *testing.T) {
+	dir := t.TempDir()
+	assert.Regexp(t, `/test-\d{14}\.tar\.gz\.age$`, backupPath(dir, "test"), "expected a timestamped backup file")
+	assert.Equal(t, filepath.Join(dir, "backup"), backupPath(filepath.Join(dir, "backup"), "test"), "expected the backup file path")
+}
@@ -0,0 +1,53 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Component is a toolchain component with maintenance functions. The
+// methods receive the function params, which name the component.
+type Component interface {
+	// RotateSecrets regenerates the credentials of the component.
+	RotateSecrets(params map[string]interface{}) (interface{}, error)
+	// Migrate runs or, with the dryRun param, lists the pending chart
+	// migrations of the component.
+	Migrate(params map[string]interface{}) (interface{}, error)
+}
+
+var components = map[string]Component{}
+
+// RegisterComponent adds the component to the component registry.
+func RegisterComponent(name string, component Component) {
+	components[name] = component
+}
+
+// getComponent gets the component named in the params.
+func getComponent(params map[string]interface{}) (Component, error) {
+	name, ok := params["component"].(string)
+	if !ok {
+		return nil, errors.New("component is required")
+	}
+	component, ok := components[name]
+	if !ok {
+		return nil, fmt.Errorf("component '%s' not found", name)
+	}
+	return component, nil
+}
+
+// componentMethod creates a function that calls the method of the
+// component named in the params.
+func componentMethod(method func(Component, map[string]interface{}) (interface{}, error)) func(map[string]interface{}) (interface{}, error) {
+	return func(params map[string]interface{}) (interface{}, error) {
+		component, err := getComponent(params)
+		if err != nil {
+			return nil, err
+		}
+		return method(component, params)
+	}
+}
+
+func init() {
+	registerMethod("rotate-secrets", componentMethod(Component.RotateSecrets))
+	registerMethod("migrate", componentMethod(Component.Migrate))
+}
@@ -0,0 +1,45 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testComponent is a component that records the params of the called
+// function.
+type testComponent struct {
+	params map[string]interface{}
+}
+
+func (c *testComponent) RotateSecrets(params map[string]interface{}) (interface{}, error) {
+	c.params = params
+	return "rotate-secrets", nil
+}
+
+func (c *testComponent) Migrate(params map[string]interface{}) (interface{}, error) {
+	c.params = params
+	return "migrate", nil
+}
+
+func TestComponentMethods(t *testing.T) {
+	component := &testComponent{}
+	RegisterComponent("test", component)
+	defer delete(components, "test")
+
+	for _, method := range []string{"rotate-secrets", "migrate"} {
+		result, err := Call(method, []byte(`{"component": "test", "dryRun": true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, method, result, "expected the component method to be called")
+		assert.Equal(t, true, component.params["dryRun"], "expected the params to be passed")
+
+		_, err = Call(method, []byte(`{}`))
+		assert.EqualError(t, err, "component is required")
+		_, err = Call(method, []byte(`{"component": 42}`))
+		assert.EqualError(t, err, "component is required", "expected a checked component name")
+		_, err = Call(method, []byte(`{"component": "missing"}`))
+		assert.EqualError(t, err, "component 'missing' not found")
+	}
+}
@@ -0,0 +1,52 @@
+package functions
+
+import (
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/inputs"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LDAPClientRules returns the permissions used by the ldap client
+// functions of the client in the namespace of the calling hook. The
+// ldap client is published to the system vars and secrets.
+func LDAPClientRules(client string) []hooks.Rule {
+	return hooks.JoinRules(
+		SSOProviderRules(client),
+		inputs.AddSystemVarsRules,
+		inputs.AddSystemSecretsRules,
+	)
+}
+
+// LDAPClient is the bind configuration of an ldap client.
+type LDAPClient struct {
+	BaseDN       string
+	BindDN       string
+	BindPassword string
+	Host         string
+}
+
+// Result returns the create ldap client function result.
+func (c *LDAPClient) Result() map[string]interface{} {
+	return map[string]interface{}{
+		"baseDN":       c.BaseDN,
+		"bindDN":       c.BindDN,
+		"bindPassword": c.BindPassword,
+		"host":         c.Host,
+	}
+}
+
+// PublishLDAPClient adds the ldap client to the system vars and
+// secrets of the client component, so that the toolchain applications
+// can bind to the ldap service.
+func PublishLDAPClient(name, namespace string, client *LDAPClient, clientset kubernetes.Interface) error {
+	vars := map[string]string{
+		"ldap-base-dn": client.BaseDN,
+		"ldap-bind-dn": client.BindDN,
+		"ldap-host":    client.Host,
+	}
+	if err := inputs.AddSystemVars(name, namespace, vars, clientset); err != nil {
+		return err
+	}
+	secrets := map[string][]byte{"ldap-bind-password": []byte(client.BindPassword)}
+	return inputs.AddSystemSecrets(name, namespace, secrets, clientset)
+}
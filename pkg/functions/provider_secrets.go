@@ -0,0 +1,72 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// OIDCClientKind is the kind of the secrets that store the oidc
+	// client credentials.
+	OIDCClientKind = "oidc-client"
+	// LDAPClientKind is the kind of the secrets that store the ldap
+	// client bind passwords.
+	LDAPClientKind = "ldap-client"
+	// SAMLClientKind is the kind of the secrets that cache the saml
+	// client metadata.
+	SAMLClientKind = "saml-client"
+)
+
+// providerSecretName returns the name of the secret of the sso
+// provider client of the kind.
+func providerSecretName(provider, kind, name string) string {
+	return fmt.Sprintf("%s-%s-%s", provider, kind, name)
+}
+
+// GetProviderSecret gets the data of the secret of the sso provider
+// client of the kind. Nil data is returned when no secret is stored.
+func GetProviderSecret(provider, kind, name, namespace string, clientset kubernetes.Interface) (map[string][]byte, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), providerSecretName(provider, kind, name), metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if secret.Data == nil {
+		return map[string][]byte{}, nil
+	}
+	return secret.Data, nil
+}
+
+// StoreProviderSecret creates or updates the secret of the sso provider
+// client of the kind. The secret is part of the sso provider component.
+func StoreProviderSecret(provider, kind, name, namespace string, data map[string][]byte, clientset kubernetes.Interface) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   providerSecretName(provider, kind, name),
+			Labels: map[string]string{"app.kubernetes.io/part-of": provider},
+		},
+		Data: data,
+	}
+	_, err := clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		_, err = clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// DeleteProviderSecret deletes the secret of the sso provider client of
+// the kind. Missing secrets are ignored.
+func DeleteProviderSecret(provider, kind, name, namespace string, clientset kubernetes.Interface) error {
+	err := clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), providerSecretName(provider, kind, name), metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
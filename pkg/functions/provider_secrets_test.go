@@ -0,0 +1,53 @@
+package functions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProviderSecret(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	data, err := GetProviderSecret("test", OIDCClientKind, "concourse", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, data, "expected no stored secret")
+
+	for _, secret := range []string{"test-secret", "test-rotated"} {
+		if err := StoreProviderSecret("test", OIDCClientKind, "concourse", "test", map[string][]byte{"client-secret": []byte(secret)}, clientset); err != nil {
+			t.Fatal(err)
+		}
+		data, err = GetProviderSecret("test", OIDCClientKind, "concourse", "test", clientset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string][]byte{"client-secret": []byte(secret)}, data)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "test-oidc-client-concourse", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test", secret.Labels["app.kubernetes.io/part-of"], "expected the secret to be part of the sso provider")
+
+	// the kinds of the same client are stored apart.
+	data, err = GetProviderSecret("test", LDAPClientKind, "concourse", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, data, "expected no secret of the other kind")
+
+	for i := 0; i < 2; i++ {
+		if err := DeleteProviderSecret("test", OIDCClientKind, "concourse", "test", clientset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err = GetProviderSecret("test", OIDCClientKind, "concourse", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, data, "expected the secret to be deleted")
+}
@@ -0,0 +1,156 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported is returned by the provider methods that the provider
+// does not support.
+var ErrUnsupported = errors.New("the operation is not supported by the provider")
+
+// SSOProvider is an sso provider component. The methods receive the
+// function params, which name the oidc client or user.
+type SSOProvider interface {
+	// CreateOIDCClient creates an oidc client and returns its clientId
+	// and clientSecret. Existing clients are kept and their credentials
+	// are returned, unless the rotate param regenerates the client
+	// secret.
+	CreateOIDCClient(params map[string]interface{}) (interface{}, error)
+	// GetOIDCClient returns the clientId, clientSecret and redirectUris
+	// of the oidc client. A nil result is returned when it does not
+	// exist.
+	GetOIDCClient(params map[string]interface{}) (interface{}, error)
+	// UpdateOIDCClient sets the redirect uris of an existing oidc
+	// client.
+	UpdateOIDCClient(params map[string]interface{}) (interface{}, error)
+	// DeleteOIDCClient deletes the oidc client. Missing clients are
+	// ignored.
+	DeleteOIDCClient(params map[string]interface{}) (interface{}, error)
+	// CreateUser creates a user and returns the username and whether it
+	// was created. Existing users are kept unchanged, and are only sent
+	// another invite until they first log in, so that imports can be
+	// repeated and a failed invite is resumed.
+	CreateUser(params map[string]interface{}) (interface{}, error)
+	// AddUserToGroup adds a user to a group of the role model. Adding a
+	// member to a group is not an error.
+	AddUserToGroup(params map[string]interface{}) (interface{}, error)
+	// RemoveUser deletes a user. Missing users are ignored.
+	RemoveUser(params map[string]interface{}) (interface{}, error)
+	// CreateLDAPClient creates an ldap client with a bind account and
+	// returns its baseDN, bindDN, bindPassword and host. The client is
+	// published to the system vars and secrets of the client component.
+	// Existing clients are kept, unless the rotate param regenerates
+	// the bind password.
+	CreateLDAPClient(params map[string]interface{}) (interface{}, error)
+	// DeleteLDAPClient deletes the ldap client and its bind account.
+	// Missing clients are ignored.
+	DeleteLDAPClient(params map[string]interface{}) (interface{}, error)
+	// CreateSAMLClient creates a saml client from the acsURL, entityID
+	// and attributeMappings params, and returns the identity provider
+	// metadata, ssoURL and signing certificate. Existing clients are
+	// updated, and their cached metadata is returned.
+	CreateSAMLClient(params map[string]interface{}) (interface{}, error)
+	// DeleteSAMLClient deletes the saml client. Missing clients are
+	// ignored.
+	DeleteSAMLClient(params map[string]interface{}) (interface{}, error)
+}
+
+// CIProvider is a ci provider component. The methods receive the
+// function params, which name the toolchain and the application.
+type CIProvider interface {
+	// CreateApplication creates the application pipeline. Creating an
+	// existing application is not an error.
+	CreateApplication(params map[string]interface{}) (interface{}, error)
+	// UpdateApplication updates the pipeline of an existing
+	// application with the current application inputs.
+	UpdateApplication(params map[string]interface{}) (interface{}, error)
+	// DeleteApplication deletes the application pipeline. Missing
+	// applications are ignored.
+	DeleteApplication(params map[string]interface{}) (interface{}, error)
+	// ApplicationStatus returns whether the application exists, and
+	// the provider specific status of its pipeline.
+	ApplicationStatus(params map[string]interface{}) (interface{}, error)
+}
+
+var ssoProviders = map[string]SSOProvider{}
+
+var ciProviders = map[string]CIProvider{}
+
+// RegisterSSOProvider adds the sso provider to the provider registry.
+func RegisterSSOProvider(name string, provider SSOProvider) {
+	ssoProviders[name] = provider
+}
+
+// RegisterCIProvider adds the ci provider to the provider registry.
+func RegisterCIProvider(name string, provider CIProvider) {
+	ciProviders[name] = provider
+}
+
+// getSSOProvider gets the sso provider named in the params.
+func getSSOProvider(params map[string]interface{}) (SSOProvider, error) {
+	name, ok := params["provider"].(string)
+	if !ok {
+		return nil, errors.New("provider is required")
+	}
+	provider, ok := ssoProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("sso provider '%s' not found", name)
+	}
+	return provider, nil
+}
+
+// getCIProvider gets the ci provider named in the params.
+func getCIProvider(params map[string]interface{}) (CIProvider, error) {
+	name, ok := params["provider"].(string)
+	if !ok {
+		return nil, errors.New("provider is required")
+	}
+	provider, ok := ciProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("ci provider '%s' not found", name)
+	}
+	return provider, nil
+}
+
+// ssoProviderMethod creates a function that calls the method of the sso
+// provider named in the params.
+func ssoProviderMethod(method func(SSOProvider, map[string]interface{}) (interface{}, error)) func(map[string]interface{}) (interface{}, error) {
+	return func(params map[string]interface{}) (interface{}, error) {
+		provider, err := getSSOProvider(params)
+		if err != nil {
+			return nil, err
+		}
+		return method(provider, params)
+	}
+}
+
+// ciProviderMethod creates a function that calls the method of the ci
+// provider named in the params.
+func ciProviderMethod(method func(CIProvider, map[string]interface{}) (interface{}, error)) func(map[string]interface{}) (interface{}, error) {
+	return func(params map[string]interface{}) (interface{}, error) {
+		provider, err := getCIProvider(params)
+		if err != nil {
+			return nil, err
+		}
+		return method(provider, params)
+	}
+}
+
+func init() {
+	registerMethod("create-oidc-client", ssoProviderMethod(SSOProvider.CreateOIDCClient))
+	registerMethod("get-oidc-client", ssoProviderMethod(SSOProvider.GetOIDCClient))
+	registerMethod("update-oidc-client", ssoProviderMethod(SSOProvider.UpdateOIDCClient))
+	registerMethod("delete-oidc-client", ssoProviderMethod(SSOProvider.DeleteOIDCClient))
+	registerMethod("create-user", ssoProviderMethod(SSOProvider.CreateUser))
+	registerMethod("add-user-to-group", ssoProviderMethod(SSOProvider.AddUserToGroup))
+	registerMethod("remove-user", ssoProviderMethod(SSOProvider.RemoveUser))
+	registerMethod("create-ldap-client", ssoProviderMethod(SSOProvider.CreateLDAPClient))
+	registerMethod("delete-ldap-client", ssoProviderMethod(SSOProvider.DeleteLDAPClient))
+	registerMethod("create-saml-client", ssoProviderMethod(SSOProvider.CreateSAMLClient))
+	registerMethod("delete-saml-client", ssoProviderMethod(SSOProvider.DeleteSAMLClient))
+	registerMethod("create-application", ciProviderMethod(CIProvider.CreateApplication))
+	registerMethod("update-application", ciProviderMethod(CIProvider.UpdateApplication))
+	registerMethod("delete-application", ciProviderMethod(CIProvider.DeleteApplication))
+	registerMethod("application-status", ciProviderMethod(CIProvider.ApplicationStatus))
+}
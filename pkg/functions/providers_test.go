@@ -0,0 +1,108 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testProvider is an sso and ci provider whose methods return the name
+// of the called function.
+type testProvider struct{}
+
+func (testProvider) CreateOIDCClient(map[string]interface{}) (interface{}, error) {
+	return "create-oidc-client", nil
+}
+
+func (testProvider) GetOIDCClient(map[string]interface{}) (interface{}, error) {
+	return "get-oidc-client", nil
+}
+
+func (testProvider) UpdateOIDCClient(map[string]interface{}) (interface{}, error) {
+	return "update-oidc-client", nil
+}
+
+func (testProvider) DeleteOIDCClient(map[string]interface{}) (interface{}, error) {
+	return "delete-oidc-client", nil
+}
+
+func (testProvider) CreateUser(map[string]interface{}) (interface{}, error) {
+	return "create-user", nil
+}
+
+func (testProvider) AddUserToGroup(map[string]interface{}) (interface{}, error) {
+	return "add-user-to-group", nil
+}
+
+func (testProvider) RemoveUser(map[string]interface{}) (interface{}, error) {
+	return "remove-user", nil
+}
+
+func (testProvider) CreateLDAPClient(map[string]interface{}) (interface{}, error) {
+	return "create-ldap-client", nil
+}
+
+func (testProvider) DeleteLDAPClient(map[string]interface{}) (interface{}, error) {
+	return "delete-ldap-client", nil
+}
+
+func (testProvider) CreateSAMLClient(map[string]interface{}) (interface{}, error) {
+	return "create-saml-client", nil
+}
+
+func (testProvider) DeleteSAMLClient(map[string]interface{}) (interface{}, error) {
+	return "delete-saml-client", nil
+}
+
+func (testProvider) CreateApplication(map[string]interface{}) (interface{}, error) {
+	return "create-application", nil
+}
+
+func (testProvider) UpdateApplication(map[string]interface{}) (interface{}, error) {
+	return "update-application", nil
+}
+
+func (testProvider) DeleteApplication(map[string]interface{}) (interface{}, error) {
+	return "delete-application", nil
+}
+
+func (testProvider) ApplicationStatus(map[string]interface{}) (interface{}, error) {
+	return "application-status", nil
+}
+
+func TestProviderMethods(t *testing.T) {
+	RegisterSSOProvider("test", testProvider{})
+	RegisterCIProvider("test", testProvider{})
+	defer delete(ssoProviders, "test")
+	defer delete(ciProviders, "test")
+
+	for _, method := range []string{
+		"create-oidc-client",
+		"get-oidc-client",
+		"update-oidc-client",
+		"delete-oidc-client",
+		"create-user",
+		"add-user-to-group",
+		"remove-user",
+		"create-ldap-client",
+		"delete-ldap-client",
+		"create-saml-client",
+		"delete-saml-client",
+		"create-application",
+		"update-application",
+		"delete-application",
+		"application-status",
+	} {
+		result, err := Call(method, []byte(`{"provider": "test"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, method, result, "expected the provider method to be called")
+		if _, err := Call(method, []byte(`{}`)); err == nil {
+			t.Errorf("%s: expected a missing provider error", method)
+		}
+		if _, err := Call(method, []byte(`{"provider": "missing"}`)); err == nil {
+			t.Errorf("%s: expected a provider not found error", method)
+		}
+	}
+}
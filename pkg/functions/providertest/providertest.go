@@ -0,0 +1,347 @@
+// Package providertest contains the contract tests of the sso and ci
+// providers. Each provider component runs them against its fake
+// service, so that the functions behave the same for every provider.
+package providertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/functions"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/rbac"
+	"github.com/trustacks/catalog/pkg/roles"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// oidcClientParams returns the function params of the contract test
+// oidc client.
+func oidcClientParams() map[string]interface{} {
+	return map[string]interface{}{
+		"name":          "contract-test",
+		"callbackPaths": []interface{}{"/auth/callback"},
+		"domain":        "local.gd",
+		"tls":           "false",
+		"ingressPort":   "8081",
+	}
+}
+
+// SSOProvider runs the contract tests of the sso provider. The user,
+// ldap client and saml client tests are skipped when the provider does
+// not support them.
+func SSOProvider(t *testing.T, provider functions.SSOProvider) {
+	t.Run("OIDCClient", func(t *testing.T) {
+		testOIDCClient(t, provider)
+	})
+	t.Run("Users", func(t *testing.T) {
+		testUsers(t, provider)
+	})
+	t.Run("LDAPClient", func(t *testing.T) {
+		testLDAPClient(t, provider)
+	})
+	t.Run("SAMLClient", func(t *testing.T) {
+		testSAMLClient(t, provider)
+	})
+}
+
+// SSOProviderRBAC runs the contract tests of the sso provider and
+// verifies that the functions only use the permissions granted to the
+// calling hooks in the namespace when the sso parameter is the
+// provider.
+func SSOProviderRBAC(t *testing.T, provider functions.SSOProvider, sso, namespace string, clientset *fake.Clientset) {
+	manifest := hooks.Manifest{
+		Component: "caller",
+		Jobs:      []hooks.Job{{Hook: hooks.PreInstallHook, Rules: functions.LDAPClientRules("contract-test")}},
+	}
+	data, err := manifest.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := rbac.RenderManifests(string(data), map[string]interface{}{"image": "test", "sso": sso})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := rbac.Grants(manifests, namespace, "caller-"+hooks.PreInstallHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accesses, err := rbac.Record(clientset, func() error {
+		SSOProvider(t, provider)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := rbac.Verify(grants, accesses)
+	assert.Empty(t, report.Missing, "the %s functions are missing permissions:\n%s", sso, report)
+}
+
+func testOIDCClient(t *testing.T, provider functions.SSOProvider) {
+	if _, err := provider.CreateOIDCClient(map[string]interface{}{}); err == nil {
+		t.Fatal("expected a missing name error")
+	}
+	params := oidcClientParams()
+	redirectURIs, err := functions.RedirectURIs(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := provider.GetOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, result, "expected a missing client")
+
+	result, err = provider.CreateOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := result.(map[string]interface{})
+	assert.NotEmpty(t, credentials["clientId"], "expected a client id")
+	assert.NotEmpty(t, credentials["clientSecret"], "expected a client secret")
+	result, err = provider.GetOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"clientId":     credentials["clientId"],
+		"clientSecret": credentials["clientSecret"],
+		"redirectUris": redirectURIs,
+	}, result, "got an unexpected client")
+
+	// an existing client is reused.
+	result, err = provider.CreateOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, credentials, result, "expected the existing credentials")
+
+	// rotation regenerates the client secret in place.
+	params["rotate"] = true
+	result, err = provider.CreateOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(params, "rotate")
+	rotated := result.(map[string]interface{})
+	assert.Equal(t, credentials["clientId"], rotated["clientId"], "expected the client id to be kept")
+	assert.NotEqual(t, credentials["clientSecret"], rotated["clientSecret"], "expected the client secret to be rotated")
+	result, err = provider.GetOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rotated["clientSecret"], result.(map[string]interface{})["clientSecret"])
+
+	// the redirect uris follow the ingress parameters.
+	params["ingressPort"] = "443"
+	params["tls"] = "true"
+	if _, err := provider.UpdateOIDCClient(params); err != nil {
+		t.Fatal(err)
+	}
+	result, err = provider.GetOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"https://contract-test.local.gd/auth/callback"}, result.(map[string]interface{})["redirectUris"])
+	assert.Equal(t, rotated["clientSecret"], result.(map[string]interface{})["clientSecret"], "expected the client secret to be kept")
+	if _, err := provider.UpdateOIDCClient(map[string]interface{}{"name": "missing"}); err == nil {
+		t.Fatal("expected a missing client error")
+	}
+
+	if _, err := provider.DeleteOIDCClient(params); err != nil {
+		t.Fatal(err)
+	}
+	result, err = provider.GetOIDCClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, result, "expected the client to be deleted")
+	// missing clients are ignored.
+	if _, err := provider.DeleteOIDCClient(params); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testUsers(t *testing.T, provider functions.SSOProvider) {
+	_, err := provider.CreateUser(map[string]interface{}{"username": "contract-test"})
+	if errors.Is(err, functions.ErrUnsupported) {
+		t.Skip("the provider does not support users")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.CreateUser(map[string]interface{}{}); err == nil {
+		t.Fatal("expected a missing username error")
+	}
+	// existing users are kept.
+	result, err := provider.CreateUser(map[string]interface{}{"username": "contract-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, result.(map[string]interface{})["created"], "expected the existing user to be kept")
+
+	// adding a member to a group is not an error.
+	for _, group := range roles.Groups {
+		for i := 0; i < 2; i++ {
+			if _, err := provider.AddUserToGroup(map[string]interface{}{"username": "contract-test", "group": group.Name}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := provider.AddUserToGroup(map[string]interface{}{"username": "contract-test", "group": "missing"}); err == nil {
+		t.Fatal("expected a missing group error")
+	}
+	if _, err := provider.AddUserToGroup(map[string]interface{}{"username": "missing", "group": roles.Groups[0].Name}); err == nil {
+		t.Fatal("expected a missing user error")
+	}
+
+	if _, err := provider.RemoveUser(map[string]interface{}{"username": "contract-test"}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = provider.CreateUser(map[string]interface{}{"username": "contract-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, result.(map[string]interface{})["created"], "expected the user to be deleted")
+	if _, err := provider.RemoveUser(map[string]interface{}{"username": "contract-test"}); err != nil {
+		t.Fatal(err)
+	}
+	// missing users are ignored.
+	if _, err := provider.RemoveUser(map[string]interface{}{"username": "contract-test"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testLDAPClient(t *testing.T, provider functions.SSOProvider) {
+	params := map[string]interface{}{"name": "contract-test"}
+	result, err := provider.CreateLDAPClient(params)
+	if errors.Is(err, functions.ErrUnsupported) {
+		t.Skip("the provider does not support ldap clients")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.CreateLDAPClient(map[string]interface{}{}); err == nil {
+		t.Fatal("expected a missing name error")
+	}
+	client := result.(map[string]interface{})
+	for _, field := range []string{"baseDN", "bindDN", "bindPassword", "host"} {
+		assert.NotEmpty(t, client[field], "expected the %s of the client", field)
+	}
+
+	// an existing client is reused.
+	result, err = provider.CreateLDAPClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, client, result, "expected the existing client")
+
+	// rotation regenerates the bind password.
+	params["rotate"] = true
+	result, err = provider.CreateLDAPClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(params, "rotate")
+	rotated := result.(map[string]interface{})
+	assert.Equal(t, client["bindDN"], rotated["bindDN"], "expected the bind dn to be kept")
+	assert.NotEqual(t, client["bindPassword"], rotated["bindPassword"], "expected the bind password to be rotated")
+
+	if _, err := provider.DeleteLDAPClient(params); err != nil {
+		t.Fatal(err)
+	}
+	// missing clients are ignored.
+	if _, err := provider.DeleteLDAPClient(params); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSAMLClient(t *testing.T, provider functions.SSOProvider) {
+	params := map[string]interface{}{
+		"name":              "contract-test",
+		"acsURL":            "http://contract-test.local.gd:8081/saml/acs",
+		"entityID":          "contract-test",
+		"attributeMappings": []interface{}{"email", "groups"},
+	}
+	result, err := provider.CreateSAMLClient(params)
+	if errors.Is(err, functions.ErrUnsupported) {
+		t.Skip("the provider does not support saml clients")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, param := range []string{"name", "acsURL", "entityID"} {
+		missing := map[string]interface{}{}
+		for k, v := range params {
+			if k != param {
+				missing[k] = v
+			}
+		}
+		if _, err := provider.CreateSAMLClient(missing); err == nil {
+			t.Errorf("expected a missing %s error", param)
+		}
+	}
+	client := result.(map[string]interface{})
+	for _, field := range []string{"metadata", "ssoURL", "certificate"} {
+		assert.NotEmpty(t, client[field], "expected the %s of the client", field)
+	}
+
+	// an existing client is updated and its metadata is kept.
+	params["acsURL"] = "https://contract-test.local.gd/saml/acs"
+	result, err = provider.CreateSAMLClient(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, client, result, "expected the existing metadata")
+
+	if _, err := provider.DeleteSAMLClient(params); err != nil {
+		t.Fatal(err)
+	}
+	// missing clients are ignored.
+	if _, err := provider.DeleteSAMLClient(params); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CIProvider runs the contract tests of the ci provider with the
+// application params, which name the toolchain and the application.
+func CIProvider(t *testing.T, provider functions.CIProvider, params map[string]interface{}) {
+	exists := func() bool {
+		result, err := provider.ApplicationStatus(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.(map[string]interface{})["exists"].(bool)
+	}
+	for name, method := range map[string]func(map[string]interface{}) (interface{}, error){
+		"create": provider.CreateApplication,
+		"update": provider.UpdateApplication,
+		"delete": provider.DeleteApplication,
+		"status": provider.ApplicationStatus,
+	} {
+		if _, err := method(map[string]interface{}{"toolchain": params["toolchain"]}); err == nil {
+			t.Errorf("%s: expected a missing name error", name)
+		}
+	}
+	assert.False(t, exists(), "expected a missing application")
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.CreateApplication(params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.True(t, exists(), "expected the application to be created")
+	if _, err := provider.UpdateApplication(params); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, exists(), "expected the application to be updated")
+
+	if _, err := provider.DeleteApplication(params); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, exists(), "expected the application to be deleted")
+	// missing applications are ignored.
+	if _, err := provider.DeleteApplication(params); err != nil {
+		t.Fatal(err)
+	}
+}
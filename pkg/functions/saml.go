@@ -0,0 +1,97 @@
+package functions
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// samlRedirectBinding is the saml binding of the sso url.
+const samlRedirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+
+// SAMLClient is the identity provider configuration of a saml client.
+type SAMLClient struct {
+	Metadata    string
+	SSOURL      string
+	Certificate string
+}
+
+// Result returns the create saml client function result.
+func (c *SAMLClient) Result() map[string]interface{} {
+	return map[string]interface{}{
+		"metadata":    c.Metadata,
+		"ssoURL":      c.SSOURL,
+		"certificate": c.Certificate,
+	}
+}
+
+// samlMetadata is the identity provider metadata.
+type samlMetadata struct {
+	IDPSSODescriptor struct {
+		KeyDescriptors []struct {
+			Use         string `xml:"use,attr"`
+			Certificate string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// ParseSAMLMetadata reads the redirect binding sso url and the pem
+// encoded signing certificate from the identity provider metadata.
+func ParseSAMLMetadata(metadata string) (*SAMLClient, error) {
+	m := &samlMetadata{}
+	if err := xml.Unmarshal([]byte(metadata), m); err != nil {
+		return nil, err
+	}
+	client := &SAMLClient{Metadata: metadata}
+	for _, service := range m.IDPSSODescriptor.SingleSignOnServices {
+		if service.Binding == samlRedirectBinding {
+			client.SSOURL = service.Location
+		}
+	}
+	if client.SSOURL == "" {
+		return nil, errors.New("the saml metadata has no redirect binding sso url")
+	}
+	for _, key := range m.IDPSSODescriptor.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key.Certificate), ""))
+		if err != nil {
+			return nil, err
+		}
+		client.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		break
+	}
+	if client.Certificate == "" {
+		return nil, errors.New("the saml metadata has no signing certificate")
+	}
+	return client, nil
+}
+
+// Data returns the saml client secret data of the client.
+func (c *SAMLClient) Data() map[string][]byte {
+	return map[string][]byte{
+		"metadata.xml":    []byte(c.Metadata),
+		"sso-url":         []byte(c.SSOURL),
+		"certificate.pem": []byte(c.Certificate),
+	}
+}
+
+// SAMLClientFromData reads the client from the saml client secret
+// data. A nil client is returned for nil data.
+func SAMLClientFromData(data map[string][]byte) *SAMLClient {
+	if data == nil {
+		return nil
+	}
+	return &SAMLClient{
+		Metadata:    string(data["metadata.xml"]),
+		SSOURL:      string(data["sso-url"]),
+		Certificate: string(data["certificate.pem"]),
+	}
+}
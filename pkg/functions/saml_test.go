@@ -0,0 +1,54 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSAMLMetadata is the identity provider metadata of a saml client.
+const testSAMLMetadata = `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="test">
+  <md:IDPSSODescriptor>
+    <md:KeyDescriptor use="encryption">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>ZW5jcnlwdGlvbg==</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>
+        c2lnbmluZw==
+      </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://sso.local.gd/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://sso.local.gd/redirect"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+func TestParseSAMLMetadata(t *testing.T) {
+	client, err := ParseSAMLMetadata(testSAMLMetadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"metadata":    testSAMLMetadata,
+		"ssoURL":      "https://sso.local.gd/redirect",
+		"certificate": "-----BEGIN CERTIFICATE-----\nc2lnbmluZw==\n-----END CERTIFICATE-----\n",
+	}, client.Result())
+
+	for _, metadata := range []string{
+		"not xml",
+		`<EntityDescriptor><IDPSSODescriptor><KeyDescriptor use="signing"><KeyInfo><X509Data><X509Certificate>c2lnbmluZw==</X509Certificate></X509Data></KeyInfo></KeyDescriptor></IDPSSODescriptor></EntityDescriptor>`,
+		`<EntityDescriptor><IDPSSODescriptor><SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://sso.local.gd/redirect"/></IDPSSODescriptor></EntityDescriptor>`,
+	} {
+		if _, err := ParseSAMLMetadata(metadata); err == nil {
+			t.Errorf("expected an error for metadata: %s", metadata)
+		}
+	}
+}
+
+func TestSAMLClientData(t *testing.T) {
+	assert.Nil(t, SAMLClientFromData(nil), "expected no client without a secret")
+	client, err := ParseSAMLMetadata(testSAMLMetadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, client, SAMLClientFromData(client.Data()))
+}
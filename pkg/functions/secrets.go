@@ -0,0 +1,33 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/trustacks/catalog/pkg/inputs"
+	"github.com/trustacks/catalog/pkg/logging"
+)
+
+// encryptSystemSecrets migrates the system secrets of a toolchain to
+// the encrypted storage. It is run once when the encryptSystemSecrets
+// catalog parameter is turned on for an existing toolchain.
+func encryptSystemSecrets(params map[string]interface{}) (interface{}, error) {
+	toolchain, ok := params["toolchain"].(string)
+	if !ok {
+		return nil, errors.New("toolchain is required")
+	}
+	namespace := fmt.Sprintf("trustacks-toolchain-%s", toolchain)
+	clientset, err := newClientset()
+	if err != nil {
+		return nil, err
+	}
+	if err := inputs.EncryptSystemSecrets(namespace, clientset); err != nil {
+		return nil, err
+	}
+	logging.Info("encrypted the system secrets", "toolchain", toolchain)
+	return nil, nil
+}
+
+func init() {
+	registerMethod("encrypt-system-secrets", encryptSystemSecrets)
+}
@@ -0,0 +1,46 @@
+package functions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/inputs"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEncryptSystemSecrets(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	defer func(fn func() (kubernetes.Interface, error)) { newClientset = fn }(newClientset)
+	newClientset = func() (kubernetes.Interface, error) {
+		return clientset, nil
+	}
+	namespace := "trustacks-toolchain-test"
+	if err := inputs.AddSystemSecrets("test", namespace, map[string][]byte{"password": []byte("password")}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inputstest.CreateToolchainKey(namespace, clientset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Call("encrypt-system-secrets", []byte(`{"toolchain": "test"}`)); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(string(secret.Data["test.password"]), "-----BEGIN AGE ENCRYPTED FILE-----"), "expected an encrypted password value")
+	data, err := inputs.GetSystemSecrets(namespace, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "password", string(data["test.password"]), "got an unexpected decrypted password value")
+
+	_, err = Call("encrypt-system-secrets", []byte(`{}`))
+	assert.Equal(t, "toolchain is required", err.Error(), "expected a toolchain required error")
+}
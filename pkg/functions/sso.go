@@ -1,31 +1,115 @@
 package functions
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+
+	"github.com/trustacks/catalog/pkg/hooks"
 )
 
-var createOIDCclientHandlers = map[string]func(params map[string]interface{}) (interface{}, error){}
+// SSOProviderRules returns the permissions used by the sso provider
+// functions of the client in the namespace of the calling hook. Only
+// the rules of the sso provider parameter are granted. The authentik
+// and keycloak functions read their api credentials and manage the
+// client secrets, and the dex functions update the dex config secret
+// and restart dex.
+func SSOProviderRules(client string) []hooks.Rule {
+	return hooks.JoinRules(
+		providerRules("authentik", "authentik-bootstrap", client, OIDCClientKind, LDAPClientKind, SAMLClientKind),
+		providerRules("keycloak", "keycloak-admin", client, OIDCClientKind),
+		[]hooks.Rule{
+			{Resources: []string{"secrets"}, ResourceNames: []string{"dex-config"}, Verbs: []string{"get", "update"}, Condition: `eq .sso "dex"`},
+			{APIGroup: "apps", Resources: []string{"deployments"}, ResourceNames: []string{"dex"}, Verbs: []string{"patch"}, Condition: `eq .sso "dex"`},
+		},
+	)
+}
 
-// createOIDCClient creates an openid connection authentication
-// client.
-func createOIDCClient(params map[string]interface{}) (interface{}, error) {
-	provider, ok := params["provider"]
-	if !ok {
-		return nil, errors.New("provider is required")
+// providerRules returns the permissions used by the functions of the
+// sso provider that read the api credentials secret and manage the
+// secrets of the client kinds.
+func providerRules(provider, credentials, client string, kinds ...string) []hooks.Rule {
+	condition := fmt.Sprintf("eq .sso %q", provider)
+	names := []string{}
+	for _, kind := range kinds {
+		names = append(names, providerSecretName(provider, kind, client))
 	}
-	method, ok := createOIDCclientHandlers[provider.(string)]
-	if !ok {
-		return nil, errors.New("method handler not foud")
+	return []hooks.Rule{
+		{Resources: []string{"secrets"}, Verbs: []string{"create"}, Condition: condition},
+		{Resources: []string{"secrets"}, ResourceNames: append([]string{credentials}, names...), Verbs: []string{"get"}, Condition: condition},
+		{Resources: []string{"secrets"}, ResourceNames: names, Verbs: []string{"update", "delete"}, Condition: condition},
 	}
-	return method(params)
 }
 
-// AddCreateOIDCClientHandler adds the create oidc client handler
-// method.
-func AddCreateOIDCClientHandler(name string, handler func(params map[string]interface{}) (interface{}, error)) {
-	createOIDCclientHandlers[name] = handler
+// OIDCClientCredentials are the credentials of an oidc client.
+type OIDCClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// Result returns the create oidc client function result.
+func (c *OIDCClientCredentials) Result() map[string]interface{} {
+	return map[string]interface{}{"clientId": c.ClientID, "clientSecret": c.ClientSecret}
 }
 
-func init() {
-	registerMethod("create-oidc-client", createOIDCClient)
+// Data returns the oidc client secret data of the credentials.
+func (c *OIDCClientCredentials) Data() map[string][]byte {
+	return map[string][]byte{
+		"client-id":     []byte(c.ClientID),
+		"client-secret": []byte(c.ClientSecret),
+	}
+}
+
+// OIDCClientCredentialsFromData reads the credentials from the oidc
+// client secret data. Nil credentials are returned for nil data.
+func OIDCClientCredentialsFromData(data map[string][]byte) *OIDCClientCredentials {
+	if data == nil {
+		return nil
+	}
+	return &OIDCClientCredentials{ClientID: string(data["client-id"]), ClientSecret: string(data["client-secret"])}
+}
+
+// OIDCClientParams creates the oidc client function params of a
+// component. The ingress parameters are read from the hook environment.
+func OIDCClientParams(name, provider string, callbackPaths ...string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":          name,
+		"provider":      provider,
+		"callbackPaths": callbackPaths,
+		"domain":        os.Getenv("DOMAIN"),
+		"tls":           os.Getenv("TLS"),
+		"ingressPort":   os.Getenv("INGRESS_PORT"),
+	})
+}
+
+// RedirectURIs computes the redirect uris of an oidc client from the
+// callback paths and the ingress parameters. The client component is
+// served at the host named after the client.
+func RedirectURIs(params map[string]interface{}) ([]string, error) {
+	paths, _ := params["callbackPaths"].([]interface{})
+	uris := []string{}
+	if len(paths) == 0 {
+		return uris, nil
+	}
+	name, ok := params["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	domain, ok := params["domain"].(string)
+	if !ok || domain == "" {
+		return nil, errors.New("domain is required")
+	}
+	scheme := "http"
+	if tls, _ := params["tls"].(string); tls == "true" {
+		scheme = "https"
+	}
+	host := fmt.Sprintf("%s.%s", name, domain)
+	if port, _ := params["ingressPort"].(string); port != "" && port != "80" && port != "443" {
+		host = fmt.Sprintf("%s:%s", host, port)
+	}
+	for _, path := range paths {
+		uris = append(uris, fmt.Sprintf("%s://%s%v", scheme, host, path))
+	}
+	return uris, nil
 }
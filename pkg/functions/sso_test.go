@@ -1,18 +1,55 @@
 package functions
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestSSOHandler(t *testing.T) {
-	createOIDCclientHandlers["test"] = func(params map[string]interface{}) (interface{}, error) {
-		return 42, nil
+func TestRedirectURIs(t *testing.T) {
+	tests := []struct {
+		tls         string
+		ingressPort string
+		uri         string
+	}{
+		{"true", "443", "https://concourse.local.gd/sky/issuer/callback"},
+		{"false", "80", "http://concourse.local.gd/sky/issuer/callback"},
+		{"false", "8081", "http://concourse.local.gd:8081/sky/issuer/callback"},
 	}
-	result, err := Call("create-oidc-client", []byte(`{"provider": "test"}`))
+	for _, tc := range tests {
+		t.Setenv("DOMAIN", "local.gd")
+		t.Setenv("TLS", tc.tls)
+		t.Setenv("INGRESS_PORT", tc.ingressPort)
+		data, err := OIDCClientParams("concourse", "test", "/sky/issuer/callback")
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := map[string]interface{}{}
+		if err := json.Unmarshal(data, &params); err != nil {
+			t.Fatal(err)
+		}
+		uris, err := RedirectURIs(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []string{tc.uri}, uris)
+	}
+
+	// clients without callback paths have no redirect uris.
+	uris, err := RedirectURIs(map[string]interface{}{"name": "test"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	assert.Equal(t, 42, result.(int), "got an unexpected result")
+	assert.Empty(t, uris)
+	if _, err := RedirectURIs(map[string]interface{}{"name": "test", "callbackPaths": []interface{}{"/callback"}}); err == nil {
+		t.Fatal("expected a missing domain error")
+	}
+}
+
+func TestOIDCClientCredentials(t *testing.T) {
+	assert.Nil(t, OIDCClientCredentialsFromData(nil), "expected no credentials without a secret")
+	credentials := &OIDCClientCredentials{"test-id", "test-secret"}
+	assert.Equal(t, credentials, OIDCClientCredentialsFromData(credentials.Data()))
+	assert.Equal(t, map[string]interface{}{"clientId": "test-id", "clientSecret": "test-secret"}, credentials.Result())
 }
@@ -8,3 +8,16 @@ func PatchMockFunction(name string, fn func(params map[string]interface{}) (inte
 		dispatcher.methods[name] = previousMethod
 	}
 }
+
+// PatchSSOProvider patches the provider registry with the sso provider.
+func PatchSSOProvider(name string, provider SSOProvider) func() {
+	previousProvider, ok := ssoProviders[name]
+	ssoProviders[name] = provider
+	return func() {
+		if !ok {
+			delete(ssoProviders, name)
+			return
+		}
+		ssoProviders[name] = previousProvider
+	}
+}
@@ -0,0 +1,123 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// user is an imported sso user.
+type user struct {
+	Username string   `yaml:"username"`
+	Email    string   `yaml:"email"`
+	Name     string   `yaml:"name"`
+	Groups   []string `yaml:"groups"`
+}
+
+// parseUsers parses a yaml or csv user list. The csv header names the
+// username, email, name and groups columns, and the groups are
+// separated by semicolons.
+func parseUsers(data, format string) ([]user, error) {
+	users := []user{}
+	switch format {
+	case "", "yaml":
+		if err := yaml.Unmarshal([]byte(data), &users); err != nil {
+			return nil, err
+		}
+	case "csv":
+		reader := csv.NewReader(bytes.NewBufferString(data))
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			u := user{}
+			for i, column := range header {
+				switch strings.TrimSpace(column) {
+				case "username":
+					u.Username = record[i]
+				case "email":
+					u.Email = record[i]
+				case "name":
+					u.Name = record[i]
+				case "groups":
+					for _, group := range strings.Split(record[i], ";") {
+						if group = strings.TrimSpace(group); group != "" {
+							u.Groups = append(u.Groups, group)
+						}
+					}
+				default:
+					return nil, fmt.Errorf("unknown column '%s'", column)
+				}
+			}
+			users = append(users, u)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format '%s'", format)
+	}
+	for i, u := range users {
+		if u.Username == "" {
+			return nil, fmt.Errorf("user %d: username is required", i+1)
+		}
+	}
+	return users, nil
+}
+
+// importUsers creates the users of a yaml or csv list and adds them to
+// their groups. Existing users are kept, so the import can be repeated.
+func importUsers(params map[string]interface{}) (interface{}, error) {
+	provider, err := getSSOProvider(params)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := params["users"].(string)
+	if !ok {
+		return nil, errors.New("users is required")
+	}
+	format, _ := params["format"].(string)
+	users, err := parseUsers(data, format)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, u := range users {
+		userParams := map[string]interface{}{
+			"provider": params["provider"],
+			"username": u.Username,
+			"email":    u.Email,
+			"name":     u.Name,
+		}
+		// the provider specific invite options are passed through.
+		if emailStage, ok := params["emailStage"]; ok {
+			userParams["emailStage"] = emailStage
+		}
+		result, err := provider.CreateUser(userParams)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", u.Username, err)
+		}
+		results = append(results, result)
+		for _, group := range u.Groups {
+			if _, err := provider.AddUserToGroup(map[string]interface{}{"provider": params["provider"], "username": u.Username, "group": group}); err != nil {
+				return nil, fmt.Errorf("%s: %s", u.Username, err)
+			}
+		}
+	}
+	return results, nil
+}
+
+func init() {
+	registerMethod("import-users", importUsers)
+}
@@ -0,0 +1,92 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUsers(t *testing.T) {
+	expected := []user{
+		{Username: "jdoe", Email: "jdoe@example.com", Name: "Jane Doe", Groups: []string{"admins", "editors"}},
+		{Username: "rroe", Email: "rroe@example.com"},
+	}
+	users, err := parseUsers(`
+- username: jdoe
+  email: jdoe@example.com
+  name: Jane Doe
+  groups: [admins, editors]
+- username: rroe
+  email: rroe@example.com
+`, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, users, "got unexpected yaml users")
+
+	users, err = parseUsers("username,email,name,groups\njdoe,jdoe@example.com,Jane Doe,admins; editors\nrroe,rroe@example.com,,\n", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, users, "got unexpected csv users")
+
+	for _, tc := range []struct {
+		data   string
+		format string
+	}{
+		{"- email: jdoe@example.com", "yaml"},
+		{"username,phone\njdoe,555", "csv"},
+		{"jdoe", "json"},
+	} {
+		if _, err := parseUsers(tc.data, tc.format); err == nil {
+			t.Errorf("expected a parsing error for '%s'", tc.data)
+		}
+	}
+}
+
+// importProvider is an sso provider that records the imported users
+// and group memberships.
+type importProvider struct {
+	testProvider
+	created     map[string]bool
+	memberships map[string]bool
+}
+
+func (p *importProvider) CreateUser(params map[string]interface{}) (interface{}, error) {
+	username := params["username"].(string)
+	result := map[string]interface{}{"username": username, "created": !p.created[username]}
+	p.created[username] = true
+	return result, nil
+}
+
+func (p *importProvider) AddUserToGroup(params map[string]interface{}) (interface{}, error) {
+	p.memberships[params["username"].(string)+"/"+params["group"].(string)] = true
+	return nil, nil
+}
+
+func TestImportUsers(t *testing.T) {
+	provider := &importProvider{created: map[string]bool{}, memberships: map[string]bool{}}
+	RegisterSSOProvider("import", provider)
+	defer delete(ssoProviders, "import")
+	params := []byte(`{"provider": "import", "format": "csv", "users": "username,groups\njdoe,editors;viewers\nrroe,viewers"}`)
+	result, err := Call("import-users", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"username": "jdoe", "created": true},
+		map[string]interface{}{"username": "rroe", "created": true},
+	}, result, "got an unexpected result")
+	assert.Equal(t, map[string]bool{"jdoe/editors": true, "jdoe/viewers": true, "rroe/viewers": true}, provider.memberships)
+
+	// a repeated import keeps the existing users.
+	result, err = Call("import-users", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, result.([]interface{})[0].(map[string]interface{})["created"])
+
+	if _, err := Call("import-users", []byte(`{"provider": "import"}`)); err == nil {
+		t.Fatal("expected a missing users error")
+	}
+}
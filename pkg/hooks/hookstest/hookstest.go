@@ -0,0 +1,36 @@
+// Package hookstest contains the test helpers of the component hook
+// manifests. It is only imported by tests.
+package hookstest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/hooks"
+)
+
+// UpdateManifestsEnv is the environment variable that makes
+// CheckManifest write the rendered manifest instead of comparing it.
+const UpdateManifestsEnv = "UPDATE_MANIFESTS"
+
+// CheckManifest checks that the generated hook manifest at path
+// matches the rendered manifest. The manifest is written to path when
+// UpdateManifestsEnv is set to true.
+func CheckManifest(t *testing.T, manifest hooks.Manifest, path string) {
+	data, err := manifest.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(UpdateManifestsEnv) == "true" {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	generated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), string(generated), "%s is out of date, run make manifests", path)
+}
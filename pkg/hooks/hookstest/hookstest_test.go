@@ -0,0 +1,24 @@
+package hookstest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/hooks"
+)
+
+func TestCheckManifest(t *testing.T) {
+	m := hooks.Manifest{Component: "test", Jobs: []hooks.Job{{Hook: hooks.PreInstallHook}}}
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	t.Setenv(UpdateManifestsEnv, "true")
+	CheckManifest(t, m, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(data), "test-pre-install", "expected the manifest to be written")
+	t.Setenv(UpdateManifestsEnv, "")
+	CheckManifest(t, m, path)
+}
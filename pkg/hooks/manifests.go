@@ -0,0 +1,304 @@
+package hooks
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// verbOrder is the order of the verbs in the generated rules.
+var verbOrder = []string{"create", "get", "list", "watch", "update", "patch", "delete"}
+
+// Rule is a kubernetes permission needed by a hook. Rules with
+// resource names only grant the named objects. Rules with a condition
+// are only granted when the catalog template condition holds, such as
+// eq .sso "dex".
+type Rule struct {
+	APIGroup      string
+	Resources     []string
+	ResourceNames []string
+	Verbs         []string
+	Condition     string
+}
+
+// JoinRules joins the rule sets of a hook.
+func JoinRules(sets ...[]Rule) []Rule {
+	rules := []Rule{}
+	for _, set := range sets {
+		rules = append(rules, set...)
+	}
+	return rules
+}
+
+// EnvVar is a hook job environment variable. Values may contain
+// catalog parameters, such as {{ .sso }}. Secret values are stored in
+// the hook env secret and referenced by the jobs, so that they are
+// kept out of the job specs.
+type EnvVar struct {
+	Name   string
+	Value  string
+	Secret bool
+}
+
+// common hook job environment variables.
+var (
+	// SSOProviderEnv passes the sso provider to the hook.
+	SSOProviderEnv = EnvVar{Name: "SSO_PROVIDER", Value: "{{ .sso }}"}
+	// ReleaseRevisionEnv passes the helm release revision to the
+	// hook. The helm template is escaped from the catalog rendering.
+	ReleaseRevisionEnv = EnvVar{Name: "RELEASE_REVISION", Value: "\"{{`{{ .Release.Revision }}`}}\""}
+	// IngressEnv passes the ingress parameters used to compute the
+	// component urls to the hook.
+	IngressEnv = []EnvVar{
+		{Name: "DOMAIN", Value: "{{ .domain }}"},
+		{Name: "TLS", Value: "\"{{ .tls }}\""},
+		{Name: "INGRESS_PORT", Value: "\"{{ .ingressPort }}\""},
+	}
+)
+
+// Job declares a component hook and the permissions it needs.
+type Job struct {
+	Hook  string
+	Env   []EnvVar
+	Rules []Rule
+}
+
+// Manifest declares the hooks of a component.
+type Manifest struct {
+	Component string
+	Jobs      []Job
+}
+
+// hookNames returns the comma separated hook names of the jobs.
+func (m *Manifest) hookNames() string {
+	names := []string{}
+	for _, job := range m.Jobs {
+		names = append(names, job.Hook)
+	}
+	return strings.Join(names, ",")
+}
+
+// secretEnv returns the secret environment variables of the jobs.
+// Variables shared by several jobs are stored once.
+func (m *Manifest) secretEnv() []EnvVar {
+	env := []EnvVar{}
+	seen := map[string]bool{}
+	for _, job := range m.Jobs {
+		for _, v := range job.Env {
+			if v.Secret && !seen[v.Name] {
+				seen[v.Name] = true
+				env = append(env, v)
+			}
+		}
+	}
+	return env
+}
+
+// Rules merges the rules of every job. Resources in the same api
+// group that need the same verbs on the same names under the same
+// condition share a rule.
+func (m *Manifest) Rules() []Rule {
+	type resource struct{ group, name, names, condition string }
+	resources := []resource{}
+	resourceNames := map[resource][]string{}
+	verbs := map[resource]map[string]bool{}
+	for _, job := range m.Jobs {
+		for _, rule := range job.Rules {
+			for _, name := range rule.Resources {
+				r := resource{rule.APIGroup, name, strings.Join(rule.ResourceNames, ","), rule.Condition}
+				if _, ok := verbs[r]; !ok {
+					resources = append(resources, r)
+					resourceNames[r] = rule.ResourceNames
+					verbs[r] = map[string]bool{}
+				}
+				for _, verb := range rule.Verbs {
+					verbs[r][verb] = true
+				}
+			}
+		}
+	}
+	rules := []Rule{}
+	index := map[string]int{}
+	for _, r := range resources {
+		ordered := []string{}
+		for _, verb := range verbOrder {
+			if verbs[r][verb] {
+				ordered = append(ordered, verb)
+			}
+		}
+		key := strings.Join([]string{r.group, strings.Join(ordered, ","), r.names, r.condition}, "/")
+		if i, ok := index[key]; ok {
+			rules[i].Resources = append(rules[i].Resources, r.name)
+			continue
+		}
+		index[key] = len(rules)
+		rules = append(rules, Rule{APIGroup: r.group, Resources: []string{r.name}, ResourceNames: resourceNames[r], Verbs: ordered, Condition: r.condition})
+	}
+	return rules
+}
+
+// manifestTemplate is the hook manifests template. It uses custom
+// delimiters since the output contains catalog and helm templates.
+// The job pod settings are catalog parameters shared by every hook,
+// and /tmp is writable when the root filesystem is read-only.
+const manifestTemplate = `[[- $name := printf "%s-hook-rbac" .Component -]]
+[[- $envSecret := printf "%s-hook-env" .Component -]]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: [[ $name ]]
+  annotations:
+    "helm.sh/hook": [[ .Hooks ]]
+    "helm.sh/hook-delete-policy": hook-succeeded
+    "helm.sh/hook-weight": "1"
+rules:
+[[- range .Rules ]]
+[[- if .Condition ]]
+{{- if [[ .Condition ]] }}
+[[- end ]]
+- apiGroups:
+  - "[[ .APIGroup ]]"
+  resources:
+  [[- range .Resources ]]
+  - [[ . ]]
+  [[- end ]]
+  [[- if .ResourceNames ]]
+  resourceNames:
+  [[- range .ResourceNames ]]
+  - [[ . ]]
+  [[- end ]]
+  [[- end ]]
+  verbs:
+  [[- range .Verbs ]]
+  - [[ . ]]
+  [[- end ]]
+[[- if .Condition ]]
+{{- end }}
+[[- end ]]
+[[- end ]]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: [[ $name ]]
+  annotations:
+    "helm.sh/hook": [[ .Hooks ]]
+    "helm.sh/hook-delete-policy": hook-succeeded
+    "helm.sh/hook-weight": "1"
+subjects:
+- kind: ServiceAccount
+  name: [[ $name ]]
+roleRef:
+  kind: Role
+  name: [[ $name ]]
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: [[ $name ]]
+  annotations:
+    "helm.sh/hook": [[ .Hooks ]]
+    "helm.sh/hook-delete-policy": hook-succeeded
+    "helm.sh/hook-weight": "1"
+[[- if .SecretEnv ]]
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: [[ $envSecret ]]
+  annotations:
+    "helm.sh/hook": [[ .Hooks ]]
+    "helm.sh/hook-delete-policy": hook-succeeded
+    "helm.sh/hook-weight": "1"
+stringData:
+[[- range .SecretEnv ]]
+  [[ .Name ]]: [[ .Value ]]
+[[- end ]]
+[[- end ]]
+[[- range .Jobs ]]
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: [[ $.Component ]]-[[ .Hook ]]
+  annotations:
+    "helm.sh/hook": [[ .Hook ]]
+    "helm.sh/hook-delete-policy": hook-succeeded
+    "helm.sh/hook-weight": "2"
+spec:
+  backoffLimit: {{ .hookBackoffLimit }}
+  {{- if .hookActiveDeadlineSeconds }}
+  activeDeadlineSeconds: {{ .hookActiveDeadlineSeconds }}
+  {{- end }}
+  template:
+    spec:
+      restartPolicy: Never
+      nodeSelector: {{ .hookNodeSelector }}
+      tolerations: {{ .hookTolerations }}
+      imagePullSecrets: {{ .hookImagePullSecrets }}
+      containers:
+      - name: [[ .Hook ]]
+        image: {{ if .registryMirror }}{{ .registryMirror }}/{{ regexReplaceAll "^(localhost|[^/]*[.:][^/]*)/" .image "" }}{{ else }}{{ .image }}{{ end }}
+        resources: {{ .hookResources }}
+        securityContext: {{ .hookSecurityContext }}
+        volumeMounts:
+        - name: tmp
+          mountPath: /tmp
+        env:
+        - name: CATALOG_MODE
+          value: hook
+        - name: CATALOG_PUSHGATEWAY_URL
+          value: "{{ .pushgateway }}"
+        - name: CATALOG_LOG_LEVEL
+          value: "{{ .logLevel }}"
+        - name: CATALOG_ENCRYPT_SYSTEM_SECRETS
+          value: "{{ .encryptSystemSecrets }}"
+        - name: HOOK_COMPONENT
+          value: [[ $.Component ]]
+        - name: HOOK_KIND
+          value: [[ .Hook ]]
+        [[- range .Env ]]
+        - name: [[ .Name ]]
+          [[- if .Secret ]]
+          valueFrom:
+            secretKeyRef:
+              name: [[ $envSecret ]]
+              key: [[ .Name ]]
+          [[- else ]]
+          value: [[ .Value ]]
+          [[- end ]]
+        [[- end ]]
+      volumes:
+      - name: tmp
+        emptyDir: {}
+      serviceAccount: [[ $name ]]
+[[- end ]]
+`
+
+// Render generates the Role, RoleBinding and ServiceAccount shared by
+// the component hooks, the env secret of the secret environment
+// variables and a Job for each hook.
+func (m *Manifest) Render() ([]byte, error) {
+	if m.Component == "" || len(m.Jobs) == 0 {
+		return nil, fmt.Errorf("the hook manifest requires a component and at least one job")
+	}
+	tmpl, err := template.New(m.Component).Delims("[[", "]]").Parse(manifestTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	data := map[string]interface{}{
+		"Component": m.Component,
+		"Hooks":     m.hookNames(),
+		"Rules":     m.Rules(),
+		"Jobs":      m.Jobs,
+		"SecretEnv": m.secretEnv(),
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,238 @@
+package hooks
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestManifestRules(t *testing.T) {
+	m := &Manifest{
+		Component: "test",
+		Jobs: []Job{
+			{Hook: PreInstallHook, Rules: []Rule{
+				{Resources: []string{"secrets"}, Verbs: []string{"get", "create"}},
+				{Resources: []string{"configmaps"}, Verbs: []string{"create"}},
+			}},
+			{Hook: PostDeleteHook, Rules: []Rule{
+				{Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				{APIGroup: "apps", Resources: []string{"deployments"}, Verbs: []string{"patch"}},
+			}},
+		},
+	}
+	assert.Equal(t, []Rule{
+		{Resources: []string{"secrets", "configmaps"}, Verbs: []string{"create", "get"}},
+		{APIGroup: "apps", Resources: []string{"deployments"}, Verbs: []string{"patch"}},
+	}, m.Rules())
+}
+
+func TestManifestRulesScoped(t *testing.T) {
+	m := &Manifest{
+		Component: "test",
+		Jobs: []Job{
+			{Hook: PreInstallHook, Rules: []Rule{
+				{Resources: []string{"secrets"}, Verbs: []string{"get"}},
+				{Resources: []string{"secrets"}, ResourceNames: []string{"test-config"}, Verbs: []string{"update"}, Condition: `eq .sso "test"`},
+			}},
+			{Hook: PostDeleteHook, Rules: []Rule{
+				{Resources: []string{"secrets"}, ResourceNames: []string{"test-config"}, Verbs: []string{"get"}, Condition: `eq .sso "test"`},
+				{Resources: []string{"secrets"}, ResourceNames: []string{"test-config"}, Verbs: []string{"delete"}},
+			}},
+		},
+	}
+	assert.Equal(t, []Rule{
+		{Resources: []string{"secrets"}, Verbs: []string{"get"}},
+		{Resources: []string{"secrets"}, ResourceNames: []string{"test-config"}, Verbs: []string{"get", "update"}, Condition: `eq .sso "test"`},
+		{Resources: []string{"secrets"}, ResourceNames: []string{"test-config"}, Verbs: []string{"delete"}},
+	}, m.Rules())
+
+	data, err := m.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sso, want := range map[string]int{"test": 3, "other": 2} {
+		tmpl, err := template.New(sso).Funcs(sprig.TxtFuncMap()).Parse(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, map[string]string{"sso": sso}); err != nil {
+			t.Fatal(err)
+		}
+		role := &rbacv1.Role{}
+		if err := yaml.Unmarshal([]byte(strings.TrimPrefix(strings.Split(buf.String(), "\n---\n")[0], "---\n")), role); err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, role.Rules, want, "%s: got an unexpected number of rules", sso)
+		assert.Equal(t, []string{"test-config"}, role.Rules[len(role.Rules)-1].ResourceNames)
+	}
+}
+
+func TestManifestRender(t *testing.T) {
+	m := &Manifest{
+		Component: "test",
+		Jobs: []Job{
+			{Hook: PreInstallHook, Env: []EnvVar{SSOProviderEnv}, Rules: []Rule{{Resources: []string{"secrets"}, Verbs: []string{"get"}}}},
+			{Hook: PostRollback, Env: []EnvVar{ReleaseRevisionEnv}},
+		},
+	}
+	data, err := m.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := string(data)
+	assert.True(t, strings.HasPrefix(manifest, "---\n"), "expected a leading document separator")
+	assert.Equal(t, 3, strings.Count(manifest, `"helm.sh/hook": pre-install,post-rollback`), "expected the rbac resources to run in every hook")
+	assert.Contains(t, manifest, "name: test-pre-install\n")
+	assert.Contains(t, manifest, "name: test-post-rollback\n")
+	assert.Contains(t, manifest, "- name: SSO_PROVIDER\n          value: {{ .sso }}\n")
+	assert.Contains(t, manifest, "value: \"{{`{{ .Release.Revision }}`}}\"\n")
+
+	if _, err := (&Manifest{Component: "test"}).Render(); err == nil {
+		t.Fatal("expected an error rendering a manifest without jobs")
+	}
+}
+
+func TestManifestRenderSecretEnv(t *testing.T) {
+	secretEnv := EnvVar{Name: "TEST_SECRET", Value: "{{ .testSecret | toJson }}", Secret: true}
+	m := &Manifest{
+		Component: "test",
+		Jobs: []Job{
+			{Hook: PreInstallHook, Env: []EnvVar{secretEnv}},
+			{Hook: PreUpgrade, Env: []EnvVar{SSOProviderEnv, secretEnv}},
+		},
+	}
+	data, err := m.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := template.New("test").Funcs(sprig.TxtFuncMap()).Parse(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]string{
+		"testSecret":           `[{"clientSecret": "it's \"secret\""}]`,
+		"sso":                  "dex",
+		"image":                "quay.io/trustacks/catalog:test",
+		"hookResources":        "{}",
+		"hookNodeSelector":     "{}",
+		"hookTolerations":      "[]",
+		"hookImagePullSecrets": "[]",
+		"hookSecurityContext":  "{}",
+		"hookBackoffLimit":     "6",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, buf.String(), "clientSecret\": \"it's", "expected the secret to be kept out of the jobs")
+	secrets, jobs := 0, 0
+	for _, doc := range strings.Split(buf.String(), "\n---\n") {
+		switch {
+		case strings.Contains(doc, "kind: Secret"):
+			secret := &corev1.Secret{}
+			if err := yaml.UnmarshalStrict([]byte(doc), secret); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "test-hook-env", secret.Name)
+			assert.Equal(t, map[string]string{"TEST_SECRET": `[{"clientSecret": "it's \"secret\""}]`}, secret.StringData)
+			secrets++
+		case strings.Contains(doc, "kind: Job"):
+			job := &batchv1.Job{}
+			if err := yaml.UnmarshalStrict([]byte(doc), job); err != nil {
+				t.Fatal(err)
+			}
+			env := job.Spec.Template.Spec.Containers[0].Env
+			ref := env[len(env)-1].ValueFrom.SecretKeyRef
+			assert.Equal(t, "test-hook-env", ref.Name)
+			assert.Equal(t, "TEST_SECRET", ref.Key)
+			jobs++
+		}
+	}
+	assert.Equal(t, 1, secrets, "expected the secret env to be stored once")
+	assert.Equal(t, 2, jobs)
+}
+
+func TestManifestRenderJobSpec(t *testing.T) {
+	m := &Manifest{Component: "test", Jobs: []Job{{Hook: PreInstallHook}}}
+	data, err := m.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		params   map[string]string
+		validate func(job *batchv1.Job)
+	}{
+		{
+			"defaults",
+			map[string]string{
+				"hookResources":             "{}",
+				"hookNodeSelector":          "{}",
+				"hookTolerations":           "[]",
+				"hookImagePullSecrets":      "[]",
+				"hookSecurityContext":       "{}",
+				"hookBackoffLimit":          "6",
+				"hookActiveDeadlineSeconds": "",
+			},
+			func(job *batchv1.Job) {
+				assert.Equal(t, int32(6), *job.Spec.BackoffLimit)
+				assert.Nil(t, job.Spec.ActiveDeadlineSeconds, "expected no deadline")
+				assert.Empty(t, job.Spec.Template.Spec.Tolerations)
+				assert.Equal(t, "quay.io/trustacks/catalog:test", job.Spec.Template.Spec.Containers[0].Image)
+			},
+		},
+		{
+			"custom",
+			map[string]string{
+				"hookResources":             `{"requests": {"cpu": "100m"}, "limits": {"memory": "256Mi"}}`,
+				"hookNodeSelector":          `{"kubernetes.io/os": "linux"}`,
+				"hookTolerations":           `[{"key": "dedicated", "operator": "Exists", "effect": "NoSchedule"}]`,
+				"hookImagePullSecrets":      `[{"name": "registry-credentials"}]`,
+				"hookSecurityContext":       `{"runAsNonRoot": true, "readOnlyRootFilesystem": true}`,
+				"hookBackoffLimit":          "1",
+				"hookActiveDeadlineSeconds": "600",
+				"registryMirror":            "registry.test:5000",
+			},
+			func(job *batchv1.Job) {
+				spec := job.Spec.Template.Spec
+				container := spec.Containers[0]
+				assert.Equal(t, int32(1), *job.Spec.BackoffLimit)
+				assert.Equal(t, int64(600), *job.Spec.ActiveDeadlineSeconds)
+				assert.Equal(t, "linux", spec.NodeSelector["kubernetes.io/os"])
+				assert.Equal(t, "dedicated", spec.Tolerations[0].Key)
+				assert.Equal(t, "registry-credentials", spec.ImagePullSecrets[0].Name)
+				assert.Equal(t, "100m", container.Resources.Requests.Cpu().String())
+				assert.Equal(t, "256Mi", container.Resources.Limits.Memory().String())
+				assert.True(t, *container.SecurityContext.RunAsNonRoot)
+				assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+				assert.Equal(t, "/tmp", container.VolumeMounts[0].MountPath, "expected a writable tmp directory")
+				assert.Equal(t, "registry.test:5000/trustacks/catalog:test", container.Image)
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc.params["image"] = "quay.io/trustacks/catalog:test"
+		tmpl, err := template.New(tc.name).Funcs(sprig.TxtFuncMap()).Parse(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, tc.params); err != nil {
+			t.Fatal(err)
+		}
+		docs := strings.Split(buf.String(), "\n---\n")
+		job := &batchv1.Job{}
+		if err := yaml.UnmarshalStrict([]byte(docs[len(docs)-1]), job); err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		tc.validate(job)
+	}
+}
@@ -0,0 +1,174 @@
+package inputs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// sopsAgeSecretName is the name of the toolchain age key secret.
+	sopsAgeSecretName = "sops-age"
+	// agePublicKeyField is the age public key field in the age key
+	// secret.
+	agePublicKeyField = "age.agepub"
+	// agePrivateKeyField is the age private key field in the age key
+	// secret.
+	agePrivateKeyField = "age.agekey"
+)
+
+// GetToolchainRecipient gets the toolchain age public key.
+func GetToolchainRecipient(namespace string, clientset kubernetes.Interface) (age.Recipient, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), sopsAgeSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return age.ParseX25519Recipient(strings.TrimSpace(string(secret.Data[agePublicKeyField])))
+}
+
+// GetToolchainIdentities gets the toolchain age private keys.
+func GetToolchainIdentities(namespace string, clientset kubernetes.Interface) ([]age.Identity, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), sopsAgeSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return age.ParseIdentities(bytes.NewReader(secret.Data[agePrivateKeyField]))
+}
+
+// isEncrypted checks if the value is an armored age ciphertext.
+func isEncrypted(value []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(value), []byte(armor.Header))
+}
+
+// encryptValue encrypts the value for the recipient as an armored
+// age ciphertext.
+func encryptValue(recipient age.Recipient, value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	armorWriter := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armorWriter, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := armorWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decryptValue decrypts the armored age ciphertext.
+func decryptValue(identities []age.Identity, value []byte) ([]byte, error) {
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(bytes.TrimSpace(value))), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// GetSystemSecrets gets the plaintext values of the system secrets, so
+// that they can be passed on to the applications. Values that are not
+// encrypted are returned as is, and the toolchain key is only read when
+// a value is encrypted.
+func GetSystemSecrets(namespace string, clientset kubernetes.Interface) (map[string][]byte, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var identities []age.Identity
+	data := map[string][]byte{}
+	for k, v := range secret.Data {
+		if !isEncrypted(v) {
+			data[k] = v
+			continue
+		}
+		if identities == nil {
+			if identities, err = GetToolchainIdentities(namespace, clientset); err != nil {
+				return nil, err
+			}
+		}
+		if data[k], err = decryptValue(identities, v); err != nil {
+			return nil, fmt.Errorf("error decrypting the '%s' system secret: %w", k, err)
+		}
+	}
+	return data, nil
+}
+
+// DecryptSystemSecret gets the plaintext value of the component
+// system secret. Values that are not encrypted are returned as is.
+func DecryptSystemSecret(component, key, namespace string, clientset kubernetes.Interface) ([]byte, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[keyPrefix(component)+key]
+	if !ok {
+		return nil, fmt.Errorf("'%s%s' system secret not found", keyPrefix(component), key)
+	}
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	identities, err := GetToolchainIdentities(namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	return decryptValue(identities, value)
+}
+
+// EncryptSystemSecrets migrates the existing system secrets to
+// encrypted storage. Plaintext values are encrypted and encrypted
+// values are re-encrypted with the current toolchain key.
+func EncryptSystemSecrets(namespace string, clientset kubernetes.Interface) error {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	recipient, err := GetToolchainRecipient(namespace, clientset)
+	if err != nil {
+		return err
+	}
+	identities, err := GetToolchainIdentities(namespace, clientset)
+	if err != nil {
+		return err
+	}
+	// group the secrets by component so each component's keys are
+	// applied with its own field manager.
+	components := map[string]map[string][]byte{}
+	for k, v := range secret.Data {
+		parts := strings.SplitN(k, ".", 2)
+		if len(parts) != 2 {
+			return errors.New("system secret key is missing the component prefix")
+		}
+		if isEncrypted(v) {
+			if v, err = decryptValue(identities, v); err != nil {
+				return err
+			}
+		}
+		ciphertext, err := encryptValue(recipient, v)
+		if err != nil {
+			return err
+		}
+		if _, ok := components[parts[0]]; !ok {
+			components[parts[0]] = map[string][]byte{}
+		}
+		components[parts[0]][k] = ciphertext
+	}
+	for component, data := range components {
+		if err := applySystemSecrets(component, namespace, data, clientset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
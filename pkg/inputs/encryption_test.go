@@ -0,0 +1,135 @@
+package inputs
+
+import (
+	"context"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// createAgeKeySecret creates the toolchain age key secret.
+func createAgeKeySecret(t *testing.T, namespace string, clientset *fake.Clientset) *age.X25519Identity {
+	identity, err := inputstest.CreateToolchainKey(namespace, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestAddSystemSecretsWithEncryption(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	createAgeKeySecret(t, "test", clientset)
+	if err := AddSystemSecrets("test", "test", map[string][]byte{"password": []byte("password")}, clientset, WithEncryption()); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, isEncrypted(secret.Data["test.password"]), "expected an encrypted password value")
+	value, err := DecryptSystemSecret("test", "password", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "password", string(value), "got an unexpected decrypted password value")
+}
+
+func TestDecryptSystemSecretPlaintext(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	if err := AddSystemSecrets("test", "test", map[string][]byte{"password": []byte("password")}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	value, err := DecryptSystemSecret("test", "password", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "password", string(value), "got an unexpected password value")
+
+	if _, err := DecryptSystemSecret("test", "missing", "test", clientset); err == nil {
+		t.Fatal("expected a missing secret error")
+	}
+}
+
+func TestEncryptSystemSecrets(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	if err := AddSystemSecrets("test1", "test", map[string][]byte{"password": []byte("password1")}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	createAgeKeySecret(t, "test", clientset)
+	if err := AddSystemSecrets("test2", "test", map[string][]byte{"password": []byte("password2")}, clientset, WithEncryption()); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptSystemSecrets("test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for component, password := range map[string]string{"test1": "password1", "test2": "password2"} {
+		assert.True(t, isEncrypted(secret.Data[component+".password"]), "expected an encrypted password value")
+		value, err := DecryptSystemSecret(component, "password", "test", clientset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, password, string(value), "got an unexpected decrypted password value")
+	}
+}
+
+func TestAddSystemSecretsEncryptionEnv(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	createAgeKeySecret(t, "test", clientset)
+	t.Setenv(EncryptSystemSecretsEnv, "true")
+	if err := AddSystemSecrets("test", "test", map[string][]byte{"password": []byte("password")}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, isEncrypted(secret.Data["test.password"]), "expected the environment to turn on the encryption")
+}
+
+func TestGetSystemSecrets(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	if err := AddSystemSecrets("test1", "test", map[string][]byte{"password": []byte("password1")}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	// plaintext values do not need the toolchain key.
+	data, err := GetSystemSecrets("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string][]byte{"test1.password": []byte("password1")}, data)
+
+	createAgeKeySecret(t, "test", clientset)
+	if err := AddSystemSecrets("test2", "test", map[string][]byte{"password": []byte("password2")}, clientset, WithEncryption()); err != nil {
+		t.Fatal(err)
+	}
+	data, err = GetSystemSecrets("test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string][]byte{
+		"test1.password": []byte("password1"),
+		"test2.password": []byte("password2"),
+	}, data, "expected the plaintext system secrets")
+
+	// values encrypted for another key are an error.
+	if err := clientset.CoreV1().Secrets("test").Delete(context.TODO(), sopsAgeSecretName, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	createAgeKeySecret(t, "test", clientset)
+	if _, err := GetSystemSecrets("test", clientset); err == nil {
+		t.Fatal("expected a decryption error")
+	}
+}
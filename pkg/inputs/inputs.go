@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
-	corev1 "k8s.io/api/core/v1"
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -18,82 +21,190 @@ const (
 	systemVarsConfigMapName = "system-vars"
 	// systemSecretsSecretName is the name of the secrets secret.
 	systemSecretsSecretName = "system-secrets"
+	// EncryptSystemSecretsEnv is the hook and function environment
+	// variable that turns on the encrypted system secrets storage. It
+	// is set from the encryptSystemSecrets catalog parameter.
+	EncryptSystemSecretsEnv = "CATALOG_ENCRYPT_SYSTEM_SECRETS"
 )
 
+// hook permissions needed to manage the system inputs.
+var (
+	// AddSystemVarsRules are the permissions used by AddSystemVars.
+	AddSystemVarsRules = []hooks.Rule{{Resources: []string{"configmaps"}, Verbs: []string{"get", "create", "patch"}}}
+	// AddSystemSecretsRules are the permissions used by
+	// AddSystemSecrets.
+	AddSystemSecretsRules = []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get", "create", "patch"}}}
+	// RemoveSystemVarsRules are the permissions used by
+	// RemoveSystemVars.
+	RemoveSystemVarsRules = []hooks.Rule{{Resources: []string{"configmaps"}, Verbs: []string{"get", "patch"}}}
+	// RemoveSystemSecretsRules are the permissions used by
+	// RemoveSystemSecrets.
+	RemoveSystemSecretsRules = []hooks.Rule{{Resources: []string{"secrets"}, Verbs: []string{"get", "patch"}}}
+)
+
+// fieldManager returns the server-side apply field manager for the
+// component. Each component owns the keys under its own prefix so
+// concurrent hooks do not clobber each other's inputs.
+func fieldManager(component string) string {
+	return fmt.Sprintf("trustacks-%s", component)
+}
+
+// keyPrefix returns the system input key prefix for the component.
+func keyPrefix(component string) string {
+	return fmt.Sprintf("%s.", component)
+}
+
 // AddSystemVars adds the component variables to the system vars
-// config map.
+// config map. The apply is forced, so it takes over the component's
+// keys from any other writer and never conflicts, while the keys of
+// the other components are owned by their own field managers.
 func AddSystemVars(component, namespace string, vars map[string]string, clientset kubernetes.Interface) error {
 	client := clientset.CoreV1().ConfigMaps(namespace)
-	// Add the component prefix to the variables.
 	data := map[string]string{}
-	for k, v := range vars {
-		data[fmt.Sprintf("%s.%s", component, k)] = v
+	// keep the keys previously applied by the component, otherwise
+	// server-side apply would remove them from the config map.
+	configMap, err := client.Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
 	}
-	// Check if the config map exists and create if not.
-	if _, err := client.Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{}); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			configMap := &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: systemVarsConfigMapName,
-				},
-				Data: data,
-			}
-			if _, err := client.Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
-				return err
+	if err == nil {
+		for k, v := range configMap.Data {
+			if strings.HasPrefix(k, keyPrefix(component)) {
+				data[k] = v
 			}
-		} else {
-			return err
 		}
-		return nil
 	}
-	// patch the existing config map.
-	dataJSON, err := json.Marshal(data)
-	if err != nil {
-		return err
+	// Add the component prefix to the variables.
+	for k, v := range vars {
+		data[keyPrefix(component)+k] = v
 	}
-	patch := []byte(fmt.Sprintf(`{"data": %s}`, dataJSON))
-	_, err = client.Patch(context.TODO(), systemVarsConfigMapName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
-	if err != nil {
-		return err
+	apply := corev1apply.ConfigMap(systemVarsConfigMapName, namespace).WithData(data)
+	_, err = client.Apply(context.TODO(), apply, metav1.ApplyOptions{FieldManager: fieldManager(component), Force: true})
+	return err
+}
+
+// SystemSecretsOption configures how the system secrets are
+// stored.
+type SystemSecretsOption func(*systemSecretsOptions)
+
+// systemSecretsOptions contains the system secrets storage options.
+type systemSecretsOptions struct {
+	encrypt bool
+}
+
+// WithEncryption age encrypts the system secrets values with the
+// toolchain public key before they are stored.
+func WithEncryption() SystemSecretsOption {
+	return func(o *systemSecretsOptions) {
+		o.encrypt = true
 	}
-	return nil
 }
 
 // AddSystemSecrets adds the component secrets to the system secrets
-// secret.
-func AddSystemSecrets(component, namespace string, secrets map[string][]byte, clientset kubernetes.Interface) error {
-	client := clientset.CoreV1().Secrets(namespace)
-	// Add the component prefix to the variables.
+// secret. The values are encrypted when the encrypted storage is turned
+// on in the environment, or with the WithEncryption option.
+func AddSystemSecrets(component, namespace string, secrets map[string][]byte, clientset kubernetes.Interface, opts ...SystemSecretsOption) error {
+	options := &systemSecretsOptions{encrypt: os.Getenv(EncryptSystemSecretsEnv) == "true"}
+	for _, opt := range opts {
+		opt(options)
+	}
+	// Add the component prefix to the secrets.
 	data := map[string][]byte{}
 	for k, v := range secrets {
-		data[fmt.Sprintf("%s.%s", component, k)] = v
+		data[keyPrefix(component)+k] = v
+		logging.AddSecret(string(v))
 	}
-	// Check if the secret exists and create if not.
-	if _, err := client.Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{}); err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			secret := &corev1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: systemSecretsSecretName,
-				},
-				Data: data,
-			}
-			if _, err := client.Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+	if options.encrypt {
+		recipient, err := GetToolchainRecipient(namespace, clientset)
+		if err != nil {
+			return err
+		}
+		for k, v := range data {
+			ciphertext, err := encryptValue(recipient, v)
+			if err != nil {
 				return err
 			}
-		} else {
-			return err
+			data[k] = ciphertext
+		}
+	}
+	return applySystemSecrets(component, namespace, data, clientset)
+}
+
+// applySystemSecrets applies the prefixed component secrets to the
+// system secrets secret. Like AddSystemVars, the apply is forced.
+func applySystemSecrets(component, namespace string, secrets map[string][]byte, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().Secrets(namespace)
+	data := map[string][]byte{}
+	// keep the keys previously applied by the component, otherwise
+	// server-side apply would remove them from the secret.
+	secret, err := client.Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	if err == nil {
+		for k, v := range secret.Data {
+			if strings.HasPrefix(k, keyPrefix(component)) {
+				data[k] = v
+			}
+		}
+	}
+	for k, v := range secrets {
+		data[k] = v
+	}
+	apply := corev1apply.Secret(systemSecretsSecretName, namespace).WithData(data)
+	_, err = client.Apply(context.TODO(), apply, metav1.ApplyOptions{FieldManager: fieldManager(component), Force: true})
+	return err
+}
+
+// removePatch creates a merge patch that removes the component's
+// keys from the data.
+func removePatch[T any](component string, data map[string]T) ([]byte, error) {
+	remove := map[string]interface{}{}
+	for k := range data {
+		if strings.HasPrefix(k, keyPrefix(component)) {
+			remove[k] = nil
 		}
-		return nil
 	}
-	// patch the existing secret.
-	dataJSON, err := json.Marshal(data)
+	if len(remove) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(map[string]interface{}{"data": remove})
+}
+
+// RemoveSystemVars removes the component variables from the system
+// vars config map.
+func RemoveSystemVars(component, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().ConfigMaps(namespace)
+	configMap, err := client.Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{})
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+	patch, err := removePatch(component, configMap.Data)
+	if err != nil || patch == nil {
 		return err
 	}
-	patch := []byte(fmt.Sprintf(`{"data": %s}`, dataJSON))
-	_, err = client.Patch(context.TODO(), systemSecretsSecretName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	_, err = client.Patch(context.TODO(), systemVarsConfigMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// RemoveSystemSecrets removes the component secrets from the system
+// secrets secret.
+func RemoveSystemSecrets(component, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().Secrets(namespace)
+	secret, err := client.Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+	patch, err := removePatch(component, secret.Data)
+	if err != nil || patch == nil {
 		return err
 	}
-	return nil
+	_, err = client.Patch(context.TODO(), systemSecretsSecretName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
 }
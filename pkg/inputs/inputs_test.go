@@ -2,15 +2,19 @@ package inputs
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/inputs/inputstest"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestAddSystemVars(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
 	if err := AddSystemVars("test", "test", map[string]string{"name": "joe"}, clientset); err != nil {
 		t.Fatal(err)
 	}
@@ -27,6 +31,7 @@ func TestAddSystemVars(t *testing.T) {
 
 func TestAddSystemSecrets(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
 	if err := AddSystemSecrets("test", "test", map[string][]byte{"username": []byte("joe")}, clientset); err != nil {
 		t.Fatal(err)
 	}
@@ -40,3 +45,129 @@ func TestAddSystemSecrets(t *testing.T) {
 	assert.Equal(t, "joe", string(secret.Data["test.username"]), "got an unexpected username variable value")
 	assert.Equal(t, "password", string(secret.Data["test.password"]), "got an unexpected password variable value")
 }
+
+func TestAddSystemInputsConcurrently(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	components := []string{"authentik", "argo-cd", "concourse", "test1", "test2", "test3"}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(components)*2)
+	for _, component := range components {
+		wg.Add(1)
+		go func(component string) {
+			defer wg.Done()
+			errs <- AddSystemVars(component, "test", map[string]string{"server": component}, clientset)
+			errs <- AddSystemSecrets(component, "test", map[string][]byte{"password": []byte(component)}, clientset)
+		}(component)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cm, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range components {
+		assert.Equal(t, component, cm.Data[fmt.Sprintf("%s.server", component)], "got an unexpected server variable value")
+		assert.Equal(t, component, string(secret.Data[fmt.Sprintf("%s.password", component)]), "got an unexpected password secret value")
+	}
+}
+
+func TestAddSystemVarsOwnership(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	if err := AddSystemVars("test1", "test", map[string]string{"name": "joe"}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSystemVars("test2", "test", map[string]string{"name": "jane"}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSystemVars("test1", "test", map[string]string{"name": "jim"}, clientset); err != nil {
+		t.Fatal(err)
+	}
+	cm, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "jim", cm.Data["test1.name"], "got an unexpected name variable value")
+	assert.Equal(t, "jane", cm.Data["test2.name"], "expected the other component's variable to be untouched")
+}
+
+func TestAddSystemInputsManagers(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	// each component applies its inputs in turn, so every apply follows
+	// the other component's apply.
+	for i := 0; i < 2; i++ {
+		for _, component := range []string{"test1", "test2"} {
+			value := fmt.Sprintf("%s-%d", component, i)
+			if err := AddSystemVars(component, "test", map[string]string{fmt.Sprintf("var%d", i): value}, clientset); err != nil {
+				t.Fatal(err)
+			}
+			if err := AddSystemSecrets(component, "test", map[string][]byte{fmt.Sprintf("secret%d", i): []byte(value)}, clientset); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cm, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range []string{"test1", "test2"} {
+		for i := 0; i < 2; i++ {
+			value := fmt.Sprintf("%s-%d", component, i)
+			assert.Equal(t, value, cm.Data[fmt.Sprintf("%s.var%d", component, i)], "expected the variable to survive the other component's apply")
+			assert.Equal(t, value, string(secret.Data[fmt.Sprintf("%s.secret%d", component, i)]), "expected the secret to survive the other component's apply")
+		}
+	}
+}
+
+func TestRemoveSystemInputs(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	inputstest.AddApplyReactor(clientset)
+	// removing from missing inputs is a no-op.
+	if err := RemoveSystemVars("test1", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSystemSecrets("test1", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range []string{"test1", "test2"} {
+		if err := AddSystemVars(component, "test", map[string]string{"name": "joe"}, clientset); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddSystemSecrets(component, "test", map[string][]byte{"password": []byte("password")}, clientset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RemoveSystemVars("test1", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSystemSecrets("test1", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	cm, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), systemVarsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), systemSecretsSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, cm.Data, "test1.name", "expected the variable to be removed")
+	assert.NotContains(t, secret.Data, "test1.password", "expected the secret to be removed")
+	assert.Equal(t, "joe", cm.Data["test2.name"], "expected the other component's variable to be kept")
+	assert.Equal(t, "password", string(secret.Data["test2.password"]), "expected the other component's secret to be kept")
+}
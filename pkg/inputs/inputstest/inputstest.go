@@ -0,0 +1,119 @@
+// Package inputstest contains the fake clientset helpers of the system
+// inputs tests. It is only imported by tests.
+package inputstest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeClientset is the subset of the fake clientset used by the
+// apply reactor.
+type fakeClientset interface {
+	PrependReactor(verb, resource string, reaction k8stesting.ReactionFunc)
+	Tracker() k8stesting.ObjectTracker
+}
+
+// AddApplyReactor patches the fake clientset with a reactor that
+// emulates server-side apply for the system inputs. The fake object
+// tracker does not support apply patches, so the reactor merges the
+// applied data and, like a per-component field manager, drops the
+// component's keys that are no longer applied.
+func AddApplyReactor(clientset fakeClientset) {
+	tracker := clientset.Tracker()
+	reactor := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		gvr := action.GetResource()
+		ns := action.GetNamespace()
+		switch gvr.Resource {
+		case "configmaps":
+			applied := &corev1.ConfigMap{}
+			if err := json.Unmarshal(patch.GetPatch(), applied); err != nil {
+				return true, nil, err
+			}
+			obj, err := tracker.Get(gvr, ns, patch.GetName())
+			if err != nil {
+				if !strings.Contains(err.Error(), "not found") {
+					return true, nil, err
+				}
+				return true, applied, tracker.Create(gvr, applied, ns)
+			}
+			configMap := obj.(*corev1.ConfigMap)
+			configMap.Data = mergeAppliedKeys(configMap.Data, applied.Data)
+			return true, configMap, tracker.Update(gvr, configMap, ns)
+		case "secrets":
+			applied := &corev1.Secret{}
+			if err := json.Unmarshal(patch.GetPatch(), applied); err != nil {
+				return true, nil, err
+			}
+			obj, err := tracker.Get(gvr, ns, patch.GetName())
+			if err != nil {
+				if !strings.Contains(err.Error(), "not found") {
+					return true, nil, err
+				}
+				return true, applied, tracker.Create(gvr, applied, ns)
+			}
+			secret := obj.(*corev1.Secret)
+			secret.Data = mergeAppliedKeys(secret.Data, applied.Data)
+			return true, secret, tracker.Update(gvr, secret, ns)
+		}
+		return false, nil, nil
+	}
+	clientset.PrependReactor("patch", "configmaps", reactor)
+	clientset.PrependReactor("patch", "secrets", reactor)
+}
+
+// mergeAppliedKeys merges the applied keys into the current keys.
+// Current keys sharing a component prefix with the applied keys are
+// owned by the applier and are removed if absent from the apply.
+func mergeAppliedKeys[T any](current, applied map[string]T) map[string]T {
+	owners := map[string]bool{}
+	for k := range applied {
+		owners[strings.SplitN(k, ".", 2)[0]] = true
+	}
+	merged := map[string]T{}
+	for k, v := range current {
+		if !owners[strings.SplitN(k, ".", 2)[0]] {
+			merged[k] = v
+		}
+	}
+	for k, v := range applied {
+		merged[k] = v
+	}
+	return merged
+}
+
+// CreateToolchainKey generates a toolchain age key and creates the age
+// key secret in the namespace. The private key has the header written
+// by age-keygen.
+func CreateToolchainKey(namespace string, clientset kubernetes.Interface) (*age.X25519Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "sops-age",
+		},
+		Data: map[string][]byte{
+			"age.agepub": []byte(identity.Recipient().String()),
+			"age.agekey": []byte("# created: 2022-08-01T00:00:00Z\n" + identity.String() + "\n"),
+		},
+	}
+	if _, err := clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
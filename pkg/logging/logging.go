@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the log severity level.
+type Level int
+
+// log levels.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// redacted replaces the secret values in the log lines.
+const redacted = "[REDACTED]"
+
+// levelNames are the log level names.
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// sensitiveFields are the field name fragments of values that are
+// always redacted.
+var sensitiveFields = []string{"password", "secret", "token", "credential", "privatekey"}
+
+// String returns the level name.
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel parses the log level name.
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("'%s' is not a valid log level", name)
+}
+
+// logger writes json log lines with the context fields.
+type logger struct {
+	mu      sync.Mutex
+	out     io.Writer
+	level   Level
+	fields  map[string]interface{}
+	secrets []string
+	exit    func(int)
+}
+
+// std is the process logger.
+var std = &logger{
+	out:    os.Stderr,
+	level:  LevelInfo,
+	fields: map[string]interface{}{},
+	exit:   os.Exit,
+}
+
+// SetLevel sets the minimum level of the logged lines.
+func SetLevel(level Level) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.level = level
+}
+
+// SetOutput sets the log line writer.
+func SetOutput(w io.Writer) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.out = w
+}
+
+// SetField sets a context field included in every log line. Empty
+// values remove the field.
+func SetField(key string, value interface{}) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	if value == "" || value == nil {
+		delete(std.fields, key)
+		return
+	}
+	std.fields[key] = value
+}
+
+// AddSecret registers the secret value so it is redacted wherever it
+// appears in the log lines.
+func AddSecret(value string) {
+	if value == "" {
+		return
+	}
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.secrets = append(std.secrets, value)
+}
+
+// redact replaces the registered secret values in the string.
+func (l *logger) redact(s string) string {
+	for _, secret := range l.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// isSensitive checks if the field contains a secret value.
+func isSensitive(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	for _, fragment := range sensitiveFields {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// value converts the field value to its logged representation.
+func (l *logger) value(key string, v interface{}) interface{} {
+	if isSensitive(key) {
+		return redacted
+	}
+	switch v := v.(type) {
+	case error:
+		return l.redact(v.Error())
+	case string:
+		return l.redact(v)
+	case fmt.Stringer:
+		return l.redact(v.String())
+	}
+	return v
+}
+
+// log writes the log line if the level is enabled. The key values
+// are alternating field names and values.
+func (l *logger) log(level Level, msg string, kv ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+	line := map[string]interface{}{}
+	for k, v := range l.fields {
+		line[k] = l.value(k, v)
+	}
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		if i+1 == len(kv) {
+			line[key] = nil
+			break
+		}
+		line[key] = l.value(key, kv[i+1])
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = level.String()
+	line["msg"] = l.redact(msg)
+	data, err := json.Marshal(line)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"level": LevelError.String(), "msg": "error encoding the log line: " + err.Error()})
+	}
+	l.out.Write(append(data, '\n'))
+}
+
+// Debug logs the message at the debug level.
+func Debug(msg string, kv ...interface{}) {
+	std.log(LevelDebug, msg, kv...)
+}
+
+// Info logs the message at the info level.
+func Info(msg string, kv ...interface{}) {
+	std.log(LevelInfo, msg, kv...)
+}
+
+// Warn logs the message at the warn level.
+func Warn(msg string, kv ...interface{}) {
+	std.log(LevelWarn, msg, kv...)
+}
+
+// Error logs the message at the error level.
+func Error(msg string, kv ...interface{}) {
+	std.log(LevelError, msg, kv...)
+}
+
+// Fatal logs the message at the error level and exits the process.
+func Fatal(msg string, kv ...interface{}) {
+	std.log(LevelError, msg, kv...)
+	std.exit(1)
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogs redirects the log lines to a buffer and resets the
+// logger state after the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	std = &logger{out: &buf, level: LevelInfo, fields: map[string]interface{}{}, exit: os.Exit}
+	t.Cleanup(func() {
+		std = &logger{out: os.Stderr, level: LevelInfo, fields: map[string]interface{}{}, exit: os.Exit}
+	})
+	return &buf
+}
+
+// decodeLines decodes the json log lines.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	lines := []map[string]interface{}{}
+	for _, data := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if data == "" {
+			continue
+		}
+		line := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(data), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLogFields(t *testing.T) {
+	buf := captureLogs(t)
+	SetField("component", "test")
+	SetField("hook", "pre-install")
+	SetField("function", "")
+	Info("create user groups", "count", 2, "error", errors.New("failed"))
+	lines := decodeLines(t, buf)
+	assert.Len(t, lines, 1, "expected a single log line")
+	assert.Equal(t, "info", lines[0]["level"], "got an unexpected level")
+	assert.Equal(t, "create user groups", lines[0]["msg"], "got an unexpected message")
+	assert.Equal(t, "test", lines[0]["component"], "got an unexpected component field")
+	assert.Equal(t, "pre-install", lines[0]["hook"], "got an unexpected hook field")
+	assert.Equal(t, float64(2), lines[0]["count"], "got an unexpected count field")
+	assert.Equal(t, "failed", lines[0]["error"], "got an unexpected error field")
+	assert.NotContains(t, lines[0], "function", "expected the empty field to be omitted")
+}
+
+func TestLogLevel(t *testing.T) {
+	buf := captureLogs(t)
+	Debug("debug")
+	SetLevel(LevelWarn)
+	Info("info")
+	Warn("warn")
+	Error("error")
+	lines := decodeLines(t, buf)
+	assert.Len(t, lines, 2, "expected only the warn and error lines")
+	assert.Equal(t, "warn", lines[0]["level"], "got an unexpected level")
+
+	level, err := ParseLevel("DEBUG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LevelDebug, level, "got an unexpected level")
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("expected an invalid level error")
+	}
+}
+
+func TestLogRedaction(t *testing.T) {
+	buf := captureLogs(t)
+	AddSecret("s3cr3t")
+	Info("token s3cr3t created", "adminPassword", "plain", "error", errors.New("invalid key s3cr3t"))
+	lines := decodeLines(t, buf)
+	assert.Equal(t, "token [REDACTED] created", lines[0]["msg"], "expected the secret to be redacted from the message")
+	assert.Equal(t, "[REDACTED]", lines[0]["adminPassword"], "expected the sensitive field to be redacted")
+	assert.Equal(t, "invalid key [REDACTED]", lines[0]["error"], "expected the secret to be redacted from the error")
+	assert.NotContains(t, buf.String(), "s3cr3t", "expected no secret values in the log lines")
+}
+
+func TestFatal(t *testing.T) {
+	buf := captureLogs(t)
+	code := 0
+	std.exit = func(c int) { code = c }
+	Fatal("failed")
+	assert.Equal(t, 1, code, "expected a non-zero exit code")
+	assert.Equal(t, "error", decodeLines(t, buf)[0]["level"], "got an unexpected level")
+}
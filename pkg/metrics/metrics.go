@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace is the metric name prefix.
+const namespace = "catalog"
+
+// serverRegistry contains the catalog server metrics.
+var serverRegistry = prometheus.NewRegistry()
+
+var (
+	// requestsTotal counts the server requests per route and status
+	// code.
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of catalog server requests.",
+	}, []string{"route", "code"})
+	// requestDuration observes the server request latencies per
+	// route.
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the catalog server requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+	// manifestSize is the size of the catalog manifest.
+	manifestSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "manifest_size_bytes",
+		Help:      "Size of the catalog manifest.",
+	})
+	// buildInfo contains the catalog component chart versions.
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Catalog components and chart versions.",
+	}, []string{"component", "chart", "version"})
+)
+
+// StatusRecorder records the response status code.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Code int
+}
+
+// NewStatusRecorder creates a status recorder of the response writer.
+// The status code defaults to ok when the handler does not write a
+// header.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Code: http.StatusOK}
+}
+
+// WriteHeader records the status code and writes the header.
+func (r *StatusRecorder) WriteHeader(code int) {
+	r.Code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// InstrumentHandler counts and times the route requests.
+func InstrumentHandler(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := NewStatusRecorder(w)
+		handler(recorder, r)
+		requestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		requestsTotal.WithLabelValues(route, strconv.Itoa(recorder.Code)).Inc()
+	}
+}
+
+// SetManifestSize sets the size of the catalog manifest.
+func SetManifestSize(size int) {
+	manifestSize.Set(float64(size))
+}
+
+// SetBuildInfo sets the catalog component chart versions.
+func SetBuildInfo(component, chart, version string) {
+	buildInfo.WithLabelValues(component, chart, version).Set(1)
+}
+
+// Handler returns the server metrics handler.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(serverRegistry, promhttp.HandlerOpts{})
+}
+
+func init() {
+	serverRegistry.MustRegister(requestsTotal, requestDuration, manifestSize, buildInfo)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	requestsTotal.Reset()
+	requestDuration.Reset()
+	handler := InstrumentHandler("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "https://test.com/test", nil))
+	SetManifestSize(42)
+	SetBuildInfo("test", "test/test", "1.0.0")
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "https://test.com/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	for _, metric := range []string{
+		`catalog_http_requests_total{code="404",route="/test"} 1`,
+		`catalog_http_request_duration_seconds_count{route="/test"} 1`,
+		`catalog_manifest_size_bytes 42`,
+		`catalog_build_info{chart="test/test",component="test",version="1.0.0"} 1`,
+	} {
+		assert.Contains(t, string(body), metric, "expected the metric to be exposed")
+	}
+}
+
+func TestRunPush(t *testing.T) {
+	var path, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	run := StartRun("catalog-hook", map[string]string{"component": "test", "hook": "pre-install"})
+	Step("first")
+	Step("second")
+	if err := run.Push(ts.URL, errors.New("failed")); err != nil {
+		t.Fatal(err)
+	}
+	// the grouping labels are a set, so their path order may vary.
+	assert.True(t, strings.HasPrefix(path, "/metrics/job/catalog-hook/"), "got an unexpected pushgateway job")
+	assert.Contains(t, path, "/component/test", "expected the component grouping label")
+	assert.Contains(t, path, "/hook/pre-install", "expected the hook grouping label")
+	for _, metric := range []string{
+		"catalog_run_duration_seconds",
+		"catalog_run_success",
+		"catalog_run_last_completion_timestamp_seconds",
+		"catalog_run_step_duration_seconds",
+		"first",
+		"second",
+	} {
+		assert.True(t, strings.Contains(body, metric), "expected the %s metric to be pushed", metric)
+	}
+}
+
+func TestStepWithoutRun(t *testing.T) {
+	activeRun = nil
+	Step("test")
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// activeRun is the hook or function run of the process.
+var activeRun *Run
+
+// activeRunMu guards the active run.
+var activeRunMu sync.Mutex
+
+// Run records the duration, outcome and step timings of a hook or
+// function run.
+type Run struct {
+	job       string
+	grouping  map[string]string
+	start     time.Time
+	step      string
+	stepStart time.Time
+	steps     map[string]time.Duration
+}
+
+// StartRun starts recording the process hook or function run. The
+// grouping labels identify the run in the pushgateway.
+func StartRun(job string, grouping map[string]string) *Run {
+	activeRunMu.Lock()
+	defer activeRunMu.Unlock()
+	activeRun = &Run{
+		job:      job,
+		grouping: grouping,
+		start:    time.Now(),
+		steps:    map[string]time.Duration{},
+	}
+	return activeRun
+}
+
+// Step ends the current step of the active run and starts timing the
+// named step. It does nothing if no run was started.
+func Step(name string) {
+	activeRunMu.Lock()
+	defer activeRunMu.Unlock()
+	if activeRun == nil {
+		return
+	}
+	activeRun.endStep()
+	activeRun.step, activeRun.stepStart = name, time.Now()
+}
+
+// endStep records the duration of the current step.
+func (r *Run) endStep() {
+	if r.step != "" {
+		r.steps[r.step] += time.Since(r.stepStart)
+		r.step = ""
+	}
+}
+
+// collectors creates the run metrics for the run outcome.
+func (r *Run) collectors(runErr error) []prometheus.Collector {
+	activeRunMu.Lock()
+	defer activeRunMu.Unlock()
+	r.endStep()
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the hook or function run.",
+	})
+	duration.Set(time.Since(r.start).Seconds())
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_success",
+		Help:      "Whether the hook or function run succeeded.",
+	})
+	if runErr == nil {
+		success.Set(1)
+	}
+	completion := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_last_completion_timestamp_seconds",
+		Help:      "Completion time of the hook or function run.",
+	})
+	completion.SetToCurrentTime()
+	steps := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "run_step_duration_seconds",
+		Help:      "Duration of the hook or function run steps.",
+	}, []string{"step"})
+	for name, d := range r.steps {
+		steps.WithLabelValues(name).Set(d.Seconds())
+	}
+	return []prometheus.Collector{duration, success, completion, steps}
+}
+
+// Push pushes the run metrics to the pushgateway compatible
+// endpoint. The run error is recorded as the run outcome.
+func (r *Run) Push(url string, runErr error) error {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(r.collectors(runErr)...)
+	pusher := push.New(url, r.job).Gatherer(registry)
+	for k, v := range r.grouping {
+		pusher = pusher.Grouping(k, v)
+	}
+	return pusher.Push()
+}
@@ -0,0 +1,243 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// migrationsConfigMapName is the name of the config map where the
+// applied migrations are recorded.
+const migrationsConfigMapName = "catalog-migrations"
+
+// Rules are the hook permissions used to record and run the
+// migrations.
+var Rules = []hooks.Rule{{Resources: []string{"configmaps"}, Verbs: []string{"get", "create", "update"}}}
+
+// Migration is a change applied when a component is upgraded from
+// one chart version to another.
+type Migration struct {
+	// From is the chart version the migration upgrades from.
+	From string
+	// To is the chart version the migration upgrades to.
+	To string
+	// Description describes the migration.
+	Description string
+	// Run applies the migration in the component namespace.
+	Run func(namespace string, clientset kubernetes.Interface) error
+}
+
+// Name returns the migration name.
+func (m Migration) Name() string {
+	return fmt.Sprintf("%s-%s", m.From, m.To)
+}
+
+// componentMigrations contains the component chart version and its
+// registered migrations.
+type componentMigrations struct {
+	version    string
+	migrations []Migration
+}
+
+// registry contains the registered component migrations.
+var registry = map[string]*componentMigrations{}
+
+// Register adds the migrations for the component at the provided
+// chart version.
+func Register(component, version string, migrations ...Migration) {
+	registry[component] = &componentMigrations{version, migrations}
+}
+
+// appliedMigration is a migration applied to a component.
+type appliedMigration struct {
+	Name      string `json:"name"`
+	AppliedAt string `json:"appliedAt"`
+}
+
+// record contains the migration state of a component.
+type record struct {
+	Version string             `json:"version"`
+	Applied []appliedMigration `json:"applied"`
+}
+
+// isApplied checks if the migration was already applied.
+func (r *record) isApplied(name string) bool {
+	for _, m := range r.Applied {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getRecord gets the migration record of the component.
+func getRecord(component, namespace string, clientset kubernetes.Interface) (*record, error) {
+	r := &record{}
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), migrationsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return r, nil
+		}
+		return nil, err
+	}
+	if data, ok := configMap.Data[component]; ok {
+		if err := json.Unmarshal([]byte(data), r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// saveRecord saves the migration record of the component.
+func saveRecord(component, namespace string, r *record, clientset kubernetes.Interface) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	client := clientset.CoreV1().ConfigMaps(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := client.Get(context.TODO(), migrationsConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			if !strings.Contains(err.Error(), "not found") {
+				return err
+			}
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: migrationsConfigMapName,
+				},
+				Data: map[string]string{component: string(data)},
+			}
+			_, err = client.Create(context.TODO(), configMap, metav1.CreateOptions{})
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[component] = string(data)
+		_, err = client.Update(context.TODO(), configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// compareVersions compares the chart versions.
+func compareVersions(a, b string) (int, error) {
+	v, err := version.ParseGeneric(a)
+	if err != nil {
+		return 0, err
+	}
+	return v.Compare(b)
+}
+
+// SetVersion records the installed chart version of the component.
+// Migrations from earlier chart versions are not run on upgrade.
+func SetVersion(component, namespace string, clientset kubernetes.Interface) error {
+	c, ok := registry[component]
+	if !ok {
+		return fmt.Errorf("'%s' migrations are not registered", component)
+	}
+	r, err := getRecord(component, namespace, clientset)
+	if err != nil {
+		return err
+	}
+	r.Version = c.version
+	return saveRecord(component, namespace, r, clientset)
+}
+
+// Pending gets the migrations of the component that have not been
+// applied, ordered by chart version.
+func Pending(component, namespace string, clientset kubernetes.Interface) ([]Migration, error) {
+	c, ok := registry[component]
+	if !ok {
+		return nil, fmt.Errorf("'%s' migrations are not registered", component)
+	}
+	r, err := getRecord(component, namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	pending := []Migration{}
+	for _, m := range c.migrations {
+		if r.isApplied(m.Name()) {
+			continue
+		}
+		// skip the migrations to a later chart version.
+		cmp, err := compareVersions(m.To, c.version)
+		if err != nil {
+			return nil, err
+		}
+		if cmp > 0 {
+			continue
+		}
+		// skip the migrations from a chart version prior to the
+		// installed version.
+		if r.Version != "" {
+			cmp, err := compareVersions(m.From, r.Version)
+			if err != nil {
+				return nil, err
+			}
+			if cmp < 0 {
+				continue
+			}
+		}
+		pending = append(pending, m)
+	}
+	var sortErr error
+	sort.SliceStable(pending, func(i, j int) bool {
+		cmp, err := compareVersions(pending[i].From, pending[j].From)
+		if err != nil {
+			sortErr = err
+		}
+		if cmp == 0 {
+			cmp, err = compareVersions(pending[i].To, pending[j].To)
+			if err != nil {
+				sortErr = err
+			}
+		}
+		return cmp < 0
+	})
+	return pending, sortErr
+}
+
+// Run applies the pending migrations of the component in order and
+// records the upgraded chart version. In dry run mode the pending
+// migrations are only listed.
+func Run(component, namespace string, dryRun bool, clientset kubernetes.Interface) ([]Migration, error) {
+	pending, err := Pending(component, namespace, clientset)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range pending {
+		if dryRun {
+			logging.Info("pending migration", "migration", m.Name(), "description", m.Description)
+			continue
+		}
+		logging.Info("run migration", "migration", m.Name(), "description", m.Description)
+		if err := m.Run(namespace, clientset); err != nil {
+			return nil, fmt.Errorf("migration %s failed: %s", m.Name(), err)
+		}
+		// record each migration as it is applied so a failed upgrade
+		// resumes at the failed migration.
+		r, err := getRecord(component, namespace, clientset)
+		if err != nil {
+			return nil, err
+		}
+		r.Applied = append(r.Applied, appliedMigration{m.Name(), time.Now().UTC().Format(time.RFC3339)})
+		if err := saveRecord(component, namespace, r, clientset); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+	return pending, SetVersion(component, namespace, clientset)
+}
@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// patchRegistry mock patches the migrations registry.
+func patchRegistry() func() {
+	previousRegistry := registry
+	registry = map[string]*componentMigrations{}
+	return func() {
+		registry = previousRegistry
+	}
+}
+
+// mockMigration creates a migration that records its calls.
+func mockMigration(from, to string, calls *[]string) Migration {
+	return Migration{
+		From:        from,
+		To:          to,
+		Description: "test migration",
+		Run: func(_ string, _ kubernetes.Interface) error {
+			*calls = append(*calls, from+"-"+to)
+			return nil
+		},
+	}
+}
+
+func TestPending(t *testing.T) {
+	defer patchRegistry()()
+	calls := []string{}
+	Register("test", "1.2.0",
+		mockMigration("1.1.0", "1.2.0", &calls),
+		mockMigration("1.0.0", "1.1.0", &calls),
+		mockMigration("1.2.0", "1.3.0", &calls),
+	)
+	clientset := fake.NewSimpleClientset()
+	pending, err := Pending("test", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, m := range pending {
+		names = append(names, m.Name())
+	}
+	assert.Equal(t, []string{"1.0.0-1.1.0", "1.1.0-1.2.0"}, names, "got unexpected pending migrations")
+
+	if _, err := Pending("missing", "test", clientset); err == nil {
+		t.Fatal("expected an unregistered component error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	defer patchRegistry()()
+	calls := []string{}
+	Register("test", "1.1.0", mockMigration("1.0.0", "1.1.0", &calls))
+	clientset := fake.NewSimpleClientset()
+
+	// dry run only lists the migrations.
+	pending, err := Run("test", "test", true, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, pending, 1, "expected a pending migration")
+	assert.Empty(t, calls, "expected no migration to run")
+
+	if _, err := Run("test", "test", false, clientset); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"1.0.0-1.1.0"}, calls, "expected the migration to run")
+
+	// applied migrations are not run again.
+	pending, err = Run("test", "test", false, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, pending, "expected no pending migrations")
+	assert.Len(t, calls, 1, "expected the migration to run once")
+
+	r, err := getRecord("test", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "1.1.0", r.Version, "got an unexpected recorded version")
+	assert.True(t, r.isApplied("1.0.0-1.1.0"), "expected the migration to be recorded")
+}
+
+func TestRunFailure(t *testing.T) {
+	defer patchRegistry()()
+	calls := []string{}
+	Register("test", "1.2.0", mockMigration("1.0.0", "1.1.0", &calls), Migration{
+		From: "1.1.0",
+		To:   "1.2.0",
+		Run: func(_ string, _ kubernetes.Interface) error {
+			return errors.New("failed")
+		},
+	})
+	clientset := fake.NewSimpleClientset()
+	if _, err := Run("test", "test", false, clientset); err == nil {
+		t.Fatal("expected a migration error")
+	}
+	r, err := getRecord("test", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, r.isApplied("1.0.0-1.1.0"), "expected the successful migration to be recorded")
+	assert.False(t, r.isApplied("1.1.0-1.2.0"), "expected the failed migration to not be recorded")
+	assert.Empty(t, r.Version, "expected the version to not be recorded")
+}
+
+func TestSetVersion(t *testing.T) {
+	defer patchRegistry()()
+	calls := []string{}
+	Register("test", "1.1.0", mockMigration("1.0.0", "1.1.0", &calls))
+	clientset := fake.NewSimpleClientset()
+	if err := SetVersion("test", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	configMap, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), migrationsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, configMap.Data["test"], `"version":"1.1.0"`, "got an unexpected migration record")
+
+	// migrations from versions prior to the installed version are
+	// not pending.
+	Register("test", "1.2.0", mockMigration("1.0.0", "1.1.0", &calls), mockMigration("1.1.0", "1.2.0", &calls))
+	pending, err := Pending("test", "test", clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, pending, 1, "expected a pending migration")
+	assert.Equal(t, "1.1.0-1.2.0", pending[0].Name(), "got an unexpected pending migration")
+}
@@ -0,0 +1,351 @@
+package rbac
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Access is a kubernetes api request made by a hook or function.
+type Access struct {
+	Namespace string
+	APIGroup  string
+	Resource  string
+	Verb      string
+	Name      string
+}
+
+// String returns the access in the "verb group/resource/name in
+// namespace" form.
+func (a Access) String() string {
+	return fmt.Sprintf("%s %s in %q", a.Verb, resourcePath(a.APIGroup, a.Resource, a.Name), a.Namespace)
+}
+
+// Grant is a permission granted to a service account. An empty
+// namespace is granted in every namespace and an empty name grants
+// every object of the resource.
+type Grant struct {
+	Namespace string
+	APIGroup  string
+	Resource  string
+	Verb      string
+	Name      string
+}
+
+// String returns the grant in the "verb group/resource/name in
+// namespace" form.
+func (g Grant) String() string {
+	namespace := fmt.Sprintf("%q", g.Namespace)
+	if g.Namespace == "" {
+		namespace = "all namespaces"
+	}
+	name := g.Name
+	if name == "" {
+		name = "*"
+	}
+	return fmt.Sprintf("%s %s in %s", g.Verb, resourcePath(g.APIGroup, g.Resource, name), namespace)
+}
+
+// resourcePath returns the group/resource/name path. The core api
+// group is omitted.
+func resourcePath(group, resource, name string) string {
+	path := resource
+	if group != "" {
+		path = group + "/" + path
+	}
+	if name != "" {
+		path += "/" + name
+	}
+	return path
+}
+
+// Accesses converts the actions recorded by a fake clientset into
+// api accesses. Duplicate accesses are removed.
+func Accesses(actions []k8stesting.Action) []Access {
+	accesses := []Access{}
+	seen := map[Access]bool{}
+	for _, action := range actions {
+		gvr := action.GetResource()
+		access := Access{
+			Namespace: action.GetNamespace(),
+			APIGroup:  gvr.Group,
+			Resource:  gvr.Resource,
+			Verb:      action.GetVerb(),
+		}
+		if sub := action.GetSubresource(); sub != "" {
+			access.Resource += "/" + sub
+		}
+		switch a := action.(type) {
+		case k8stesting.GetAction:
+			access.Name = a.GetName()
+		case k8stesting.DeleteAction:
+			access.Name = a.GetName()
+		case k8stesting.PatchAction:
+			access.Name = a.GetName()
+		case k8stesting.CreateAction:
+			if obj, err := meta.Accessor(a.GetObject()); err == nil {
+				access.Name = obj.GetName()
+			}
+		case k8stesting.UpdateAction:
+			if obj, err := meta.Accessor(a.GetObject()); err == nil {
+				access.Name = obj.GetName()
+			}
+		}
+		if seen[access] {
+			continue
+		}
+		seen[access] = true
+		accesses = append(accesses, access)
+	}
+	return accesses
+}
+
+// RenderManifests renders the catalog templates of the manifests.
+// Helm templates escaped from the catalog rendering are left in the
+// output. The sprig functions are available as they are to the
+// toolchain.
+func RenderManifests(manifests string, params map[string]interface{}) ([]byte, error) {
+	tmpl, err := template.New("manifests").Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(manifests)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// object contains the fields of the rbac objects and jobs used to
+// resolve the grants of a job.
+type object struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Rules []struct {
+		APIGroups     []string `yaml:"apiGroups"`
+		Resources     []string `yaml:"resources"`
+		Verbs         []string `yaml:"verbs"`
+		ResourceNames []string `yaml:"resourceNames"`
+	} `yaml:"rules"`
+	Subjects []struct {
+		Kind      string `yaml:"kind"`
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"subjects"`
+	RoleRef struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"roleRef"`
+	Spec struct {
+		Template struct {
+			Spec struct {
+				ServiceAccount     string `yaml:"serviceAccount"`
+				ServiceAccountName string `yaml:"serviceAccountName"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// decodeObjects decodes the manifest documents. Objects without a
+// namespace are placed in the release namespace.
+func decodeObjects(manifests []byte, namespace string) ([]*object, error) {
+	objects := []*object{}
+	decoder := yaml.NewDecoder(bytes.NewReader(manifests))
+	for {
+		o := &object{}
+		if err := decoder.Decode(o); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if o.Kind == "" {
+			continue
+		}
+		if o.Metadata.Namespace == "" {
+			o.Metadata.Namespace = namespace
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// Grants returns the permissions granted to the service account of
+// the job in the rendered manifests. The job and the objects without
+// a namespace are in the release namespace.
+func Grants(manifests []byte, namespace, job string) ([]Grant, error) {
+	objects, err := decodeObjects(manifests, namespace)
+	if err != nil {
+		return nil, err
+	}
+	serviceAccount := ""
+	for _, o := range objects {
+		if o.Kind == "Job" && o.Metadata.Name == job {
+			serviceAccount = o.Spec.Template.Spec.ServiceAccountName
+			if serviceAccount == "" {
+				serviceAccount = o.Spec.Template.Spec.ServiceAccount
+			}
+			break
+		}
+	}
+	if serviceAccount == "" {
+		return nil, fmt.Errorf("the %s job service account was not found", job)
+	}
+	roles := map[string]*object{}
+	for _, o := range objects {
+		switch o.Kind {
+		case "Role":
+			roles["Role/"+o.Metadata.Namespace+"/"+o.Metadata.Name] = o
+		case "ClusterRole":
+			roles["ClusterRole/"+o.Metadata.Name] = o
+		}
+	}
+	grants := []Grant{}
+	seen := map[Grant]bool{}
+	for _, binding := range objects {
+		if binding.Kind != "RoleBinding" && binding.Kind != "ClusterRoleBinding" {
+			continue
+		}
+		bound := false
+		for _, subject := range binding.Subjects {
+			subjectNamespace := subject.Namespace
+			if subjectNamespace == "" {
+				subjectNamespace = binding.Metadata.Namespace
+			}
+			if subject.Kind == "ServiceAccount" && subject.Name == serviceAccount && subjectNamespace == namespace {
+				bound = true
+			}
+		}
+		if !bound {
+			continue
+		}
+		// role bindings grant the role in their namespace, cluster
+		// role bindings grant the cluster role in every namespace.
+		grantNamespace := binding.Metadata.Namespace
+		if binding.Kind == "ClusterRoleBinding" {
+			grantNamespace = ""
+		}
+		key := "ClusterRole/" + binding.RoleRef.Name
+		if binding.RoleRef.Kind == "Role" {
+			key = "Role/" + binding.Metadata.Namespace + "/" + binding.RoleRef.Name
+		}
+		role, ok := roles[key]
+		if !ok {
+			return nil, fmt.Errorf("the %s %s role was not found", binding.Kind, binding.Metadata.Name)
+		}
+		for _, rule := range role.Rules {
+			names := rule.ResourceNames
+			if len(names) == 0 {
+				names = []string{""}
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					for _, verb := range rule.Verbs {
+						for _, name := range names {
+							grant := Grant{grantNamespace, group, resource, verb, name}
+							if !seen[grant] {
+								seen[grant] = true
+								grants = append(grants, grant)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return grants, nil
+}
+
+// collectionVerbs are the verbs that can not be restricted by the
+// resource names of a rule.
+var collectionVerbs = map[string]bool{"create": true, "list": true, "watch": true, "deletecollection": true}
+
+// allows reports whether the grant allows the access.
+func (g Grant) allows(a Access) bool {
+	match := func(granted, requested string) bool {
+		return granted == "*" || granted == requested
+	}
+	if g.Namespace != "" && g.Namespace != a.Namespace {
+		return false
+	}
+	if !match(g.APIGroup, a.APIGroup) || !match(g.Resource, a.Resource) || !match(g.Verb, a.Verb) {
+		return false
+	}
+	if g.Name == "" {
+		return true
+	}
+	return !collectionVerbs[a.Verb] && g.Name == a.Name
+}
+
+// Report contains the result of a grants verification.
+type Report struct {
+	// Missing are the accesses not allowed by any grant. They fail at
+	// runtime.
+	Missing []Access
+	// Unused are the grants that allow none of the accesses. They are
+	// over-privileged.
+	Unused []Grant
+}
+
+// String returns the missing accesses and unused grants, one per
+// line.
+func (r *Report) String() string {
+	lines := []string{}
+	for _, access := range r.Missing {
+		lines = append(lines, "missing: "+access.String())
+	}
+	for _, grant := range r.Unused {
+		lines = append(lines, "unused: "+grant.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Verify compares the accesses with the grants.
+func Verify(grants []Grant, accesses []Access) *Report {
+	report := &Report{Missing: []Access{}, Unused: []Grant{}}
+	used := map[Grant]bool{}
+	for _, access := range accesses {
+		allowed := false
+		for _, grant := range grants {
+			if grant.allows(access) {
+				allowed = true
+				used[grant] = true
+			}
+		}
+		if !allowed {
+			report.Missing = append(report.Missing, access)
+		}
+	}
+	for _, grant := range grants {
+		if !used[grant] {
+			report.Unused = append(report.Unused, grant)
+		}
+	}
+	return report
+}
+
+// fakeClientset is the subset of the fake clientset used to record
+// the api accesses.
+type fakeClientset interface {
+	Actions() []k8stesting.Action
+	ClearActions()
+}
+
+// Record runs the function and returns the api accesses it made
+// through the fake clientset.
+func Record(clientset fakeClientset, fn func() error) ([]Access, error) {
+	clientset.ClearActions()
+	err := fn()
+	return Accesses(clientset.Actions()), err
+}
@@ -0,0 +1,150 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testManifests = `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: test
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - secrets
+  verbs:
+  - create
+  - delete
+- apiGroups:
+  - ""
+  resources:
+  - configmaps
+  verbs:
+  - get
+  - create
+  resourceNames:
+  - {{ .name }}
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: test
+subjects:
+- kind: ServiceAccount
+  name: test
+roleRef:
+  kind: Role
+  name: test
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: other
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - pods
+  verbs:
+  - get
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: other
+subjects:
+- kind: ServiceAccount
+  name: other
+roleRef:
+  kind: Role
+  name: other
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test-job
+spec:
+  template:
+    spec:
+      containers:
+      - name: test
+        image: {{ .image }}
+      serviceAccount: test
+`
+
+func TestAccesses(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "test", metav1.GetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := clientset.CoreV1().ConfigMaps("test").List(context.TODO(), metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Access{
+		{Namespace: "test", Resource: "secrets", Verb: "create", Name: "test"},
+		{Namespace: "test", Resource: "secrets", Verb: "get", Name: "test"},
+		{Namespace: "test", Resource: "configmaps", Verb: "list"},
+	}, Accesses(clientset.Actions()))
+}
+
+func TestGrants(t *testing.T) {
+	manifests, err := RenderManifests(testManifests, map[string]interface{}{"name": "test-vars", "image": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := Grants(manifests, "test", "test-job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Grant{
+		{Namespace: "test", Resource: "secrets", Verb: "create"},
+		{Namespace: "test", Resource: "secrets", Verb: "delete"},
+		{Namespace: "test", Resource: "configmaps", Verb: "get", Name: "test-vars"},
+		{Namespace: "test", Resource: "configmaps", Verb: "create", Name: "test-vars"},
+	}, grants)
+
+	if _, err := Grants(manifests, "test", "missing"); err == nil {
+		t.Fatal("expected an error for a missing job")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	grants := []Grant{
+		{Namespace: "test", Resource: "secrets", Verb: "create"},
+		{Namespace: "test", Resource: "secrets", Verb: "delete"},
+		{Namespace: "test", Resource: "configmaps", Verb: "get", Name: "test-vars"},
+		{Namespace: "test", Resource: "configmaps", Verb: "create", Name: "test-vars"},
+	}
+	accesses := []Access{
+		{Namespace: "test", Resource: "secrets", Verb: "create", Name: "test"},
+		{Namespace: "other", Resource: "secrets", Verb: "create", Name: "test"},
+		{Namespace: "test", Resource: "configmaps", Verb: "get", Name: "test-vars"},
+		{Namespace: "test", Resource: "configmaps", Verb: "get", Name: "other-vars"},
+		// resource names can not restrict create requests.
+		{Namespace: "test", Resource: "configmaps", Verb: "create", Name: "test-vars"},
+	}
+	report := Verify(grants, accesses)
+	assert.Equal(t, []Access{accesses[1], accesses[3], accesses[4]}, report.Missing)
+	assert.Equal(t, []Grant{grants[1], grants[3]}, report.Unused)
+	assert.Equal(t, `missing: create secrets/test in "other"
+missing: get configmaps/other-vars in "test"
+missing: create configmaps/test-vars in "test"
+unused: delete secrets/* in "test"
+unused: create configmaps/test-vars in "test"`, report.String())
+}
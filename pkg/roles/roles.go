@@ -0,0 +1,57 @@
+package roles
+
+// Role is a toolchain role.
+type Role string
+
+const (
+	// Admin manages the toolchain components.
+	Admin Role = "admin"
+	// Editor manages the applications.
+	Editor Role = "editor"
+	// Viewer has read access to the applications.
+	Viewer Role = "viewer"
+)
+
+// Group is an sso group and the toolchain role of its members.
+type Group struct {
+	Name string
+	Role Role
+}
+
+// Groups are the sso groups created by every sso provider, ordered
+// from the most privileged role.
+var Groups = []Group{
+	{"admins", Admin},
+	{"editors", Editor},
+	{"viewers", Viewer},
+}
+
+// Binding binds an sso group to a component role.
+type Binding struct {
+	Group string
+	Role  string
+}
+
+// Bindings maps the sso groups to the component roles. Groups whose
+// toolchain role has no component role are not bound.
+func Bindings(componentRoles map[Role]string) []Binding {
+	bindings := []Binding{}
+	for _, group := range Groups {
+		role, ok := componentRoles[group.Role]
+		if !ok {
+			continue
+		}
+		bindings = append(bindings, Binding{Group: group.Name, Role: role})
+	}
+	return bindings
+}
+
+// IsGroup reports whether name is an sso group of the role model.
+func IsGroup(name string) bool {
+	for _, group := range Groups {
+		if group.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package roles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindings(t *testing.T) {
+	bindings := Bindings(map[Role]string{Admin: "owner", Viewer: "viewer"})
+	assert.Equal(t, []Binding{{"admins", "owner"}, {"viewers", "viewer"}}, bindings, "expected the unmapped roles to be skipped")
+	assert.Empty(t, Bindings(map[Role]string{}))
+}
+
+func TestIsGroup(t *testing.T) {
+	assert.True(t, IsGroup("editors"))
+	assert.False(t, IsGroup("trustacks"))
+}
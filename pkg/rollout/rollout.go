@@ -0,0 +1,33 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// restartedAtAnnotation is the pod template annotation used by
+// kubectl to trigger a rollout restart.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// restartPatch creates the pod template patch that triggers a
+// rollout.
+func restartPatch() []byte {
+	return []byte(fmt.Sprintf(`{"spec": {"template": {"metadata": {"annotations": {"%s": "%s"}}}}}`, restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+}
+
+// RestartDeployment triggers a rollout restart of the deployment.
+func RestartDeployment(namespace, name string, clientset kubernetes.Interface) error {
+	_, err := clientset.AppsV1().Deployments(namespace).Patch(context.TODO(), name, types.StrategicMergePatchType, restartPatch(), metav1.PatchOptions{})
+	return err
+}
+
+// RestartStatefulSet triggers a rollout restart of the stateful set.
+func RestartStatefulSet(namespace, name string, clientset kubernetes.Interface) error {
+	_, err := clientset.AppsV1().StatefulSets(namespace).Patch(context.TODO(), name, types.StrategicMergePatchType, restartPatch(), metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,51 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRestartDeployment(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+	if _, err := clientset.AppsV1().Deployments("test").Create(context.TODO(), deployment, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestartDeployment("test", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := clientset.AppsV1().Deployments("test").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, deployment.Spec.Template.Annotations[restartedAtAnnotation], "expected a restarted at annotation")
+}
+
+func TestRestartStatefulSet(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+	if _, err := clientset.AppsV1().StatefulSets("test").Create(context.TODO(), statefulSet, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestartStatefulSet("test", "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	statefulSet, err := clientset.AppsV1().StatefulSets("test").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, statefulSet.Spec.Template.Annotations[restartedAtAnnotation], "expected a restarted at annotation")
+}
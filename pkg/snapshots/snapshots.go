@@ -0,0 +1,389 @@
+package snapshots
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/trustacks/catalog/pkg/hooks"
+	"github.com/trustacks/catalog/pkg/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// componentLabel is the snapshot component label.
+	componentLabel = "trustacks.io/snapshot-component"
+	// revisionLabel is the snapshot release revision label.
+	revisionLabel = "trustacks.io/snapshot-revision"
+	// snapshotKey is the snapshot secret data key.
+	snapshotKey = "snapshot"
+	// maxSnapshots is the number of snapshots kept per component.
+	maxSnapshots = 10
+)
+
+var (
+	// sharedConfigMaps are the config maps that contain keys for
+	// every component.
+	sharedConfigMaps = []string{"system-vars", "catalog-migrations"}
+	// sharedSecrets are the secrets that contain keys for every
+	// component.
+	sharedSecrets = []string{"system-secrets"}
+)
+
+// CreateRules are the hook permissions used by Create.
+var CreateRules = []hooks.Rule{
+	{Resources: []string{"secrets"}, Verbs: []string{"get", "list", "create", "update", "delete"}},
+	{Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+}
+
+// Spec contains the names of the component's hook managed objects.
+type Spec struct {
+	Secrets    []string
+	ConfigMaps []string
+}
+
+// RestoreRules returns the hook permissions used by Restore. The
+// shared object keys are patched, while the spec objects are
+// replaced or deleted.
+func (s Spec) RestoreRules() []hooks.Rule {
+	rules := []hooks.Rule{{Resources: []string{"secrets", "configmaps"}, Verbs: []string{"get", "create", "patch"}}}
+	if len(s.Secrets) > 0 {
+		rules = append(rules, hooks.Rule{Resources: []string{"secrets"}, Verbs: []string{"update", "delete"}})
+	}
+	if len(s.ConfigMaps) > 0 {
+		rules = append(rules, hooks.Rule{Resources: []string{"configmaps"}, Verbs: []string{"update", "delete"}})
+	}
+	return rules
+}
+
+// object is a snapshot of a hook managed object.
+type object struct {
+	Name   string            `json:"name"`
+	Exists bool              `json:"exists"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Data   map[string][]byte `json:"data,omitempty"`
+}
+
+// snapshot contains the component state before an upgrade.
+type snapshot struct {
+	Revision         int                          `json:"revision"`
+	Secrets          []object                     `json:"secrets"`
+	ConfigMaps       []object                     `json:"configMaps"`
+	SharedSecrets    map[string]map[string][]byte `json:"sharedSecrets"`
+	SharedConfigMaps map[string]map[string]string `json:"sharedConfigMaps"`
+}
+
+// ReleaseRevision gets the helm release revision the hook was
+// rendered for.
+func ReleaseRevision() (int, error) {
+	revision, err := strconv.Atoi(os.Getenv("RELEASE_REVISION"))
+	if err != nil {
+		return 0, errors.New("a valid release revision is required")
+	}
+	return revision, nil
+}
+
+// snapshotName returns the name of the component snapshot secret.
+func snapshotName(component string, revision int) string {
+	return fmt.Sprintf("%s-snapshot-%d", component, revision)
+}
+
+// isComponentKey checks if the shared object key belongs to the
+// component.
+func isComponentKey(component, key string) bool {
+	return key == component || strings.HasPrefix(key, fmt.Sprintf("%s.", component))
+}
+
+// Create captures the component's hook managed objects and shared
+// object keys in a snapshot for the release revision.
+func Create(component string, revision int, spec Spec, namespace string, clientset kubernetes.Interface) error {
+	s := &snapshot{
+		Revision:         revision,
+		SharedSecrets:    map[string]map[string][]byte{},
+		SharedConfigMaps: map[string]map[string]string{},
+	}
+	for _, name := range spec.Secrets {
+		o := object{Name: name}
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		if err == nil {
+			o.Exists, o.Labels, o.Data = true, secret.Labels, secret.Data
+		}
+		s.Secrets = append(s.Secrets, o)
+	}
+	for _, name := range spec.ConfigMaps {
+		o := object{Name: name}
+		configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		if err == nil {
+			o.Exists, o.Labels, o.Data = true, configMap.Labels, map[string][]byte{}
+			for k, v := range configMap.Data {
+				o.Data[k] = []byte(v)
+			}
+		}
+		s.ConfigMaps = append(s.ConfigMaps, o)
+	}
+	for _, name := range sharedSecrets {
+		data := map[string][]byte{}
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		if err == nil {
+			for k, v := range secret.Data {
+				if isComponentKey(component, k) {
+					data[k] = v
+				}
+			}
+		}
+		s.SharedSecrets[name] = data
+	}
+	for _, name := range sharedConfigMaps {
+		data := map[string]string{}
+		configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		if err == nil {
+			for k, v := range configMap.Data {
+				if isComponentKey(component, k) {
+					data[k] = v
+				}
+			}
+		}
+		s.SharedConfigMaps[name] = data
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: snapshotName(component, revision),
+			Labels: map[string]string{
+				componentLabel: component,
+				revisionLabel:  strconv.Itoa(revision),
+			},
+		},
+		Data: map[string][]byte{snapshotKey: data},
+	}
+	client := clientset.CoreV1().Secrets(namespace)
+	if _, err := client.Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		if !strings.Contains(err.Error(), "already exists") {
+			return err
+		}
+		// replace the snapshot left by a previous install.
+		if _, err := client.Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return prune(component, namespace, clientset)
+}
+
+// prune deletes the oldest component snapshots.
+func prune(component, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().Secrets(namespace)
+	secrets, err := client.List(context.TODO(), metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", componentLabel, component)})
+	if err != nil {
+		return err
+	}
+	revisions := []int{}
+	for _, secret := range secrets.Items {
+		revision, err := strconv.Atoi(secret.Labels[revisionLabel])
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	if len(revisions) <= maxSnapshots {
+		return nil
+	}
+	sort.Ints(revisions)
+	for _, revision := range revisions[:len(revisions)-maxSnapshots] {
+		err := client.Delete(context.TODO(), snapshotName(component, revision), metav1.DeleteOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore restores the component's hook managed objects and shared
+// object keys from the snapshot of the release revision.
+func Restore(component string, revision int, namespace string, clientset kubernetes.Interface) error {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), snapshotName(component, revision), metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			logging.Info("no snapshot found", "revision", revision)
+			return nil
+		}
+		return err
+	}
+	s := &snapshot{}
+	if err := json.Unmarshal(secret.Data[snapshotKey], s); err != nil {
+		return err
+	}
+	for _, o := range s.Secrets {
+		if err := restoreSecret(o, namespace, clientset); err != nil {
+			return err
+		}
+	}
+	for _, o := range s.ConfigMaps {
+		if err := restoreConfigMap(o, namespace, clientset); err != nil {
+			return err
+		}
+	}
+	for name, data := range s.SharedSecrets {
+		if err := restoreSharedSecretKeys(component, name, data, namespace, clientset); err != nil {
+			return err
+		}
+	}
+	for name, data := range s.SharedConfigMaps {
+		if err := restoreSharedConfigMapKeys(component, name, data, namespace, clientset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreSecret restores the secret to its snapshot state.
+func restoreSecret(o object, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().Secrets(namespace)
+	current, err := client.Get(context.TODO(), o.Name, metav1.GetOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	exists := err == nil
+	switch {
+	case !o.Exists && exists:
+		return client.Delete(context.TODO(), o.Name, metav1.DeleteOptions{})
+	case o.Exists && exists:
+		current.Labels, current.Data = o.Labels, o.Data
+		_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{})
+		return err
+	case o.Exists:
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: o.Name, Labels: o.Labels}, Data: o.Data}
+		_, err = client.Create(context.TODO(), secret, metav1.CreateOptions{})
+		return err
+	}
+	return nil
+}
+
+// restoreConfigMap restores the config map to its snapshot state.
+func restoreConfigMap(o object, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().ConfigMaps(namespace)
+	current, err := client.Get(context.TODO(), o.Name, metav1.GetOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	exists := err == nil
+	data := map[string]string{}
+	for k, v := range o.Data {
+		data[k] = string(v)
+	}
+	switch {
+	case !o.Exists && exists:
+		return client.Delete(context.TODO(), o.Name, metav1.DeleteOptions{})
+	case o.Exists && exists:
+		current.Labels, current.Data = o.Labels, data
+		_, err = client.Update(context.TODO(), current, metav1.UpdateOptions{})
+		return err
+	case o.Exists:
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: o.Name, Labels: o.Labels}, Data: data}
+		_, err = client.Create(context.TODO(), configMap, metav1.CreateOptions{})
+		return err
+	}
+	return nil
+}
+
+// restorePatch creates a merge patch that replaces the component
+// keys of the shared object with the snapshot keys.
+func restorePatch(component string, current []string, data map[string]interface{}) ([]byte, error) {
+	keys := map[string]interface{}{}
+	for _, k := range current {
+		if isComponentKey(component, k) {
+			keys[k] = nil
+		}
+	}
+	for k, v := range data {
+		keys[k] = v
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(map[string]interface{}{"data": keys})
+}
+
+// restoreSharedSecretKeys restores the component keys of the shared
+// secret.
+func restoreSharedSecretKeys(component, name string, data map[string][]byte, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().Secrets(namespace)
+	secret, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		_, err = client.Create(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}, Data: data}, metav1.CreateOptions{})
+		return err
+	}
+	current := []string{}
+	for k := range secret.Data {
+		current = append(current, k)
+	}
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+	patch, err := restorePatch(component, current, values)
+	if err != nil || patch == nil {
+		return err
+	}
+	_, err = client.Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// restoreSharedConfigMapKeys restores the component keys of the
+// shared config map.
+func restoreSharedConfigMapKeys(component, name string, data map[string]string, namespace string, clientset kubernetes.Interface) error {
+	client := clientset.CoreV1().ConfigMaps(namespace)
+	configMap, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		_, err = client.Create(context.TODO(), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name}, Data: data}, metav1.CreateOptions{})
+		return err
+	}
+	current := []string{}
+	for k := range configMap.Data {
+		current = append(current, k)
+	}
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+	patch, err := restorePatch(component, current, values)
+	if err != nil || patch == nil {
+		return err
+	}
+	_, err = client.Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
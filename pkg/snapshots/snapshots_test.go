@@ -0,0 +1,133 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReleaseRevision(t *testing.T) {
+	defer os.Unsetenv("RELEASE_REVISION")
+	os.Setenv("RELEASE_REVISION", "3")
+	revision, err := ReleaseRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, revision, "got an unexpected release revision")
+
+	os.Setenv("RELEASE_REVISION", "")
+	if _, err := ReleaseRevision(); err == nil {
+		t.Fatal("expected a release revision error")
+	}
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	objects := []*corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-secret"},
+			Data:       map[string][]byte{"key": []byte("before")},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "system-secrets"},
+			Data: map[string][]byte{
+				"test.password":  []byte("before"),
+				"other.password": []byte("before"),
+			},
+		},
+	}
+	for _, secret := range objects {
+		if _, err := clientset.CoreV1().Secrets("test").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	systemVars := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "system-vars"},
+		Data:       map[string]string{"test.server": "before", "other.server": "before"},
+	}
+	if _, err := clientset.CoreV1().ConfigMaps("test").Create(context.TODO(), systemVars, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	spec := Spec{Secrets: []string{"test-secret"}, ConfigMaps: []string{"test-config"}}
+	if err := Create("test", 1, spec, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate the upgrade changes.
+	secret, _ := clientset.CoreV1().Secrets("test").Get(context.TODO(), "test-secret", metav1.GetOptions{})
+	secret.Data["key"] = []byte("after")
+	if _, err := clientset.CoreV1().Secrets("test").Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	testConfig := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config"}}
+	if _, err := clientset.CoreV1().ConfigMaps("test").Create(context.TODO(), testConfig, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	systemSecrets, _ := clientset.CoreV1().Secrets("test").Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	systemSecrets.Data["test.password"] = []byte("after")
+	systemSecrets.Data["test.token"] = []byte("after")
+	systemSecrets.Data["other.password"] = []byte("after")
+	if _, err := clientset.CoreV1().Secrets("test").Update(context.TODO(), systemSecrets, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	systemVars.Data["test.server"] = "after"
+	if _, err := clientset.CoreV1().ConfigMaps("test").Update(context.TODO(), systemVars, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore("test", 1, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), "test-secret", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "before", string(secret.Data["key"]), "expected the secret to be restored")
+	if _, err := clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), "test-config", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected the config map created after the snapshot to be deleted")
+	}
+	systemSecrets, err = clientset.CoreV1().Secrets("test").Get(context.TODO(), "system-secrets", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "before", string(systemSecrets.Data["test.password"]), "expected the component secret to be restored")
+	assert.NotContains(t, systemSecrets.Data, "test.token", "expected the component secret added after the snapshot to be removed")
+	assert.Equal(t, "after", string(systemSecrets.Data["other.password"]), "expected the other component's secret to be kept")
+	systemVars, err = clientset.CoreV1().ConfigMaps("test").Get(context.TODO(), "system-vars", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "before", systemVars.Data["test.server"], "expected the component variable to be restored")
+}
+
+func TestRestoreMissingSnapshot(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	if err := Restore("test", 1, "test", clientset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	for revision := 1; revision <= maxSnapshots+2; revision++ {
+		if err := Create("test", revision, Spec{}, "test", clientset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secrets, err := clientset.CoreV1().Secrets("test").List(context.TODO(), metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=test", componentLabel)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, secrets.Items, maxSnapshots, "got an unexpected number of snapshots")
+	for _, revision := range []int{1, 2} {
+		if _, err := clientset.CoreV1().Secrets("test").Get(context.TODO(), snapshotName("test", revision), metav1.GetOptions{}); err == nil {
+			t.Fatalf("expected the revision %d snapshot to be pruned", revision)
+		}
+	}
+}
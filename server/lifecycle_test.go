@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+)
+
+// writeKeyPair writes a self signed key pair for the common name.
+func writeKeyPair(t *testing.T, dir, commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestProbes(t *testing.T) {
+	ready := &atomic.Value{}
+	ready.Store(false)
+	w := httptest.NewRecorder()
+	readyRequestHandler(ready)(w, httptest.NewRequest("GET", "https://test.com/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "expected the server to not be ready")
+
+	ready.Store(true)
+	w = httptest.NewRecorder()
+	readyRequestHandler(ready)(w, httptest.NewRequest("GET", "https://test.com/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "expected the server to be ready")
+
+	w = httptest.NewRecorder()
+	healthRequestHandler(w, httptest.NewRequest("GET", "https://test.com/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "expected the server to be healthy")
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := recoverMiddleware(logMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "https://test.com", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "expected an internal server error")
+}
+
+func TestServeShutdown(t *testing.T) {
+	cat, err := catalog.NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(cat, &Config{ShutdownTimeout: time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Serve(ctx, listener)
+	}()
+	url := "http://" + listener.Addr().String()
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(url + readyRoute)
+		return err == nil && resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond, "expected the server to become ready")
+
+	cancel()
+	select {
+	case err := <-errs:
+		assert.NoError(t, err, "expected a graceful shutdown")
+	case <-time.After(5 * time.Second):
+		t.Fatal("server shutdown timeout")
+	}
+	if _, err := http.Get(url + healthRoute); err == nil {
+		t.Fatal("expected the server to be stopped")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "first")
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := reloader.getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := cert.Certificate[0]
+
+	writeKeyPair(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cert, err = reloader.getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, first, cert.Certificate[0], "expected the certificate to be reloaded")
+}
+
+func TestRunListenError(t *testing.T) {
+	cat, err := catalog.NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	s := NewServer(cat, &Config{Addr: listener.Addr().String()})
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatal("expected a listen error")
+	}
+	ready, _ := s.ready.Load().(bool)
+	assert.False(t, ready, "expected the server to not be ready without a listener")
+}
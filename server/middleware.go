@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
+)
+
+// logMiddleware logs the handled requests.
+func logMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := metrics.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+		logging.Info("handled request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.Code,
+			"duration", time.Since(start).String(),
+		)
+	})
+}
+
+// recoverMiddleware responds with an internal server error if the
+// handler panics.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				logging.Error("recovered request handler panic", "path", r.URL.Path, "panic", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,16 +1,66 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/logging"
+	"github.com/trustacks/catalog/pkg/metrics"
 )
 
-// serverPort is the port of the webserver.
-const serverPort = "80"
+const (
+	// defaultAddr is the default listen address of the webserver.
+	defaultAddr = ":80"
+	// manifestRoute is the catalog manifest route.
+	manifestRoute = "/.well-known/catalog-manifest"
+	// metricsRoute is the prometheus metrics route.
+	metricsRoute = "/metrics"
+	// healthRoute is the liveness probe route.
+	healthRoute = "/healthz"
+	// readyRoute is the readiness probe route.
+	readyRoute = "/readyz"
+)
+
+// Config contains the catalog server settings.
+type Config struct {
+	// Addr is the listen address.
+	Addr string
+	// TLSCertFile and TLSKeyFile enable tls when both are set. The
+	// key pair is reloaded when the files change.
+	TLSCertFile string
+	TLSKeyFile  string
+	// ReadTimeout and WriteTimeout bound the request handling time.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// ShutdownTimeout bounds the graceful shutdown time.
+	ShutdownTimeout time.Duration
+}
+
+// ConfigFromEnv creates the server config from the environment.
+func ConfigFromEnv() *Config {
+	config := &Config{
+		Addr:            os.Getenv("CATALOG_SERVER_ADDR"),
+		TLSCertFile:     os.Getenv("CATALOG_TLS_CERT_FILE"),
+		TLSKeyFile:      os.Getenv("CATALOG_TLS_KEY_FILE"),
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+	}
+	if config.Addr == "" {
+		config.Addr = defaultAddr
+	}
+	return config
+}
 
 // catalogRequestHandler returns the component catalog json
 // manifest.
@@ -18,21 +68,130 @@ func catalogRequestHandler(c *catalog.ComponentCatalog) func(w http.ResponseWrit
 	return func(w http.ResponseWriter, r *http.Request) {
 		data, err := json.Marshal(c)
 		if err != nil {
-			log.Println("error unmarshaling the catalog:", err)
+			logging.Error("error unmarshaling the catalog", "error", err)
 		}
+		metrics.SetManifestSize(len(data))
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write(data); err != nil {
-			log.Println("error:", err)
+			logging.Error("error writing the catalog response", "error", err)
+		}
+	}
+}
+
+// healthRequestHandler reports the server liveness.
+func healthRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyRequestHandler reports whether the server accepts requests.
+func readyRequestHandler(ready *atomic.Value) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, _ := ready.Load().(bool); !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
 		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+// newServeMux creates the catalog server routes.
+func newServeMux(cat *catalog.ComponentCatalog, ready *atomic.Value) *http.ServeMux {
+	for name, chart := range cat.ChartVersions() {
+		metrics.SetBuildInfo(name, chart.Chart, chart.Version)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(manifestRoute, metrics.InstrumentHandler(manifestRoute, catalogRequestHandler(cat)))
+	mux.Handle(metricsRoute, metrics.Handler())
+	mux.HandleFunc(healthRoute, healthRequestHandler)
+	mux.HandleFunc(readyRoute, readyRequestHandler(ready))
+	mux.HandleFunc(uiRoute, uiRequestHandler)
+	mux.HandleFunc(previewRoute, metrics.InstrumentHandler(previewRoute, previewRequestHandler(cat)))
+	return mux
+}
+
+// Server is the catalog webserver.
+type Server struct {
+	config *Config
+	server *http.Server
+	ready  *atomic.Value
+}
+
+// NewServer creates the catalog server.
+func NewServer(cat *catalog.ComponentCatalog, config *Config) *Server {
+	ready := &atomic.Value{}
+	ready.Store(false)
+	return &Server{
+		config: config,
+		ready:  ready,
+		server: &http.Server{
+			Handler:      recoverMiddleware(logMiddleware(newServeMux(cat, ready))),
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+		},
+	}
+}
+
+// Run listens on the configured address and serves the catalog
+// until the context is done.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves the catalog on the listener until the context is
+// done, then gracefully shuts down the server.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	if s.config.TLSCertFile != "" && s.config.TLSKeyFile != "" {
+		reloader, err := newCertReloader(s.config.TLSCertFile, s.config.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			GetCertificate: reloader.getCertificate,
+			MinVersion:     tls.VersionTLS12,
+		})
+	}
+	// the listener already accepts connections, which are served once
+	// Serve runs, so the server is ready before it is started.
+	s.ready.Store(true)
+	logging.Info("starting server", "addr", listener.Addr().String(), "tls", s.config.TLSCertFile != "")
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		s.ready.Store(false)
+		return err
+	case <-ctx.Done():
+	}
+	logging.Info("shutting down server")
+	s.ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	return nil
 }
 
-// startCatalogServer starts the catalog server.
+// StartCatalogServer starts the catalog server and stops it on
+// SIGTERM or SIGINT.
 func StartCatalogServer(cat *catalog.ComponentCatalog) {
-	http.HandleFunc("/.well-known/catalog-manifest", catalogRequestHandler(cat))
-	log.Printf("starting server on *:%s\n", serverPort)
-	if err := http.ListenAndServe(fmt.Sprintf(":%s", serverPort), nil); err != nil {
-		log.Fatal(err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if err := NewServer(cat, ConfigFromEnv()).Run(ctx); err != nil {
+		logging.Fatal("catalog server failed", "error", err)
 	}
 }
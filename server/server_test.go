@@ -3,7 +3,10 @@ package server
 import (
 	"encoding/json"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/trustacks/catalog/pkg/catalog"
@@ -37,3 +40,24 @@ func TestCatalogRequestHandler(t *testing.T) {
 		t.Fatal("got an unexpected helm repository")
 	}
 }
+
+func TestMetricsRoute(t *testing.T) {
+	cat, err := catalog.NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := &atomic.Value{}
+	ts := httptest.NewServer(newServeMux(cat, ready))
+	defer ts.Close()
+	if _, err := http.Get(ts.URL + manifestRoute); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(ts.URL + metricsRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `catalog_http_requests_total{code="200",route="/.well-known/catalog-manifest"}`) {
+		t.Fatal("expected the manifest request to be counted")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/trustacks/catalog/pkg/logging"
+)
+
+// certReloader serves the tls key pair and reloads it when the files
+// change, such as when cert-manager renews a mounted certificate.
+type certReloader struct {
+	mu       sync.Mutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// newCertReloader loads the tls key pair.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// lastModified returns the latest modification time of the key pair
+// files.
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload loads the key pair from the files.
+func (r *certReloader) reload() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// getCertificate returns the key pair, reloading it first if the
+// files changed. The current key pair is kept if the reload fails.
+func (r *certReloader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if modTime, err := r.lastModified(); err == nil && !modTime.Equal(r.modTime) {
+		if err := r.reload(); err != nil {
+			logging.Warn("error reloading the tls certificate", "error", err)
+		} else {
+			logging.Info("reloaded the tls certificate")
+		}
+	}
+	return r.cert, nil
+}
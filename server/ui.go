@@ -0,0 +1,82 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/trustacks/catalog/pkg/catalog"
+	"github.com/trustacks/catalog/pkg/logging"
+)
+
+const (
+	// uiRoute is the catalog web ui route.
+	uiRoute = "/"
+	// previewRoute is the values preview route.
+	previewRoute = "/api/preview"
+)
+
+//go:embed ui/index.html
+var uiPage []byte
+
+// previewRequest contains the component and parameters to render.
+type previewRequest struct {
+	Component  string            `json:"component"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// previewResponse contains the rendered values or the render error.
+type previewResponse struct {
+	Values string `json:"values,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// uiRequestHandler serves the catalog web ui.
+func uiRequestHandler(w http.ResponseWriter, r *http.Request) {
+	// the ui route matches every path unknown to the mux.
+	if r.URL.Path != uiRoute {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(uiPage); err != nil {
+		logging.Error("error writing the ui response", "error", err)
+	}
+}
+
+// writePreviewResponse writes the json preview response.
+func writePreviewResponse(w http.ResponseWriter, code int, resp previewResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		logging.Error("error marshaling the preview response", "error", err)
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(data); err != nil {
+		logging.Error("error writing the preview response", "error", err)
+	}
+}
+
+// previewRequestHandler renders the component values with the
+// requested parameters.
+func previewRequestHandler(c *catalog.ComponentCatalog) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Add("Allow", http.MethodPost)
+			writePreviewResponse(w, http.StatusMethodNotAllowed, previewResponse{Error: "method not allowed"})
+			return
+		}
+		req := previewRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writePreviewResponse(w, http.StatusBadRequest, previewResponse{Error: err.Error()})
+			return
+		}
+		values, err := c.RenderValues(req.Component, req.Parameters)
+		if err != nil {
+			writePreviewResponse(w, http.StatusBadRequest, previewResponse{Error: err.Error()})
+			return
+		}
+		writePreviewResponse(w, http.StatusOK, previewResponse{Values: values})
+	}
+}
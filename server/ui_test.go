@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustacks/catalog/pkg/catalog"
+)
+
+func TestUIRequestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	uiRequestHandler(w, httptest.NewRequest("GET", "https://test.com/", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "got an unexpected status code")
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html", "expected an html response")
+	assert.Contains(t, w.Body.String(), "/.well-known/catalog-manifest", "expected the ui to load the catalog manifest")
+
+	w = httptest.NewRecorder()
+	uiRequestHandler(w, httptest.NewRequest("GET", "https://test.com/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "expected unknown paths to not be found")
+}
+
+func TestPreviewRequestHandler(t *testing.T) {
+	cat, err := catalog.NewComponentCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat.AddComponent("test", &testComponent{
+		&catalog.BaseComponent{
+			Values: "host: test.{{ .domain }}",
+		},
+	})
+	tests := []struct {
+		method string
+		body   string
+		code   int
+		resp   previewResponse
+	}{
+		{"POST", `{"component": "test", "parameters": {"domain": "example.com"}}`, http.StatusOK, previewResponse{Values: "host: test.example.com"}},
+		{"POST", `{"component": "test"}`, http.StatusOK, previewResponse{Values: "host: test.local.gd"}},
+		{"POST", `{"component": "missing"}`, http.StatusBadRequest, previewResponse{Error: "'missing' component not found"}},
+		{"GET", ``, http.StatusMethodNotAllowed, previewResponse{Error: "method not allowed"}},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		previewRequestHandler(cat)(w, httptest.NewRequest(tc.method, "https://test.com/api/preview", strings.NewReader(tc.body)))
+		assert.Equal(t, tc.code, w.Code, "got an unexpected status code")
+		body, _ := io.ReadAll(w.Result().Body)
+		resp := previewResponse{}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.resp, resp, "got an unexpected preview response")
+	}
+}